@@ -1,9 +1,14 @@
 package main
 
 import (
+	"os"
+
 	"github.com/msmkdenis/wb-order-nats/internal/app/natsproducer"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "publish-file" {
+		os.Exit(natsproducer.PublishFileCommand(os.Args[2:]))
+	}
 	natsproducer.Run()
 }
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+
+	"github.com/msmkdenis/wb-order-nats/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -9,14 +9,18 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/brianvoe/gofakeit/v6"
+	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
 	"github.com/nats-io/stan.go"
 	"go.uber.org/zap"
 
+	"github.com/msmkdenis/wb-order-nats/internal/codec"
+	"github.com/msmkdenis/wb-order-nats/internal/compression"
 	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/openapi"
+	"github.com/msmkdenis/wb-order-nats/internal/signature"
 )
 
 type producerConfig struct {
@@ -24,9 +28,29 @@ type producerConfig struct {
 	Client     string
 	NatsURL    string
 	ServerAddr string
+	SigningKey string
+
+	StatusSubject string
 }
 
+// defaultStatusSubject is the subject of status events if NATS_STATUS_SUBJECT is not set.
+const defaultStatusSubject = "order-status"
+
 func Run() {
+	config := loadConfig()
+	logger, _ := zap.NewProduction()
+	producer := newProducer(config, logger)
+	e := echo.New()
+	NewProducerHandler(e, producer, logger)
+	openapi.Serve(e, OpenAPI())
+
+	errStart := e.Start(config.ServerAddr)
+	if errStart != nil && !errors.Is(errStart, http.ErrServerClosed) {
+		logger.Fatal(errStart.Error())
+	}
+}
+
+func loadConfig() *producerConfig {
 	err := godotenv.Load("natsproducer.env")
 	if err != nil {
 		log.Info("Error loading .env file, using default values")
@@ -37,22 +61,37 @@ func Run() {
 		Client:     os.Getenv("NATS_PRODUCER_CLIENT"),
 		NatsURL:    os.Getenv("NATS_PRODUCER_URL"),
 		ServerAddr: os.Getenv("PRODUCER_SERV_ADDR"),
+		SigningKey: os.Getenv("NATS_PRODUCER_SIGNING_KEY"),
+
+		StatusSubject: os.Getenv("NATS_STATUS_SUBJECT"),
+	}
+	if config.StatusSubject == "" {
+		config.StatusSubject = defaultStatusSubject
 	}
+	return config
+}
 
-	logger, _ := zap.NewProduction()
+// newProducer connects to NATS Streaming and sets up signing if a key is configured.
+func newProducer(config *producerConfig, logger *zap.Logger) *Producer {
 	producer := New(config.Cluster, config.Client, config.NatsURL, logger)
-	e := echo.New()
-	NewProducerHandler(e, producer, logger)
-
-	errStart := e.Start(config.ServerAddr)
-	if errStart != nil && !errors.Is(errStart, http.ErrServerClosed) {
-		logger.Fatal(errStart.Error())
+	producer.SetStatusSubject(config.StatusSubject)
+	if config.SigningKey != "" {
+		keys, err := signature.ParseKeys(config.SigningKey)
+		if err != nil || len(keys) != 1 {
+			logger.Fatal("Signing key must be a single id:secret pair", zap.Error(err))
+		}
+		for id, secret := range keys {
+			producer.SetSigner(signature.NewSigner(id, secret))
+		}
 	}
+	return producer
 }
 
 type Producer struct {
-	sc     stan.Conn
-	logger *zap.Logger
+	sc            stan.Conn
+	signer        *signature.Signer
+	statusSubject string
+	logger        *zap.Logger
 }
 
 func New(cluster string, clientID string, natsURL string, logger *zap.Logger) *Producer {
@@ -62,63 +101,124 @@ func New(cluster string, clientID string, natsURL string, logger *zap.Logger) *P
 	}
 
 	return &Producer{
-		sc:     sc,
-		logger: logger,
+		sc:            sc,
+		statusSubject: defaultStatusSubject,
+		logger:        logger,
 	}
 }
 
+// SetStatusSubject sets the subject SendStatus publishes status events to, it must match the
+// NATS_STATUS_SUBJECT of wborder.
+func (p *Producer) SetStatusSubject(subject string) {
+	p.statusSubject = subject
+}
+
+// SetSigner makes the producer sign every order it publishes.
+func (p *Producer) SetSigner(signer *signature.Signer) {
+	p.signer = signer
+}
+
+func (p *Producer) sign(order model.Order) (model.Order, error) {
+	if p.signer == nil {
+		return order, nil
+	}
+
+	var err error
+	order.InternalSignature, err = p.signer.Sign(order)
+	return order, err
+}
+
+// HeaderSeed reports the seed of generated orders, pass it back as ?seed= to replay them.
+const HeaderSeed = "X-Seed"
+
+// HeaderAnchor reports the time generated dates are relative to, pass it back as ?anchor= with
+// the seed to replay the same dates.
+const HeaderAnchor = "X-Anchor"
+
 type ProducerHandler struct {
-	producer *Producer
-	logger   *zap.Logger
-	e        *echo.Echo
+	producer  *Producer
+	logger    *zap.Logger
+	e         *echo.Echo
+	validator *validator.Validate
+	scenarios *scenarioRunner
 }
 
 func NewProducerHandler(e *echo.Echo, producer *Producer, logger *zap.Logger) *ProducerHandler {
 	handler := &ProducerHandler{
-		producer: producer,
-		logger:   logger,
-		e:        e,
+		producer:  producer,
+		logger:    logger,
+		e:         e,
+		validator: validator.New(),
+		scenarios: &scenarioRunner{runs: make(map[string]*scenarioRun)},
 	}
 
 	e.POST("/api/v1/producer/:msgCount", handler.Send)
 	e.POST("/api/v1/producer/validate-fail/:msgCount", handler.SendFail)
+	e.POST("/api/v1/producer/status/:orderID/:status", handler.SendStatus)
+	e.POST("/api/v1/producer/scenario", handler.StartScenario)
+	e.GET("/api/v1/producer/scenario/:id", handler.Scenario)
+	e.POST("/api/v1/producer/file", handler.SendFile)
 	return handler
 }
 
+// Send publishes valid generated orders.
 func (h *ProducerHandler) Send(c echo.Context) error {
-	msgCount := c.Param("msgCount")
-	count, err := strconv.Atoi(msgCount)
+	return h.sendGenerated(c, 0, 2_000)
+}
+
+// SendFail publishes generated orders with negative payment amounts.
+func (h *ProducerHandler) SendFail(c echo.Context) error {
+	return h.sendGenerated(c, -2_000, -1)
+}
+
+// maxMsgCount bounds :msgCount of generated orders published by one request.
+const maxMsgCount = 100_000
+
+func parseMsgCount(raw string) (int, error) {
+	count, err := strconv.Atoi(raw)
+	if err != nil || count <= 0 || count > maxMsgCount {
+		return 0, fmt.Errorf("message count must be between 1 and %d", maxMsgCount)
+	}
+	return count, nil
+}
+
+// sendGenerated publishes msgCount generated orders with payment amounts in the range. With
+// ?wait=true it waits up to ?timeout= for all acks and responds with a PublishSummary.
+func (h *ProducerHandler) sendGenerated(c echo.Context, minPay int, maxPay int) error {
+	count, err := parseMsgCount(c.Param("msgCount"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	ackHandler := func(ackedNuid string, err error) {
-		if err != nil {
-			h.logger.Error("Warning: error publishing msg id ", zap.String("nuid", ackedNuid), zap.Error(err))
-		} else {
-			h.logger.Info("Received ack for msg ", zap.String("nuid", ackedNuid))
-		}
+	orderCodec, err := codec.ByName(c.QueryParam("codec"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	for i := 0; i < count; i++ {
-		order := newFakeOrder(0, 2_000)
-		or, _ := json.Marshal(order)
-		_, err := h.producer.sc.PublishAsync("orders", or, ackHandler) // returns immediately
-		if err != nil {
-			h.logger.Error("Error publishing", zap.Error(err))
-			return c.JSON(http.StatusInternalServerError, err.Error())
-		}
+	compressionAlg := c.QueryParam("compression")
+	if !compression.Supported(compressionAlg) {
+		return c.JSON(http.StatusBadRequest, "unknown compression "+compressionAlg)
 	}
 
-	return c.NoContent(http.StatusOK)
-}
-
-func (h *ProducerHandler) SendFail(c echo.Context) error {
-	msgCount := c.Param("msgCount")
-	count, err := strconv.Atoi(msgCount)
+	seed, err := parseSeed(c.QueryParam("seed"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	anchor, err := parseAnchor(c.QueryParam("anchor"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
+	fakes := newGenerator(seed, anchor)
+	c.Response().Header().Set(HeaderSeed, strconv.FormatInt(fakes.Seed(), 10))
+	c.Response().Header().Set(HeaderAnchor, fakes.Anchor().Format(time.RFC3339))
+
+	if c.QueryParam("wait") == "true" {
+		timeout, err := parseTimeout(c.QueryParam("timeout"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return h.sendAndWait(c, count, timeout, func() model.Order { return fakes.Order(minPay, maxPay) }, orderCodec, compressionAlg)
+	}
 
 	ackHandler := func(ackedNuid string, err error) {
 		if err != nil {
@@ -129,9 +229,12 @@ func (h *ProducerHandler) SendFail(c echo.Context) error {
 	}
 
 	for i := 0; i < count; i++ {
-		order := newFakeOrder(-2_000, -1)
-		or, _ := json.Marshal(order)
-		_, err := h.producer.sc.PublishAsync("orders", or, ackHandler) // returns immediately
+		or, err := h.producer.encodeOrder(fakes.Order(minPay, maxPay), orderCodec, compressionAlg)
+		if err != nil {
+			h.logger.Error("Error encoding", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		_, err = h.producer.sc.PublishAsync("orders", or, ackHandler) // returns immediately
 		if err != nil {
 			h.logger.Error("Error publishing", zap.Error(err))
 			return c.JSON(http.StatusInternalServerError, err.Error())
@@ -141,94 +244,35 @@ func (h *ProducerHandler) SendFail(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
-func newFakeOrder(minPay int, maxPay int) model.Order {
-	faker := gofakeit.New(0)
-	trackNumber := faker.Word()
-
-	itemCount := faker.IntRange(1, 10)
-	items := make([]model.Item, 0, itemCount)
-	var totalSum int
-	for i := 0; i < itemCount; i++ {
-		item := newFakeItem(trackNumber)
-		totalSum += item.TotalPrice
-		items = append(items, item)
-	}
-
-	delivery := newFakeDelivery()
-
-	payment := newFakePayment(minPay, maxPay)
-	payment.GoodsTotal = &totalSum
-
-	dateTime := faker.DateRange(time.Now().AddDate(0, -6, 0), time.Now())
-	date := dateTime.Format("2006-01-02T15:04:05Z")
-
-	return model.Order{
-		OrderUID:          faker.UUID(),
-		TrackNumber:       trackNumber,
-		Entry:             trackNumber,
-		Delivery:          delivery,
-		Payment:           payment,
-		Items:             items,
-		Locale:            faker.LanguageAbbreviation(),
-		InternalSignature: "",
-		CustomerID:        faker.UUID(),
-		DeliveryService:   faker.Word(),
-		Shardkey:          strconv.Itoa(faker.IntRange(1, 9)),
-		SmID:              faker.IntRange(1, 99),
-		DateCreated:       date,
-		OofShard:          strconv.Itoa(faker.IntRange(1, 9)),
-	}
-}
-
-func newFakeItem(trackNumber string) model.Item {
-	faker := gofakeit.New(0)
-	price := faker.IntRange(1, 2000)
-	sale := faker.IntRange(1, 30)
-	totalPrice := price * (100 - sale) / 100
-	return model.Item{
-		ChrtID:      faker.IntRange(1111111, 9999999),
-		TrackNumber: trackNumber,
-		Price:       price,
-		Rid:         faker.UUID(),
-		Name:        faker.Word(),
-		Sale:        sale,
-		Size:        "0",
-		TotalPrice:  totalPrice,
-		NmID:        faker.IntRange(1111111, 9999999),
-		Brand:       faker.Word(),
-		Status:      202,
-	}
-}
-
-func newFakePayment(minPay int, maxPay int) model.Payment {
-	faker := gofakeit.New(0)
-	a := faker.IntRange(minPay, maxPay)
-	d := faker.IntRange(minPay, maxPay)
-	g := faker.IntRange(minPay, maxPay)
-	c := faker.IntRange(minPay, maxPay)
-	return model.Payment{
-		Transaction:  faker.Word(),
-		RequestID:    "",
-		Currency:     faker.Currency().Short,
-		Provider:     faker.Word(),
-		Amount:       &a,
-		PaymentDt:    faker.DateRange(time.Now().AddDate(0, -6, 0), time.Now()).Unix(),
-		Bank:         faker.RandomString([]string{"alpha", "sberbank", "sovcombank"}),
-		DeliveryCost: &d,
-		GoodsTotal:   &g,
-		CustomFee:    &c,
-	}
-}
-
-func newFakeDelivery() model.Delivery {
-	faker := gofakeit.New(0)
-	return model.Delivery{
-		Name:    faker.Name(),
-		Phone:   fmt.Sprintf("+%s", faker.Phone()),
-		Zip:     faker.Zip(),
-		City:    faker.City(),
-		Address: faker.Street(),
-		Region:  faker.State(),
-		Email:   faker.Email(),
+// SendStatus publishes an order status change event.
+func (h *ProducerHandler) SendStatus(c echo.Context) error {
+	event := model.StatusEvent{
+		OrderUID: c.Param("orderID"),
+		Status:   c.Param("status"),
+		Reason:   c.QueryParam("reason"),
+	}
+
+	data, _ := json.Marshal(event)
+	err := h.producer.sc.Publish(h.producer.statusSubject, data)
+	if err != nil {
+		h.logger.Error("Error publishing", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// encodeOrder signs the order if the producer has a signer, encodes it with the codec and compresses it.
+func (p *Producer) encodeOrder(order model.Order, orderCodec codec.Codec, compressionAlg string) ([]byte, error) {
+	order, err := p.sign(order)
+	if err != nil {
+		return nil, err
 	}
+
+	data, err := codec.Encode(orderCodec, order)
+	if err != nil {
+		return nil, err
+	}
+
+	return compression.Compress(compressionAlg, data)
 }
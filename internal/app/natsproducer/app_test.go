@@ -0,0 +1,33 @@
+package natsproducer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMsgCount(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{raw: "1", want: 1},
+		{raw: "100000", want: maxMsgCount},
+		{raw: "0", wantErr: true},
+		{raw: "-1", wantErr: true},
+		{raw: "100001", wantErr: true},
+		{raw: "ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			count, err := parseMsgCount(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, count)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package natsproducer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+// generator produces fake orders from one seeded faker, so a run with the same seed yields the
+// same orders in the same order. order_uid, payment transaction and chrt_id are built from
+// per-run counters and are unique within the run. Dates fall into the six months before the
+// anchor, so the seed together with the anchor reproduces a run. It is safe for concurrent use,
+// concurrent callers get the same orders but may interleave differently.
+type generator struct {
+	mu        sync.Mutex
+	seed      int64
+	anchor    time.Time
+	faker     *gofakeit.Faker
+	uidPrefix string
+	chrtBase  int64
+	orders    int64
+	items     int64
+}
+
+// newGenerator creates a generator, seed 0 picks a random seed reported by Seed and a zero
+// anchor dates orders relative to now, reported by Anchor.
+func newGenerator(seed int64, anchor time.Time) *generator {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if anchor.IsZero() {
+		anchor = time.Now().Truncate(time.Second)
+	}
+	anchor = anchor.UTC()
+
+	faker := gofakeit.New(seed)
+	return &generator{
+		seed:      seed,
+		anchor:    anchor,
+		faker:     faker,
+		uidPrefix: faker.LetterN(8),
+		chrtBase:  int64(faker.Uint32()) << 24,
+	}
+}
+
+// Seed returns the seed that reproduces the run.
+func (g *generator) Seed() int64 {
+	return g.seed
+}
+
+// Anchor returns the time generated dates are relative to.
+func (g *generator) Anchor() time.Time {
+	return g.anchor
+}
+
+// parseAnchor reads the anchor query parameter in RFC 3339, empty means now.
+func parseAnchor(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// parseSeed reads the seed query parameter, empty means random.
+func parseSeed(raw string) (int64, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+func (g *generator) Order(minPay int, maxPay int) model.Order {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.orders++
+	orderUID := fmt.Sprintf("%s%012x", g.uidPrefix, g.orders)
+	trackNumber := g.faker.Word()
+
+	itemCount := g.faker.IntRange(1, 10)
+	items := make([]model.Item, 0, itemCount)
+	var totalSum int
+	for i := 0; i < itemCount; i++ {
+		item := g.item(trackNumber)
+		totalSum += item.TotalPrice
+		items = append(items, item)
+	}
+
+	delivery := g.delivery()
+
+	payment := g.payment(minPay, maxPay)
+	payment.Transaction = orderUID
+	payment.GoodsTotal = &totalSum
+
+	dateTime := g.faker.DateRange(g.anchor.AddDate(0, -6, 0), g.anchor)
+	date := dateTime.Format("2006-01-02T15:04:05Z")
+
+	return model.Order{
+		OrderUID:          orderUID,
+		TrackNumber:       trackNumber,
+		Entry:             trackNumber,
+		Delivery:          delivery,
+		Payment:           payment,
+		Items:             items,
+		Locale:            g.faker.LanguageAbbreviation(),
+		InternalSignature: "",
+		CustomerID:        g.faker.UUID(),
+		DeliveryService:   g.faker.Word(),
+		Shardkey:          strconv.Itoa(g.faker.IntRange(1, 9)),
+		SmID:              g.faker.IntRange(1, 99),
+		DateCreated:       date,
+		OofShard:          strconv.Itoa(g.faker.IntRange(1, 9)),
+	}
+}
+
+// Rewrite gives a sample order fresh order_uid, track_number, payment transaction and chrt_id,
+// so it can be published again without colliding with the keys already stored by wborder.
+func (g *generator) Rewrite(order model.Order) model.Order {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.orders++
+	orderUID := fmt.Sprintf("%s%012x", g.uidPrefix, g.orders)
+	trackNumber := fmt.Sprintf("%s%012X", strings.ToUpper(g.uidPrefix), g.orders)
+
+	items := make([]model.Item, len(order.Items))
+	for i, item := range order.Items {
+		g.items++
+		item.ChrtID = int(g.chrtBase + g.items)
+		if item.TrackNumber == order.TrackNumber {
+			item.TrackNumber = trackNumber
+		}
+		items[i] = item
+	}
+
+	order.OrderUID, order.TrackNumber, order.Items = orderUID, trackNumber, items
+	order.Payment.Transaction = orderUID
+	return order
+}
+
+func (g *generator) Item(trackNumber string) model.Item {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.item(trackNumber)
+}
+
+func (g *generator) item(trackNumber string) model.Item {
+	g.items++
+	price := g.faker.IntRange(1, 2000)
+	sale := g.faker.IntRange(1, 30)
+	totalPrice := price * (100 - sale) / 100
+	return model.Item{
+		ChrtID:      int(g.chrtBase + g.items),
+		TrackNumber: trackNumber,
+		Price:       price,
+		Rid:         g.faker.UUID(),
+		Name:        g.faker.Word(),
+		Sale:        sale,
+		Size:        "0",
+		TotalPrice:  totalPrice,
+		NmID:        g.faker.IntRange(1111111, 9999999),
+		Brand:       g.faker.Word(),
+		Status:      202,
+	}
+}
+
+func (g *generator) payment(minPay int, maxPay int) model.Payment {
+	a := g.faker.IntRange(minPay, maxPay)
+	d := g.faker.IntRange(minPay, maxPay)
+	gt := g.faker.IntRange(minPay, maxPay)
+	c := g.faker.IntRange(minPay, maxPay)
+	return model.Payment{
+		RequestID:    "",
+		Currency:     g.faker.Currency().Short,
+		Provider:     g.faker.Word(),
+		Amount:       &a,
+		PaymentDt:    g.faker.DateRange(g.anchor.AddDate(0, -6, 0), g.anchor).Unix(),
+		Bank:         g.faker.RandomString([]string{"alpha", "sberbank", "sovcombank"}),
+		DeliveryCost: &d,
+		GoodsTotal:   &gt,
+		CustomFee:    &c,
+	}
+}
+
+func (g *generator) delivery() model.Delivery {
+	return model.Delivery{
+		Name:    g.faker.Name(),
+		Phone:   fmt.Sprintf("+%s", g.faker.Phone()),
+		Zip:     g.faker.Zip(),
+		City:    g.faker.City(),
+		Address: g.faker.Street(),
+		Region:  g.faker.State(),
+		Email:   g.faker.Email(),
+	}
+}
@@ -0,0 +1,62 @@
+package natsproducer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// anchor fixes generated dates, so runs with the same seed are equal.
+var anchor = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	a, b := newGenerator(42, anchor), newGenerator(42, anchor)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, a.Order(0, 2_000), b.Order(0, 2_000))
+	}
+	assert.NotEqual(t, newGenerator(1, anchor).Order(0, 2_000), newGenerator(2, anchor).Order(0, 2_000))
+	assert.NotZero(t, newGenerator(0, anchor).Seed())
+}
+
+func TestGeneratorKeysAreUnique(t *testing.T) {
+	g := newGenerator(7, anchor)
+	uids, transactions, chrtIDs := map[string]bool{}, map[string]bool{}, map[int]bool{}
+	validate := validator.New()
+
+	for i := 0; i < 1_000; i++ {
+		order := g.Order(0, 2_000)
+		require.NoError(t, validate.Struct(order))
+		assert.False(t, uids[order.OrderUID], order.OrderUID)
+		assert.False(t, transactions[order.Payment.Transaction], order.Payment.Transaction)
+		uids[order.OrderUID], transactions[order.Payment.Transaction] = true, true
+		for _, item := range order.Items {
+			assert.False(t, chrtIDs[item.ChrtID], item.ChrtID)
+			chrtIDs[item.ChrtID] = true
+		}
+	}
+}
+
+func TestGeneratorAnchor(t *testing.T) {
+	g := newGenerator(5, anchor)
+	for i := 0; i < 100; i++ {
+		created, err := time.Parse(time.RFC3339, g.Order(0, 2_000).DateCreated)
+		require.NoError(t, err)
+		assert.False(t, created.After(anchor), created)
+		assert.False(t, created.Before(anchor.AddDate(0, -6, 0)), created)
+	}
+
+	now := newGenerator(5, time.Time{})
+	assert.WithinDuration(t, time.Now(), now.Anchor(), time.Minute)
+	created, err := time.Parse(time.RFC3339, now.Order(0, 2_000).DateCreated)
+	require.NoError(t, err)
+	assert.True(t, created.After(time.Now().AddDate(0, -7, 0)), "dates follow the current time")
+
+	parsed, err := parseAnchor("2024-01-01T00:00:00Z")
+	require.NoError(t, err)
+	assert.Equal(t, anchor, parsed)
+	_, err = parseAnchor("yesterday")
+	assert.Error(t, err)
+}
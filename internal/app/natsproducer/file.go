@@ -0,0 +1,366 @@
+package natsproducer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/codec"
+	"github.com/msmkdenis/wb-order-nats/internal/compression"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+// FileOptions controls publishing of orders read from a file.
+type FileOptions struct {
+	Subject string
+	// Rate limits publishing to messages per second, zero publishes as fast as acks arrive.
+	Rate int
+	// Rewrite replaces order_uid, track_number, payment transaction and chrt_id with fresh ones.
+	Rewrite bool
+	// PublishInvalid publishes orders that fail validation instead of skipping them.
+	PublishInvalid bool
+	Codec          codec.Codec
+	Compression    string
+	Seed           int64
+}
+
+// FileLine is the outcome of one order of the file. Line is the line of an NDJSON file or the
+// position of the element in a JSON array, both starting at one.
+type FileLine struct {
+	Line         int    `json:"line"`
+	OrderUID     string `json:"order_uid,omitempty"`
+	Valid        bool   `json:"valid"`
+	Error        string `json:"error,omitempty"`
+	Published    bool   `json:"published"`
+	PublishError string `json:"publish_error,omitempty"`
+}
+
+// FileReport summarises a file publish, Error is set if the file could not be read to the end.
+type FileReport struct {
+	Seed      int64      `json:"seed,omitempty"`
+	Total     int        `json:"total"`
+	Valid     int        `json:"valid"`
+	Invalid   int        `json:"invalid"`
+	Published int        `json:"published"`
+	Failed    int        `json:"failed"`
+	Error     string     `json:"error,omitempty"`
+	Lines     []FileLine `json:"lines"`
+}
+
+// readOrders calls fn for every order of a JSON array or NDJSON stream. Broken NDJSON lines are
+// passed to fn with the error, a broken array can not be read further and stops with an error.
+func readOrders(r io.Reader, fn func(line int, raw []byte, err error) bool) error {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !isSpace(b) {
+			_ = br.UnreadByte()
+			if b == '[' {
+				return readArray(br, fn)
+			}
+			return readLines(br, fn)
+		}
+	}
+}
+
+func readArray(r io.Reader, fn func(line int, raw []byte, err error) bool) error {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	for line := 1; dec.More(); line++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("element %d: %w", line, err)
+		}
+		if !fn(line, raw, nil) {
+			return nil
+		}
+	}
+
+	_, err := dec.Token()
+	return err
+}
+
+func readLines(r *bufio.Reader, fn func(line int, raw []byte, err error) bool) error {
+	for line := 1; ; line++ {
+		raw, err := r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 {
+			var lineErr error
+			if !json.Valid(raw) {
+				lineErr = errors.New("invalid JSON")
+			}
+			if !fn(line, raw, lineErr) {
+				return nil
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// PublishFile validates and publishes every order of the JSON array or NDJSON stream, waiting
+// for the ack of each message. It stops early if ctx is cancelled.
+func (p *Producer) PublishFile(ctx context.Context, r io.Reader, opts FileOptions, validate *validator.Validate) (*FileReport, error) {
+	var fakes *generator
+	report := &FileReport{Lines: []FileLine{}}
+	if opts.Rewrite {
+		fakes = newGenerator(opts.Seed, time.Time{})
+		report.Seed = fakes.Seed()
+	}
+
+	var tick <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	err := readOrders(r, func(line int, raw []byte, err error) bool {
+		result := p.publishLine(raw, err, opts, validate, fakes, tick)
+		result.Line = line
+		report.add(result)
+		return ctx.Err() == nil
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report, err
+}
+
+func (p *Producer) publishLine(raw []byte, err error, opts FileOptions, validate *validator.Validate, fakes *generator, tick <-chan time.Time) FileLine {
+	var result FileLine
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	var order model.Order
+	err = json.Unmarshal(raw, &order)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.OrderUID = order.OrderUID
+
+	err = validate.Struct(order)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Valid = true
+	}
+	if !result.Valid && !opts.PublishInvalid {
+		return result
+	}
+
+	if fakes != nil {
+		order = fakes.Rewrite(order)
+		result.OrderUID = order.OrderUID
+	}
+
+	data, err := p.encodeOrder(order, opts.Codec, opts.Compression)
+	if err != nil {
+		result.PublishError = err.Error()
+		return result
+	}
+
+	if tick != nil {
+		<-tick
+	}
+	err = p.sc.Publish(opts.Subject, data)
+	if err != nil {
+		result.PublishError = err.Error()
+		return result
+	}
+
+	result.Published = true
+	return result
+}
+
+func (r *FileReport) add(line FileLine) {
+	r.Total++
+	if line.Valid {
+		r.Valid++
+	} else {
+		r.Invalid++
+	}
+	if line.Published {
+		r.Published++
+	}
+	if line.PublishError != "" {
+		r.Failed++
+	}
+	r.Lines = append(r.Lines, line)
+}
+
+// SendFile publishes orders of the request body, a JSON array or NDJSON, or of the uploaded "file" form field.
+func (h *ProducerHandler) SendFile(c echo.Context) error {
+	opts, err := fileOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	body := c.Request().Body
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		file, err := header.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		defer file.Close()
+		body = file
+	}
+
+	report, err := h.producer.PublishFile(c.Request().Context(), body, opts, h.validator)
+	if err != nil {
+		h.logger.Error("Error reading orders file", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, report)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+func fileOptions(c echo.Context) (FileOptions, error) {
+	opts := FileOptions{
+		Subject:     c.QueryParam("subject"),
+		Compression: c.QueryParam("compression"),
+	}
+	if opts.Subject == "" {
+		opts.Subject = "orders"
+	}
+
+	var err error
+	opts.Codec, err = codec.ByName(c.QueryParam("codec"))
+	if err != nil {
+		return opts, err
+	}
+	if !compression.Supported(opts.Compression) {
+		return opts, errors.New("unknown compression " + opts.Compression)
+	}
+
+	opts.Seed, err = parseSeed(c.QueryParam("seed"))
+	if err != nil {
+		return opts, err
+	}
+
+	if raw := c.QueryParam("rate"); raw != "" {
+		opts.Rate, err = strconv.Atoi(raw)
+		if err != nil || opts.Rate < 0 {
+			return opts, fmt.Errorf("invalid rate %q", raw)
+		}
+	}
+
+	opts.Rewrite = c.QueryParam("rewrite") == "true"
+	opts.PublishInvalid = c.QueryParam("publish_invalid") == "true"
+	return opts, nil
+}
+
+// PublishFileCommand runs the publish-file CLI mode: it publishes the orders of a file, or of
+// stdin for "-", prints the report as JSON and returns the exit code.
+func PublishFileCommand(args []string) int {
+	flags := flag.NewFlagSet("publish-file", flag.ContinueOnError)
+	subject := flags.String("subject", "orders", "subject to publish to")
+	rate := flags.Int("rate", 0, "messages per second, 0 means unlimited")
+	rewrite := flags.Bool("rewrite", false, "rewrite order_uid, track_number, transaction and chrt_id")
+	publishInvalid := flags.Bool("publish-invalid", false, "publish orders that fail validation")
+	codecName := flags.String("codec", "", "order codec")
+	compressionAlg := flags.String("compression", "", "compression algorithm")
+	seed := flags.Int64("seed", 0, "seed of rewritten keys, 0 means random")
+	client := flags.String("client", "", "NATS Streaming client id, defaults to the configured one with a -file suffix")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: natsproducer publish-file [flags] <file.json|file.ndjson|->")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || *rate < 0 {
+		flags.Usage()
+		return 2
+	}
+
+	orderCodec, err := codec.ByName(*codecName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if !compression.Supported(*compressionAlg) {
+		fmt.Fprintln(os.Stderr, "unknown compression "+*compressionAlg)
+		return 2
+	}
+
+	input := os.Stdin
+	if path := flags.Arg(0); path != "-" {
+		input, err = os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer input.Close()
+	}
+
+	config := loadConfig()
+	config.Client += "-file"
+	if *client != "" {
+		config.Client = *client
+	}
+	logger, _ := zap.NewProduction()
+	producer := newProducer(config, logger)
+	defer producer.sc.Close()
+
+	report, err := producer.PublishFile(context.Background(), input, FileOptions{
+		Subject:        *subject,
+		Rate:           *rate,
+		Rewrite:        *rewrite,
+		PublishInvalid: *publishInvalid,
+		Codec:          orderCodec,
+		Compression:    *compressionAlg,
+		Seed:           *seed,
+	}, validator.New())
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	_ = out.Encode(report)
+	if err != nil || report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
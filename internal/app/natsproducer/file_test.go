@@ -0,0 +1,62 @@
+package natsproducer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readLine struct {
+	line int
+	raw  string
+	err  bool
+}
+
+func collect(t *testing.T, input string) ([]readLine, error) {
+	t.Helper()
+	var lines []readLine
+	err := readOrders(strings.NewReader(input), func(line int, raw []byte, err error) bool {
+		lines = append(lines, readLine{line: line, raw: string(raw), err: err != nil})
+		return true
+	})
+	return lines, err
+}
+
+func TestReadOrdersNDJSON(t *testing.T) {
+	lines, err := collect(t, "{\"order_uid\":\"a\"}\n\n{broken\n  {\"order_uid\":\"b\"}")
+	require.NoError(t, err)
+	assert.Equal(t, []readLine{
+		{line: 1, raw: `{"order_uid":"a"}`},
+		{line: 3, raw: `{broken`, err: true},
+		{line: 4, raw: `{"order_uid":"b"}`},
+	}, lines)
+}
+
+func TestReadOrdersArray(t *testing.T) {
+	lines, err := collect(t, "\n [{\"order_uid\":\"a\"},\n{\"order_uid\":\"b\"}]")
+	require.NoError(t, err)
+	assert.Equal(t, []readLine{
+		{line: 1, raw: `{"order_uid":"a"}`},
+		{line: 2, raw: `{"order_uid":"b"}`},
+	}, lines)
+
+	lines, err = collect(t, `[{"order_uid":"a"}, {broken`)
+	assert.Error(t, err)
+	assert.Len(t, lines, 1)
+}
+
+func TestGeneratorRewrite(t *testing.T) {
+	g := newGenerator(3, anchor)
+	sample := newGenerator(4, anchor).Order(0, 2_000)
+
+	first, second := g.Rewrite(sample), g.Rewrite(sample)
+	assert.NotEqual(t, sample.OrderUID, first.OrderUID)
+	assert.NotEqual(t, first.OrderUID, second.OrderUID)
+	assert.NotEqual(t, first.TrackNumber, second.TrackNumber)
+	assert.Equal(t, first.OrderUID, first.Payment.Transaction)
+	assert.NotEqual(t, first.Items[0].ChrtID, second.Items[0].ChrtID)
+	assert.Equal(t, first.TrackNumber, first.Items[0].TrackNumber)
+	assert.Equal(t, sample.Items[0].ChrtID, newGenerator(4, anchor).Order(0, 2_000).Items[0].ChrtID, "sample must not be modified")
+}
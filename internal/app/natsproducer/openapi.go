@@ -0,0 +1,67 @@
+package natsproducer
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/openapi"
+)
+
+// OpenAPI describes the natsproducer API, every route of ProducerHandler must be described here.
+func OpenAPI() *openapi.Document {
+	d := openapi.New("natsproducer", "1.0.0", "Publishes generated orders and status events to NATS Streaming.")
+	tags := []string{"producer"}
+	encoding := []openapi.Parameter{
+		{Name: "codec", In: "query", Description: "Message format, json by default", Schema: &openapi.Schema{Type: "string", Enum: []any{"json", "protobuf", "msgpack"}}},
+		{Name: "compression", In: "query", Description: "Message compression, none by default", Schema: &openapi.Schema{Type: "string", Enum: []any{"gzip", "zstd"}}},
+		{Name: "seed", In: "query", Description: "Seed of generated orders, random by default and returned in the X-Seed header", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+	}
+	errors := []int{http.StatusBadRequest, http.StatusInternalServerError}
+
+	generated := append(slices.Clip(encoding),
+		openapi.Parameter{Name: "wait", In: "query", Description: "Wait for all acks and respond with a summary, 502 unless every message was acked", Schema: &openapi.Schema{Type: "boolean"}},
+		openapi.Parameter{Name: "timeout", In: "query", Description: "Ack wait timeout with wait=true, 30s by default and 5m at most", Schema: &openapi.Schema{Type: "string"}},
+		openapi.Parameter{Name: "anchor", In: "query", Description: "RFC 3339 time order dates are generated before, now by default and returned in the X-Anchor header", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+	)
+	generatedErrors := append(slices.Clip(errors), http.StatusBadGateway)
+
+	d.Add(http.MethodPost, "/api/v1/producer/:msgCount", openapi.Operation{
+		Summary: "Publish valid orders", Description: "Responds before acks arrive unless wait=true.",
+		Tags: tags, Query: generated, Response: PublishSummary{}, Errors: generatedErrors,
+	})
+	d.Add(http.MethodPost, "/api/v1/producer/validate-fail/:msgCount", openapi.Operation{
+		Summary: "Publish orders failing validation", Description: "Payment amounts are negative. Responds before acks arrive unless wait=true.",
+		Tags: tags, Query: generated, Response: PublishSummary{}, Errors: generatedErrors,
+	})
+	d.Add(http.MethodPost, "/api/v1/producer/status/:orderID/:status", openapi.Operation{
+		Summary: "Publish an order status event", Tags: tags,
+		Query:  []openapi.Parameter{{Name: "reason", In: "query", Schema: &openapi.Schema{Type: "string"}}},
+		Errors: []int{http.StatusInternalServerError},
+	})
+	d.Add(http.MethodPost, "/api/v1/producer/scenario", openapi.Operation{
+		Summary:     "Start a load scenario",
+		Description: "Publishes at `rate` messages per second for `duration` after a linear `ramp_up`, mixing in broken messages by `failures` percentages. Poll the returned id for the report.",
+		Tags:        tags, Request: Scenario{}, Status: http.StatusAccepted, Response: ScenarioReport{}, Errors: []int{http.StatusBadRequest},
+	})
+	d.Add(http.MethodGet, "/api/v1/producer/scenario/:id", openapi.Operation{
+		Summary: "Scenario report", Description: "Latency percentiles are measured from publish to ack.",
+		Tags: tags, Response: ScenarioReport{}, Errors: []int{http.StatusNotFound},
+	})
+	d.Add(http.MethodPost, "/api/v1/producer/file", openapi.Operation{
+		Summary: "Publish orders from a file",
+		Description: "The body is a JSON array or NDJSON of orders, or a multipart form with a `file` field. Invalid orders are skipped unless `publish_invalid` is set, " +
+			"`rewrite` gives every order fresh keys. Each message waits for its ack, the report lists the outcome of every line.",
+		Tags: tags,
+		Query: append(encoding[:2:2],
+			openapi.Parameter{Name: "seed", In: "query", Description: "Seed of rewritten keys, random by default and returned in the report", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+			openapi.Parameter{Name: "subject", In: "query", Description: "Subject to publish to, orders by default", Schema: &openapi.Schema{Type: "string"}},
+			openapi.Parameter{Name: "rate", In: "query", Description: "Messages per second, unlimited by default", Schema: &openapi.Schema{Type: "integer"}},
+			openapi.Parameter{Name: "rewrite", In: "query", Description: "Rewrite order_uid, track_number, payment transaction and chrt_id", Schema: &openapi.Schema{Type: "boolean"}},
+			openapi.Parameter{Name: "publish_invalid", In: "query", Description: "Publish orders failing validation", Schema: &openapi.Schema{Type: "boolean"}},
+		),
+		Request: []model.Order{}, Response: FileReport{}, Errors: []int{http.StatusBadRequest},
+	})
+
+	return d
+}
@@ -0,0 +1,20 @@
+package natsproducer
+
+import (
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/openapi"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	e := echo.New()
+	NewProducerHandler(e, nil, zap.NewNop())
+	doc := OpenAPI()
+	openapi.Serve(e, doc)
+
+	assert.Empty(t, doc.Undocumented(openapi.Routes(e)), "routes missing from natsproducer.OpenAPI()")
+}
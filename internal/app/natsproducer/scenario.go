@@ -0,0 +1,385 @@
+package natsproducer
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/codec"
+	"github.com/msmkdenis/wb-order-nats/internal/compression"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+// Kinds of messages a scenario publishes.
+const (
+	KindValid          = "valid"
+	KindBadJSON        = "bad_json"
+	KindMissingFields  = "missing_fields"
+	KindNegativeAmount = "negative_amount"
+	KindDuplicate      = "duplicate"
+	KindOversizeItems  = "oversize_items"
+)
+
+const (
+	scenarioTick = 10 * time.Millisecond
+	// ackTimeout bounds the wait for outstanding acks once a scenario stopped publishing.
+	ackTimeout    = 30 * time.Second
+	oversizeItems = 1_000
+)
+
+// Scenario describes a load run: messages are published at Rate per second after a linear
+// RampUp from zero, for Duration in total, by Concurrency publishers.
+type Scenario struct {
+	Subject     string     `json:"subject"`
+	Seed        int64      `json:"seed"`
+	Anchor      time.Time  `json:"anchor"`
+	Rate        int        `json:"rate" validate:"required,gt=0"`
+	Duration    string     `json:"duration" validate:"required"`
+	RampUp      string     `json:"ramp_up"`
+	Concurrency int        `json:"concurrency" validate:"gte=0"`
+	Codec       string     `json:"codec"`
+	Compression string     `json:"compression"`
+	Failures    FailureMix `json:"failures"`
+}
+
+// FailureMix holds percentages of broken messages, the rest are valid orders.
+type FailureMix struct {
+	BadJSON        float64 `json:"bad_json" validate:"gte=0,lte=100"`
+	MissingFields  float64 `json:"missing_fields" validate:"gte=0,lte=100"`
+	NegativeAmount float64 `json:"negative_amount" validate:"gte=0,lte=100"`
+	Duplicates     float64 `json:"duplicates" validate:"gte=0,lte=100"`
+	OversizeItems  float64 `json:"oversize_items" validate:"gte=0,lte=100"`
+}
+
+func (f FailureMix) total() float64 {
+	return f.BadJSON + f.MissingFields + f.NegativeAmount + f.Duplicates + f.OversizeItems
+}
+
+// pick returns the kind of the message for a uniformly distributed roll in [0, 100).
+func (f FailureMix) pick(roll float64) string {
+	for _, k := range []struct {
+		kind    string
+		percent float64
+	}{
+		{KindBadJSON, f.BadJSON},
+		{KindMissingFields, f.MissingFields},
+		{KindNegativeAmount, f.NegativeAmount},
+		{KindDuplicate, f.Duplicates},
+		{KindOversizeItems, f.OversizeItems},
+	} {
+		if roll < k.percent {
+			return k.kind
+		}
+		roll -= k.percent
+	}
+	return KindValid
+}
+
+// Latency holds publish to ack latency percentiles in milliseconds.
+type Latency struct {
+	P50 float64 `json:"p50_ms"`
+	P90 float64 `json:"p90_ms"`
+	P99 float64 `json:"p99_ms"`
+	Max float64 `json:"max_ms"`
+}
+
+// ScenarioReport is the state of a scenario run, final once Status is finished.
+type ScenarioReport struct {
+	ID            string           `json:"id"`
+	Status        string           `json:"status"`
+	Scenario      Scenario         `json:"scenario"`
+	StartedAt     time.Time        `json:"started_at"`
+	FinishedAt    *time.Time       `json:"finished_at,omitempty"`
+	Published     int64            `json:"published"`
+	PublishErrors int64            `json:"publish_errors"`
+	Acked         int64            `json:"acked"`
+	AckErrors     int64            `json:"ack_errors"`
+	Unacked       int64            `json:"unacked"`
+	ByKind        map[string]int64 `json:"by_kind"`
+	Latency       Latency          `json:"latency"`
+}
+
+// Scenario run statuses.
+const (
+	ScenarioRunning  = "running"
+	ScenarioFinished = "finished"
+)
+
+type scenarioRun struct {
+	mu        sync.Mutex
+	report    ScenarioReport
+	latencies []time.Duration
+	pending   sync.WaitGroup
+	inflight  atomic.Int64
+}
+
+func (r *scenarioRun) count(f func(report *ScenarioReport)) {
+	r.mu.Lock()
+	f(&r.report)
+	r.mu.Unlock()
+}
+
+func (r *scenarioRun) snapshot() ScenarioReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := r.report
+	report.ByKind = make(map[string]int64, len(r.report.ByKind))
+	for k, v := range r.report.ByKind {
+		report.ByKind[k] = v
+	}
+	report.Unacked = r.inflight.Load()
+	report.Latency = percentiles(r.latencies)
+	return report
+}
+
+// percentiles uses the nearest-rank method.
+func percentiles(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	rank := func(p float64) float64 {
+		i := int(p*float64(len(sorted))+0.999999) - 1
+		return float64(sorted[max(i, 0)]) / float64(time.Millisecond)
+	}
+	return Latency{P50: rank(0.5), P90: rank(0.9), P99: rank(0.99), Max: rank(1)}
+}
+
+// target returns how many messages should have been published after elapsed time.
+func target(rate float64, rampUp time.Duration, elapsed time.Duration) int64 {
+	t, r := elapsed.Seconds(), rampUp.Seconds()
+	if t < r {
+		return int64(rate * t * t / (2 * r))
+	}
+	return int64(rate*r/2 + rate*(t-r))
+}
+
+type scenarioRunner struct {
+	mu     sync.Mutex
+	runs   map[string]*scenarioRun
+	nextID atomic.Int64
+}
+
+// StartScenario validates the scenario and runs it in the background, the report is polled by id.
+func (h *ProducerHandler) StartScenario(c echo.Context) error {
+	var s Scenario
+	err := c.Bind(&s)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.validator.Struct(s)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	duration, rampUp, err := s.durations()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	orderCodec, err := codec.ByName(s.Codec)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if !compression.Supported(s.Compression) {
+		return c.JSON(http.StatusBadRequest, "unknown compression "+s.Compression)
+	}
+
+	if s.Subject == "" {
+		s.Subject = "orders"
+	}
+	if s.Concurrency == 0 {
+		s.Concurrency = 1
+	}
+	fakes := newGenerator(s.Seed, s.Anchor)
+	s.Seed, s.Anchor = fakes.Seed(), fakes.Anchor()
+
+	id := strconv.FormatInt(h.scenarios.nextID.Add(1), 10)
+	run := &scenarioRun{report: ScenarioReport{
+		ID:        id,
+		Status:    ScenarioRunning,
+		Scenario:  s,
+		StartedAt: time.Now().UTC(),
+		ByKind:    make(map[string]int64),
+	}}
+	h.scenarios.mu.Lock()
+	h.scenarios.runs[id] = run
+	h.scenarios.mu.Unlock()
+
+	go h.runScenario(run, s, duration, rampUp, orderCodec, fakes)
+
+	return c.JSON(http.StatusAccepted, run.snapshot())
+}
+
+func (s Scenario) durations() (time.Duration, time.Duration, error) {
+	duration, err := time.ParseDuration(s.Duration)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid duration: %w", err)
+	}
+
+	var rampUp time.Duration
+	if s.RampUp != "" {
+		rampUp, err = time.ParseDuration(s.RampUp)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid ramp_up: %w", err)
+		}
+	}
+
+	switch {
+	case duration <= 0:
+		return 0, 0, errors.New("duration must be positive")
+	case rampUp < 0 || rampUp > duration:
+		return 0, 0, errors.New("ramp_up must be between zero and duration")
+	case s.Failures.total() > 100:
+		return 0, 0, errors.New("failure percentages exceed 100")
+	}
+	return duration, rampUp, nil
+}
+
+// Scenario returns the report of a scenario run.
+func (h *ProducerHandler) Scenario(c echo.Context) error {
+	h.scenarios.mu.Lock()
+	run, ok := h.scenarios.runs[c.Param("id")]
+	h.scenarios.mu.Unlock()
+	if !ok {
+		return c.JSON(http.StatusNotFound, "scenario not found")
+	}
+
+	return c.JSON(http.StatusOK, run.snapshot())
+}
+
+func (h *ProducerHandler) runScenario(run *scenarioRun, s Scenario, duration time.Duration, rampUp time.Duration, orderCodec codec.Codec, fakes *generator) {
+	jobs := make(chan string, s.Concurrency)
+	workers := sync.WaitGroup{}
+	for i := 0; i < s.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			var last []byte
+			for kind := range jobs {
+				last = h.publishScenarioMessage(run, s, kind, last, orderCodec, fakes)
+			}
+		}()
+	}
+
+	roll := rand.New(rand.NewSource(s.Seed)) //nolint:gosec
+	start := time.Now()
+	ticker := time.NewTicker(scenarioTick)
+	var sent int64
+	for now := range ticker.C {
+		elapsed := min(now.Sub(start), duration)
+		for due := target(float64(s.Rate), rampUp, elapsed); sent < due; sent++ {
+			jobs <- s.Failures.pick(roll.Float64() * 100)
+		}
+		if elapsed >= duration {
+			break
+		}
+	}
+	ticker.Stop()
+	close(jobs)
+	workers.Wait()
+
+	acked := make(chan struct{})
+	go func() {
+		run.pending.Wait()
+		close(acked)
+	}()
+	select {
+	case <-acked:
+	case <-time.After(ackTimeout):
+		h.logger.Warn("scenario finished with unacked messages", zap.String("id", run.report.ID), zap.Int64("unacked", run.inflight.Load()))
+	}
+
+	run.count(func(report *ScenarioReport) {
+		finished := time.Now().UTC()
+		report.FinishedAt = &finished
+		report.Status = ScenarioFinished
+	})
+	report := run.snapshot()
+	h.logger.Info("scenario finished", zap.String("id", report.ID), zap.Int64("published", report.Published),
+		zap.Int64("ack_errors", report.AckErrors), zap.Float64("p99_ms", report.Latency.P99))
+}
+
+// publishScenarioMessage publishes a message of the kind and returns the last valid message for duplicates.
+func (h *ProducerHandler) publishScenarioMessage(run *scenarioRun, s Scenario, kind string, last []byte, orderCodec codec.Codec, fakes *generator) []byte {
+	data, err := h.scenarioMessage(kind, last, orderCodec, s.Compression, fakes)
+	if err != nil {
+		h.logger.Error("Error encoding", zap.Error(err))
+		run.count(func(report *ScenarioReport) { report.PublishErrors++ })
+		return last
+	}
+	if kind == KindValid {
+		last = data
+	}
+
+	start := time.Now()
+	run.pending.Add(1)
+	run.inflight.Add(1)
+	_, err = h.producer.sc.PublishAsync(s.Subject, data, func(_ string, err error) {
+		latency := time.Since(start)
+		run.count(func(report *ScenarioReport) {
+			if err != nil {
+				report.AckErrors++
+				return
+			}
+			report.Acked++
+		})
+		run.mu.Lock()
+		run.latencies = append(run.latencies, latency)
+		run.mu.Unlock()
+		run.inflight.Add(-1)
+		run.pending.Done()
+	})
+	if err != nil {
+		run.inflight.Add(-1)
+		run.pending.Done()
+		run.count(func(report *ScenarioReport) { report.PublishErrors++ })
+		return last
+	}
+
+	run.count(func(report *ScenarioReport) {
+		report.Published++
+		report.ByKind[kind]++
+	})
+	return last
+}
+
+func (h *ProducerHandler) scenarioMessage(kind string, last []byte, orderCodec codec.Codec, compressionAlg string, fakes *generator) ([]byte, error) {
+	var order model.Order
+	switch kind {
+	case KindBadJSON:
+		return []byte(`{"order_uid": "broken", "items": [`), nil
+	case KindDuplicate:
+		if last != nil {
+			return last, nil
+		}
+		order = fakes.Order(0, 2_000)
+	case KindMissingFields:
+		order = fakes.Order(0, 2_000)
+		order.TrackNumber, order.CustomerID = "", ""
+	case KindNegativeAmount:
+		order = fakes.Order(-2_000, -1)
+	case KindOversizeItems:
+		order = fakes.Order(0, 2_000)
+		for len(order.Items) < oversizeItems {
+			order.Items = append(order.Items, fakes.Item(order.TrackNumber))
+		}
+	default:
+		order = fakes.Order(0, 2_000)
+	}
+
+	return h.producer.encodeOrder(order, orderCodec, compressionAlg)
+}
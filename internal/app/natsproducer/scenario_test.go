@@ -0,0 +1,35 @@
+package natsproducer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarget(t *testing.T) {
+	assert.Equal(t, int64(0), target(100, 10*time.Second, 0))
+	assert.Equal(t, int64(125), target(100, 10*time.Second, 5*time.Second))
+	assert.Equal(t, int64(500), target(100, 10*time.Second, 10*time.Second))
+	assert.Equal(t, int64(1500), target(100, 10*time.Second, 20*time.Second))
+	assert.Equal(t, int64(300), target(100, 0, 3*time.Second))
+}
+
+func TestFailureMixPick(t *testing.T) {
+	mix := FailureMix{BadJSON: 10, Duplicates: 5}
+	assert.Equal(t, KindBadJSON, mix.pick(0))
+	assert.Equal(t, KindBadJSON, mix.pick(9.9))
+	assert.Equal(t, KindDuplicate, mix.pick(12))
+	assert.Equal(t, KindValid, mix.pick(15))
+	assert.Equal(t, KindValid, mix.pick(99.9))
+}
+
+func TestPercentiles(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, Latency{P50: 50, P90: 90, P99: 99, Max: 100}, percentiles(latencies))
+	assert.Equal(t, Latency{}, percentiles(nil))
+}
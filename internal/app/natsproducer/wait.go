@@ -0,0 +1,149 @@
+package natsproducer
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nats-io/stan.go"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/codec"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+// maxWaitTimeout bounds ?timeout= of synchronous publishing.
+const maxWaitTimeout = 5 * time.Minute
+
+// PublishSummary is the outcome of a synchronous publish. Pending counts messages without an
+// ack when the timeout expired, OrderUIDs lists every published order in publish order.
+type PublishSummary struct {
+	Published int              `json:"published"`
+	Acked     int              `json:"acked"`
+	Failed    int              `json:"failed"`
+	Pending   int              `json:"pending"`
+	TimedOut  bool             `json:"timed_out"`
+	Failures  []PublishFailure `json:"failures"`
+	OrderUIDs []string         `json:"order_uids"`
+}
+
+// PublishFailure describes a message that was not published or was not acked, NUID is empty
+// if publishing itself failed.
+type PublishFailure struct {
+	NUID     string `json:"nuid,omitempty"`
+	OrderUID string `json:"order_uid"`
+	Error    string `json:"error"`
+}
+
+// ackCollector counts acks of asynchronously published messages, acks arriving after the
+// timeout still update it but are no longer reported.
+type ackCollector struct {
+	mu      sync.Mutex
+	summary PublishSummary
+	pending sync.WaitGroup
+}
+
+func (a *ackCollector) handler(orderUID string) stan.AckHandler {
+	return func(nuid string, err error) {
+		a.mu.Lock()
+		if err != nil {
+			a.summary.Failed++
+			a.summary.Failures = append(a.summary.Failures, PublishFailure{NUID: nuid, OrderUID: orderUID, Error: err.Error()})
+		} else {
+			a.summary.Acked++
+		}
+		a.mu.Unlock()
+		a.pending.Done()
+	}
+}
+
+// wait blocks until every published message is acked or the timeout expires.
+func (a *ackCollector) wait(timeout time.Duration) PublishSummary {
+	acked := make(chan struct{})
+	go func() {
+		a.pending.Wait()
+		close(acked)
+	}()
+
+	timedOut := false
+	select {
+	case <-acked:
+	case <-time.After(timeout):
+		timedOut = true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	summary := a.summary
+	summary.Failures = slices.Clone(a.summary.Failures)
+	summary.OrderUIDs = slices.Clone(a.summary.OrderUIDs)
+	summary.Pending = summary.Published - summary.Acked - summary.Failed + countUnpublished(summary.Failures)
+	summary.TimedOut = timedOut && summary.Pending > 0
+	return summary
+}
+
+// countUnpublished counts failures of messages that never reached the server and thus were not pending.
+func countUnpublished(failures []PublishFailure) int {
+	var n int
+	for _, f := range failures {
+		if f.NUID == "" {
+			n++
+		}
+	}
+	return n
+}
+
+func parseTimeout(raw string) (time.Duration, error) {
+	if raw == "" {
+		return ackTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout <= 0 || timeout > maxWaitTimeout {
+		return 0, fmt.Errorf("timeout must be a duration up to %s", maxWaitTimeout)
+	}
+	return timeout, nil
+}
+
+// sendAndWait publishes count orders and waits for their acks. It responds 200 if every message
+// was acked and 502 otherwise, both with the summary.
+func (h *ProducerHandler) sendAndWait(c echo.Context, count int, timeout time.Duration, next func() model.Order, orderCodec codec.Codec, compressionAlg string) error {
+	acks := &ackCollector{summary: PublishSummary{Failures: []PublishFailure{}, OrderUIDs: make([]string, 0, count)}}
+
+	for i := 0; i < count; i++ {
+		order := next()
+		data, err := h.producer.encodeOrder(order, orderCodec, compressionAlg)
+		if err != nil {
+			h.logger.Error("Error encoding", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+
+		acks.pending.Add(1)
+		_, err = h.producer.sc.PublishAsync("orders", data, acks.handler(order.OrderUID))
+		if err != nil {
+			acks.pending.Done()
+			h.logger.Error("Error publishing", zap.Error(err))
+			acks.mu.Lock()
+			acks.summary.Failed++
+			acks.summary.Failures = append(acks.summary.Failures, PublishFailure{OrderUID: order.OrderUID, Error: err.Error()})
+			acks.mu.Unlock()
+			break
+		}
+
+		acks.mu.Lock()
+		acks.summary.Published++
+		acks.summary.OrderUIDs = append(acks.summary.OrderUIDs, order.OrderUID)
+		acks.mu.Unlock()
+	}
+
+	summary := acks.wait(timeout)
+	if summary.Failed > 0 || summary.Pending > 0 {
+		h.logger.Warn("Not every message was acked", zap.Int("published", summary.Published),
+			zap.Int("acked", summary.Acked), zap.Int("failed", summary.Failed), zap.Int("pending", summary.Pending))
+		return c.JSON(http.StatusBadGateway, summary)
+	}
+	return c.JSON(http.StatusOK, summary)
+}
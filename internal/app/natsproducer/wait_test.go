@@ -0,0 +1,33 @@
+package natsproducer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAckCollectorWait(t *testing.T) {
+	acks := &ackCollector{}
+	for _, uid := range []string{"a", "b", "c"} {
+		acks.pending.Add(1)
+		acks.summary.Published++
+		acks.summary.OrderUIDs = append(acks.summary.OrderUIDs, uid)
+	}
+
+	acks.handler("a")("nuid-a", nil)
+	acks.handler("b")("nuid-b", errors.New("timeout"))
+	summary := acks.wait(10 * time.Millisecond)
+	assert.Equal(t, PublishSummary{
+		Published: 3, Acked: 1, Failed: 1, Pending: 1, TimedOut: true,
+		Failures:  []PublishFailure{{NUID: "nuid-b", OrderUID: "b", Error: "timeout"}},
+		OrderUIDs: []string{"a", "b", "c"},
+	}, summary)
+
+	acks.handler("c")("nuid-c", nil)
+	summary = acks.wait(time.Second)
+	assert.Equal(t, 2, summary.Acked)
+	assert.Zero(t, summary.Pending)
+	assert.False(t, summary.TimedOut)
+}
@@ -6,21 +6,33 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/msmkdenis/wb-order-nats/internal/archive"
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
 	"github.com/msmkdenis/wb-order-nats/internal/cache/memory"
+	"github.com/msmkdenis/wb-order-nats/internal/codec"
 	"github.com/msmkdenis/wb-order-nats/internal/config"
 	"github.com/msmkdenis/wb-order-nats/internal/consumer"
+	"github.com/msmkdenis/wb-order-nats/internal/grpcserver"
 	"github.com/msmkdenis/wb-order-nats/internal/handlers"
 	"github.com/msmkdenis/wb-order-nats/internal/metrics"
 	"github.com/msmkdenis/wb-order-nats/internal/middleware"
+	"github.com/msmkdenis/wb-order-nats/internal/openapi"
+	"github.com/msmkdenis/wb-order-nats/internal/outbox"
+	"github.com/msmkdenis/wb-order-nats/internal/partition"
 	"github.com/msmkdenis/wb-order-nats/internal/repository"
 	"github.com/msmkdenis/wb-order-nats/internal/service"
+	"github.com/msmkdenis/wb-order-nats/internal/shard"
+	"github.com/msmkdenis/wb-order-nats/internal/signature"
 	"github.com/msmkdenis/wb-order-nats/internal/storage/db"
+	"github.com/msmkdenis/wb-order-nats/internal/web"
+	"github.com/msmkdenis/wb-order-nats/internal/webhook"
 )
 
 func Run(quitSignal chan os.Signal) {
@@ -30,17 +42,63 @@ func Run(quitSignal chan os.Signal) {
 		log.Fatal("Unable to initialize zap logger", err)
 	}
 
-	postgresPool := initPostgresPool(cfg, logger)
+	postgresPool := initPostgresPool(cfg.DatabaseURI, cfg.MigrationMode, logger)
 
-	orderRepository := repository.NewOrderRepository(postgresPool, logger)
+	orderRepository, orderPools := initOrderRepository(cfg, postgresPool, logger)
 	cache := memory.NewCache(logger)
 
 	orderService := service.NewOrderUseCase(orderRepository, cache, logger)
+	orderService.SetOutboxSubject(cfg.OutboxSubject)
+	err = orderService.SetErasureMode(cfg.ErasureMode)
+	if err != nil {
+		logger.Fatal("failed to set erasure mode", zap.Error(err))
+	}
 	err = orderService.RestoreCache()
 	if err != nil {
 		logger.Error("failed to restore cache", zap.Error(err))
 	}
 
+	webhookRepository := repository.NewWebhookRepository(postgresPool, logger)
+	webhookService := service.NewWebhookUseCase(webhookRepository, logger)
+	webhookService.SetAllowPrivate(cfg.WebhookAllowPrivate)
+	backgroundCtx, backgroundStop := context.WithCancel(context.Background())
+	dispatcher := webhook.NewDispatcher(webhookRepository, cfg.WebhookInterval, cfg.WebhookAttempts, logger)
+	dispatcher.SetAllowPrivate(cfg.WebhookAllowPrivate)
+	for _, pool := range orderPools {
+		if pool != postgresPool {
+			dispatcher.AddEventSource(repository.NewWebhookRepository(pool, logger))
+		}
+	}
+	go dispatcher.Run(backgroundCtx)
+
+	for _, pool := range orderPools {
+		partitionMaintainer, err := partition.NewMaintainer(repository.NewPartitionRepository(pool, logger), cache,
+			cfg.PartitionAhead, cfg.PartitionRetention, cfg.PartitionRetentionMode, cfg.PartitionInterval, logger)
+		if err != nil {
+			logger.Fatal("failed to configure partition maintenance", zap.Error(err))
+		}
+		if sharded, ok := orderRepository.(*repository.ShardedOrderRepository); ok {
+			partitionMaintainer.SetShardIndex(sharded)
+		}
+		go partitionMaintainer.Run(backgroundCtx)
+	}
+
+	if cfg.ArchiveDir != "" {
+		store, err := archive.Open(cfg.ArchiveDir)
+		if err != nil {
+			logger.Fatal("failed to open order archive", zap.Error(err))
+		}
+		orderService.SetArchive(store)
+
+		if cfg.ArchiveAge > 0 {
+			archiver, err := archive.NewArchiver(orderRepository, store, cache, cfg.ArchiveAge, cfg.ArchiveBatch, cfg.ArchiveInterval, logger)
+			if err != nil {
+				logger.Fatal("failed to configure order archiving", zap.Error(err))
+			}
+			go archiver.Run(backgroundCtx)
+		}
+	}
+
 	statService := metrics.NewMessageStatsUseCase(logger)
 	go statService.ProcessedMessagesRun(context.Background())
 
@@ -51,29 +109,90 @@ func Run(quitSignal chan os.Signal) {
 		logger.Fatal("failed to connect to nats-streaming", zap.Error(err))
 	}
 
+	subjectCodec, err := codec.ByName(cfg.NatsCodec)
+	if err != nil {
+		logger.Fatal("failed to select codec", zap.Error(err))
+	}
+
+	signatureKeys, err := signature.ParseKeys(cfg.SignatureKeys)
+	if err != nil {
+		logger.Fatal("failed to parse signature keys", zap.Error(err))
+	}
+
+	var requeueSigner *signature.Signer
+	if cfg.RequeueSignatureKey != "" {
+		secret, ok := signatureKeys[cfg.RequeueSignatureKey]
+		if !ok {
+			logger.Fatal("requeue signature key is not in SIGNATURE_KEYS", zap.String("key", cfg.RequeueSignatureKey))
+		}
+		requeueSigner = signature.NewSigner(cfg.RequeueSignatureKey, secret)
+	}
+	orderService.SetRequeueSubject(cfg.NatsSubject, subjectCodec, requeueSigner)
+
 	unsubscribe := make(chan struct{})
 	if nats != nil {
-		err = nats.OrderProcessingRun(cfg.NatsSubject, cfg.NatsQGroup, cfg.NatsDurable, cfg.NatsSubscribers, cfg.Workers, unsubscribe)
+		nats.RegisterCodec(cfg.NatsSubject, subjectCodec)
+		err = nats.SetMaxMessageSize(cfg.MaxMessageSize)
+		if err != nil {
+			logger.Fatal("failed to set maximum message size", zap.Error(err))
+		}
+		err = nats.SetVerifier(signature.NewVerifier(signatureKeys), cfg.SignatureMode)
+		if err != nil {
+			logger.Fatal("failed to set signature verifier", zap.Error(err))
+		}
+		err = nats.OrderProcessingRun(cfg.NatsSubject, cfg.NatsQGroup, cfg.NatsDurable, cfg.NatsSubscribers, cfg.Workers, cfg.PartitionKey, unsubscribe)
 		if err != nil {
-			logger.Error("failed to run order processing", zap.Error(err))
+			logger.Fatal("failed to run order processing", zap.Error(err))
+		}
+		// The relay also publishes requeued quarantined orders, so it runs without OUTBOX_SUBJECT too.
+		for _, pool := range orderPools {
+			go outbox.NewRelay(repository.NewOutboxRepository(pool, logger), nats, cfg.OutboxInterval, logger).Run(backgroundCtx)
+		}
+		if cfg.StatusSubject != "" {
+			err = nats.StatusProcessingRun(cfg.StatusSubject, cfg.NatsQGroup, cfg.NatsDurable+"-status", unsubscribe)
+			if err != nil {
+				logger.Error("failed to run status processing", zap.Error(err))
+			}
 		}
 	}
 
 	requestLogger := middleware.InitRequestLogger(logger)
 	cacheMiddleware := middleware.NewCacheMiddleware(cache, logger)
+	authenticator := initAuthenticator(cfg, logger)
+	authMiddleware := middleware.NewAuthMiddleware(authenticator, logger)
 
 	e := echo.New()
+	e.Renderer, err = web.NewRenderer()
+	if err != nil {
+		logger.Fatal("failed to parse ui templates", zap.Error(err))
+	}
 
 	e.Use(requestLogger.RequestLogger())
 
-	handlers.NewOrderHandler(e, orderService, cacheMiddleware, logger)
-	handlers.NewStatisticsHandler(e, statService, logger)
+	handlers.NewOrderHandler(e, orderService, cacheMiddleware, authMiddleware, logger)
+	handlers.NewStatisticsHandler(e, statService, authMiddleware, logger)
+	handlers.NewAdminHandler(e, orderService, cache, authMiddleware, logger)
+	handlers.NewWebhookHandler(e, webhookService, authMiddleware, logger)
+	handlers.NewUIHandler(e, orderService, statService, authMiddleware, logger)
+	openapi.Serve(e, handlers.OpenAPI())
+
+	var grpcServer *grpcserver.Server
+	if cfg.GRPCAddress != "" {
+		grpcServer = grpcserver.NewServer(orderService, statService, authenticator, logger)
+		go func() {
+			errServe := grpcServer.Run(cfg.GRPCAddress)
+			if errServe != nil {
+				log.Fatal(errServe)
+			}
+		}()
+	}
 
 	serverCtx, serverStopCtx := context.WithCancel(context.Background())
 
 	go func() {
 		<-quitSignal
 		close(unsubscribe)
+		backgroundStop()
 
 		shutdownCtx, cancel := context.WithTimeout(serverCtx, 30*time.Second)
 		defer cancel()
@@ -86,9 +205,14 @@ func Run(quitSignal chan os.Signal) {
 		}()
 
 		logger.Info("Shutting down gracefully...")
+		// stat streams never end on their own, Shutdown would wait for them until the timeout
+		statService.CloseStreams()
 		if errShutdown := e.Shutdown(shutdownCtx); errShutdown != nil {
 			e.Logger.Fatal(errShutdown)
 		}
+		if grpcServer != nil {
+			grpcServer.Shutdown(shutdownCtx)
+		}
 		serverStopCtx()
 	}()
 
@@ -102,25 +226,85 @@ func Run(quitSignal chan os.Signal) {
 	<-serverCtx.Done()
 }
 
-func initPostgresPool(cfg *config.Config, logger *zap.Logger) *db.PostgresPool {
+func initPostgresPool(uri string, migrationMode string, logger *zap.Logger) *db.PostgresPool {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
 	defer cancel()
 
-	postgresPool, err := db.NewPostgresPool(ctx, cfg.DatabaseURI, logger)
+	postgresPool, err := db.NewPostgresPool(ctx, uri, logger)
 	if err != nil {
 		logger.Fatal("Unable to connect to database", zap.Error(err))
 	}
 
-	migrations, err := db.NewMigrations(cfg.DatabaseURI, logger)
+	migrations, err := db.NewMigrations(uri, logger)
 	if err != nil {
 		logger.Fatal("Unable to create migrations", zap.Error(err))
 	}
 
-	err = migrations.MigrateUp()
+	defer migrations.Close()
+
+	err = migrations.Startup(migrationMode)
 	if err != nil {
-		logger.Fatal("Unable to up migrations", zap.Error(err))
+		logger.Fatal("Unable to prepare database schema", zap.Error(err))
 	}
 
-	logger.Info("Connected to database", zap.String("DSN", cfg.DatabaseURI))
+	logger.Info("Connected to database", zap.String("DSN", uri))
 	return postgresPool
 }
+
+// orderStore is the order repository of a single database or of the shards.
+type orderStore interface {
+	service.OrderRepository
+	archive.Repository
+}
+
+// initOrderRepository returns the order repository and the pools storing orders, the main pool
+// or the pools of DATABASE_SHARDS, each pool once. Every shard is migrated like the main
+// database, shards with the same URI, including the URI of the main database, share a pool.
+func initOrderRepository(cfg *config.Config, postgresPool *db.PostgresPool, logger *zap.Logger) (orderStore, []*db.PostgresPool) {
+	shards, err := shard.Parse(cfg.DatabaseShards)
+	if err != nil {
+		logger.Fatal("Unable to parse database shards", zap.Error(err))
+	}
+	if len(shards) == 0 {
+		return repository.NewOrderRepository(postgresPool, logger), []*db.PostgresPool{postgresPool}
+	}
+
+	shardPools := make(map[string]*db.PostgresPool, len(shards))
+	uriPools := map[string]*db.PostgresPool{cfg.DatabaseURI: postgresPool}
+	pools := make([]*db.PostgresPool, 0, len(shards))
+	for _, s := range shards {
+		pool, ok := uriPools[s.URI]
+		if !ok {
+			pool = initPostgresPool(s.URI, cfg.MigrationMode, logger)
+			uriPools[s.URI] = pool
+		}
+		shardPools[s.Name] = pool
+		if !slices.Contains(pools, pool) {
+			pools = append(pools, pool)
+		}
+	}
+
+	logger.Info("Orders are sharded", zap.Int("shards", len(shards)))
+	return repository.NewShardedOrderRepository(postgresPool, shardPools, logger), pools
+}
+
+func initAuthenticator(cfg *config.Config, logger *zap.Logger) *auth.Authenticator {
+	apiKeys, err := auth.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		logger.Fatal("Unable to parse api keys", zap.Error(err))
+	}
+
+	var jwks *auth.JWKS
+	if cfg.JWKSFile != "" {
+		jwks, err = auth.LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			logger.Fatal("Unable to load jwks", zap.Error(err))
+		}
+	}
+
+	if len(apiKeys) == 0 && jwks == nil {
+		logger.Warn("No api keys or jwks configured, all api requests will be rejected")
+	}
+
+	return auth.NewAuthenticator(apiKeys, jwks, cfg.JWTIssuer, cfg.JWTAudience)
+}
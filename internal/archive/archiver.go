@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/audit"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+type Repository interface {
+	SelectCreatedBefore(ctx context.Context, cutoff time.Time, limit int) ([]model.Order, error)
+	DeleteOrders(ctx context.Context, orderUIDs []string) error
+}
+
+type Cache interface {
+	DeleteOrder(key string)
+}
+
+// Archiver moves orders older than the age from the database to the store.
+type Archiver struct {
+	repository Repository
+	store      *Store
+	cache      Cache
+	age        time.Duration
+	batch      int
+	interval   time.Duration
+	logger     *zap.Logger
+}
+
+// NewArchiver creates an archiver moving orders in batches of batch orders.
+func NewArchiver(repository Repository, store *Store, cache Cache, age time.Duration, batch int, interval time.Duration, logger *zap.Logger) (*Archiver, error) {
+	if age <= 0 || batch <= 0 || interval <= 0 {
+		return nil, fmt.Errorf("archive age, batch and interval must be positive")
+	}
+
+	return &Archiver{
+		repository: repository,
+		store:      store,
+		cache:      cache,
+		age:        age,
+		batch:      batch,
+		interval:   interval,
+		logger:     logger,
+	}, nil
+}
+
+// Run archives old orders right away and then every interval until ctx is done.
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		archived, err := a.Archive(ctx, time.Now())
+		if err != nil {
+			a.logger.Error("unable to archive orders", zap.Error(err))
+		}
+		if archived > 0 {
+			a.logger.Info("orders archived", zap.Int("orders", archived), zap.Int("total", a.store.Len()))
+		}
+
+		select {
+		case <-ctx.Done():
+			a.logger.Info("order archiver stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Archive moves all orders created before now minus the age and returns their number. Orders
+// are deleted from the database only after the archive file and the index are synced, a failed
+// delete leaves them in both places and they are archived again on the next run. The repository
+// records the deletions in the audit trail with the archiver as the source.
+func (a *Archiver) Archive(ctx context.Context, now time.Time) (int, error) {
+	ctx = audit.WithSource(ctx, audit.SourceArchiver)
+	cutoff := now.Add(-a.age)
+	archived := 0
+	for ctx.Err() == nil {
+		orders, err := a.repository.SelectCreatedBefore(ctx, cutoff, a.batch)
+		if err != nil || len(orders) == 0 {
+			return archived, err
+		}
+
+		file, err := a.store.Write(orders)
+		if err != nil {
+			return archived, err
+		}
+
+		orderUIDs := make([]string, 0, len(orders))
+		for _, order := range orders {
+			orderUIDs = append(orderUIDs, order.OrderUID)
+		}
+		err = a.repository.DeleteOrders(ctx, orderUIDs)
+		if err != nil {
+			return archived, fmt.Errorf("orders are written to %s but not deleted: %w", file, err)
+		}
+
+		for _, orderUID := range orderUIDs {
+			a.cache.DeleteOrder(orderUID)
+		}
+		archived += len(orders)
+
+		if len(orders) < a.batch {
+			break
+		}
+	}
+	return archived, nil
+}
@@ -0,0 +1,68 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+type fakeRepository struct {
+	orders    []model.Order
+	cutoff    time.Time
+	deleteErr error
+}
+
+func (f *fakeRepository) SelectCreatedBefore(_ context.Context, cutoff time.Time, limit int) ([]model.Order, error) {
+	f.cutoff = cutoff
+	return f.orders[:min(limit, len(f.orders))], nil
+}
+
+func (f *fakeRepository) DeleteOrders(_ context.Context, orderUIDs []string) error {
+	if f.deleteErr != nil {
+		return f.deleteErr
+	}
+	f.orders = f.orders[len(orderUIDs):]
+	return nil
+}
+
+type fakeCache []string
+
+func (f *fakeCache) DeleteOrder(key string) {
+	*f = append(*f, key)
+}
+
+func TestArchive(t *testing.T) {
+	store, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	repository := &fakeRepository{orders: orders(5)}
+	cache := &fakeCache{}
+	archiver, err := NewArchiver(repository, store, cache, 24*time.Hour, 2, time.Hour, zap.NewNop())
+	require.NoError(t, err)
+
+	_, err = NewArchiver(repository, store, cache, 24*time.Hour, 2, 0, zap.NewNop())
+	assert.Error(t, err, "a zero interval would panic in Run")
+
+	now := time.Date(2024, time.March, 3, 0, 0, 0, 0, time.UTC)
+	archived, err := archiver.Archive(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 5, archived)
+	assert.Empty(t, repository.orders)
+	assert.Equal(t, now.Add(-24*time.Hour), repository.cutoff)
+	assert.Len(t, *cache, 5)
+	assert.Equal(t, 5, store.Len())
+
+	repository = &fakeRepository{orders: orders(1), deleteErr: errors.New("deadlock")}
+	archiver.repository = repository
+	_, err = archiver.Archive(context.Background(), now)
+	assert.ErrorIs(t, err, repository.deleteErr)
+	assert.Len(t, *cache, 5, "orders left in the database stay cached")
+}
@@ -0,0 +1,439 @@
+// Package archive moves old orders out of Postgres into gzip NDJSON files and serves them back.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/pii"
+)
+
+// blockSize is the number of orders in one gzip member, a lookup decompresses one member.
+const blockSize = 256
+
+const indexFile = "index.ndjson"
+
+const (
+	filePrefix = "orders-"
+	fileSuffix = ".ndjson.gz"
+)
+
+var ErrNotArchived = errors.New("order is not archived")
+
+// location is the gzip member of an archive file holding the order.
+type location struct {
+	File   string `json:"file"`
+	Offset int64  `json:"offset"`
+}
+
+// indexEntry maps the order to its location, an entry without a file removes the order from
+// the index.
+type indexEntry struct {
+	OrderUID string `json:"order_uid"`
+	location
+}
+
+// Store keeps archived orders in files of concatenated gzip members, each one a block of
+// NDJSON lines, so a whole file still decompresses with gunzip. index.ndjson maps order ids to
+// the file and offset of their member and is loaded into memory on Open.
+type Store struct {
+	dir string
+	// files serializes writing and rewriting of archive files
+	files sync.Mutex
+	mu    sync.RWMutex
+	index map[string]location
+	log   *os.File
+}
+
+// Open opens the archive in dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{dir: dir, index: make(map[string]location)}
+	err = s.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	s.log, err = os.OpenFile(filepath.Join(dir, indexFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadIndex applies index.ndjson to the in-memory index. A torn last line left by a crash
+// during Write is cut off, the orders of that Write are still in the database and get
+// archived again. Broken lines before it are reported as corruption.
+func (s *Store) loadIndex() error {
+	path := filepath.Join(s.dir, indexFile)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var size int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) > 0 {
+				return os.Truncate(path, size)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var entry indexEntry
+		err = json.Unmarshal(data, &entry)
+		if err != nil {
+			if _, peekErr := reader.Peek(1); errors.Is(peekErr, io.EOF) {
+				return os.Truncate(path, size)
+			}
+			return fmt.Errorf("%s line %d: %w", indexFile, line, err)
+		}
+		s.apply(entry)
+		size += int64(len(data))
+	}
+}
+
+func (s *Store) apply(entry indexEntry) {
+	if entry.File == "" {
+		delete(s.index, entry.OrderUID)
+		return
+	}
+	s.index[entry.OrderUID] = entry.location
+}
+
+// Write stores the orders in a new archive file and indexes them. Both the file and the index
+// are synced before Write returns, so the orders may be deleted from the database afterwards.
+func (s *Store) Write(orders []model.Order) (string, error) {
+	s.files.Lock()
+	defer s.files.Unlock()
+
+	name := newFileName()
+	entries, err := s.writeFile(name, orders)
+	if err != nil {
+		_ = os.Remove(filepath.Join(s.dir, name))
+		return "", err
+	}
+
+	err = s.appendIndex(entries)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func newFileName() string {
+	return filePrefix + time.Now().UTC().Format("20060102T150405.000000000") + fileSuffix
+}
+
+// appendIndex syncs the entries to index.ndjson and applies them to the in-memory index.
+func (s *Store) appendIndex(entries []indexEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	_, err := s.log.Write(buf)
+	if err != nil {
+		return err
+	}
+	err = s.log.Sync()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		s.apply(entry)
+	}
+	return nil
+}
+
+// Erase removes personal data of the customer from the archive: every file holding orders of the
+// customer is rewritten with their deliveries erased, or without them when hardDelete is set,
+// and the old file is removed once the index points to the new one. Copies of orders archived
+// again later are dropped from the rewritten files. Erase scans the whole archive and returns
+// the erased orders as they were before erasure.
+func (s *Store) Erase(customerID string, hardDelete bool) ([]model.Order, error) {
+	s.files.Lock()
+	defer s.files.Unlock()
+
+	names, err := s.fileNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var erased []model.Order
+	for _, name := range names {
+		orders, err := s.readFile(name)
+		if err != nil {
+			return erased, err
+		}
+
+		var kept []model.Order
+		var entries []indexEntry
+		changed := false
+		for _, order := range orders {
+			indexed := s.indexedIn(order.OrderUID, name)
+			if order.CustomerID != customerID {
+				if indexed {
+					kept = append(kept, order)
+				}
+				continue
+			}
+			changed = true
+			if !indexed {
+				continue
+			}
+			erased = append(erased, order)
+			if hardDelete {
+				entries = append(entries, indexEntry{OrderUID: order.OrderUID})
+				continue
+			}
+			kept = append(kept, pii.Erase(order))
+		}
+		if !changed {
+			continue
+		}
+
+		err = s.rewrite(name, kept, entries)
+		if err != nil {
+			return erased, err
+		}
+	}
+	return erased, nil
+}
+
+// FindByCustomer reads the archived orders of the customer without changing them.
+func (s *Store) FindByCustomer(customerID string) ([]model.Order, error) {
+	s.files.Lock()
+	defer s.files.Unlock()
+
+	names, err := s.fileNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []model.Order
+	for _, name := range names {
+		orders, err := s.readFile(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, order := range orders {
+			if order.CustomerID == customerID && s.indexedIn(order.OrderUID, name) {
+				found = append(found, order)
+			}
+		}
+	}
+	return found, nil
+}
+
+// rewrite replaces the archive file by a new one with the orders and removes the deleted
+// entries from the index.
+func (s *Store) rewrite(name string, orders []model.Order, deleted []indexEntry) error {
+	entries := deleted
+	if len(orders) > 0 {
+		newName := newFileName()
+		written, err := s.writeFile(newName, orders)
+		if err != nil {
+			_ = os.Remove(filepath.Join(s.dir, newName))
+			return err
+		}
+		entries = append(entries, written...)
+	}
+
+	err := s.appendIndex(entries)
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(s.dir, name))
+}
+
+// fileNames returns the archive files in the directory, including files whose orders were all
+// archived again later.
+func (s *Store) fileNames() ([]string, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range dirEntries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (s *Store) indexedIn(orderUID string, name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.index[orderUID].File == name
+}
+
+// readFile reads all orders of the archive file.
+func (s *Store) readFile(name string) ([]model.Order, error) {
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	members, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	var orders []model.Order
+	dec := json.NewDecoder(members)
+	for {
+		var order model.Order
+		err = dec.Decode(&order)
+		if errors.Is(err, io.EOF) {
+			return orders, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		orders = append(orders, order)
+	}
+}
+
+func (s *Store) writeFile(name string, orders []model.Order) ([]indexEntry, error) {
+	file, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := bufio.NewWriter(file)
+	out := &countingWriter{w: buf}
+	entries := make([]indexEntry, 0, len(orders))
+	for start := 0; start < len(orders); start += blockSize {
+		offset := out.n
+		member := gzip.NewWriter(out)
+		enc := json.NewEncoder(member)
+		for _, order := range orders[start:min(start+blockSize, len(orders))] {
+			err = enc.Encode(order)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, indexEntry{OrderUID: order.OrderUID, location: location{File: name, Offset: offset}})
+		}
+		err = member.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = buf.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return entries, file.Sync()
+}
+
+// Find reads the archived order, it returns ErrNotArchived if the order is not in the index.
+// Erase may replace the file of the order between the index lookup and the read, the lookup
+// is then repeated once.
+func (s *Store) Find(orderUID string) (*model.Order, error) {
+	order, err := s.find(orderUID)
+	if errors.Is(err, os.ErrNotExist) {
+		order, err = s.find(orderUID)
+	}
+	return order, err
+}
+
+func (s *Store) find(orderUID string) (*model.Order, error) {
+	s.mu.RLock()
+	loc, ok := s.index[orderUID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotArchived
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, loc.File))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	_, err = file.Seek(loc.Offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	member, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s at %d: %w", loc.File, loc.Offset, err)
+	}
+	member.Multistream(false)
+
+	dec := json.NewDecoder(member)
+	for {
+		var order model.Order
+		err = dec.Decode(&order)
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: %s is missing in %s", ErrNotArchived, orderUID, loc.File)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s at %d: %w", loc.File, loc.Offset, err)
+		}
+		if order.OrderUID == orderUID {
+			return &order, nil
+		}
+	}
+}
+
+// Len returns the number of archived orders.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.index)
+}
+
+func (s *Store) Close() error {
+	return s.log.Close()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,159 @@
+package archive
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+func orders(n int) []model.Order {
+	result := make([]model.Order, n)
+	for i := range result {
+		result[i] = model.Order{OrderUID: fmt.Sprintf("order-%d", i), TrackNumber: fmt.Sprintf("TRACK%d", i)}
+	}
+	return result
+}
+
+func TestStoreWriteFind(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	require.NoError(t, err)
+
+	written := orders(blockSize + 10)
+	name, err := store.Write(written)
+	require.NoError(t, err)
+	assert.Equal(t, len(written), store.Len())
+
+	last := written[len(written)-1]
+	found, err := store.Find(last.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, last, *found)
+
+	_, err = store.Find("missing")
+	assert.ErrorIs(t, err, ErrNotArchived)
+	require.NoError(t, store.Close())
+
+	// the concatenated members are plain gzip NDJSON
+	file, err := os.Open(filepath.Join(dir, name))
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, len(written), strings.Count(string(content), "\n"))
+
+	reopened, err := Open(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, len(written), reopened.Len())
+	found, err = reopened.Find(written[0].OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, written[0], *found)
+}
+
+func TestStoreErase(t *testing.T) {
+	for _, hardDelete := range []bool{false, true} {
+		t.Run(fmt.Sprintf("hard delete %t", hardDelete), func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := Open(dir)
+			require.NoError(t, err)
+
+			written := orders(4)
+			written[1].CustomerID, written[3].CustomerID = "customer", "customer"
+			written[1].Delivery.Phone = "+79990000001"
+			_, err = store.Write(written[:2])
+			require.NoError(t, err)
+			_, err = store.Write(written[2:])
+			require.NoError(t, err)
+
+			matched, err := store.FindByCustomer("customer")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []model.Order{written[1], written[3]}, matched)
+
+			erased, err := store.Erase("customer", hardDelete)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []model.Order{written[1], written[3]}, erased)
+			require.NoError(t, store.Close())
+
+			reopened, err := Open(dir)
+			require.NoError(t, err)
+			defer reopened.Close()
+
+			// the original files are gone, no copy of the phone is left
+			names, err := reopened.fileNames()
+			require.NoError(t, err)
+			for _, name := range names {
+				content, err := reopened.readFile(name)
+				require.NoError(t, err)
+				for _, order := range content {
+					assert.NotEqual(t, written[1].Delivery.Phone, order.Delivery.Phone)
+				}
+			}
+
+			found, err := reopened.Find(written[0].OrderUID)
+			require.NoError(t, err)
+			assert.Equal(t, written[0], *found)
+
+			found, err = reopened.Find(written[1].OrderUID)
+			if hardDelete {
+				assert.ErrorIs(t, err, ErrNotArchived)
+				assert.Equal(t, 2, reopened.Len())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "[erased]", found.Delivery.Phone)
+			assert.Equal(t, 4, reopened.Len())
+		})
+	}
+}
+
+func TestStoreOpenTornIndex(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	require.NoError(t, err)
+	written := orders(3)
+	_, err = store.Write(written)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	index := filepath.Join(dir, indexFile)
+	log, err := os.OpenFile(index, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = log.WriteString(`{"order_uid":"order-torn","fi`)
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	// a crash during Write tears the last line only, the archive opens without it
+	store, err = Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, len(written), store.Len())
+	more := []model.Order{{OrderUID: "order-next"}}
+	_, err = store.Write(more)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, len(written)+len(more), store.Len())
+	found, err := store.Find("order-next")
+	require.NoError(t, err)
+	assert.Equal(t, more[0], *found)
+	require.NoError(t, store.Close())
+
+	// a broken line before the last one is corruption
+	content, err := os.ReadFile(index)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(index, append([]byte("{broken\n"), content...), 0o644))
+	_, err = Open(dir)
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Package audit describes who changed an order and how.
+package audit
+
+import (
+	"context"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+)
+
+// Sources of order changes.
+const (
+	SourceNats  = "nats"
+	SourceHTTP  = "http"
+	SourceGRPC  = "grpc"
+	SourceAdmin = "admin"
+	// SourceArchiver moves old orders to the archive.
+	SourceArchiver = "archiver"
+	// SourceRetention detaches or drops old partitions of the order tables.
+	SourceRetention = "retention"
+)
+
+// Actions recorded in the audit trail.
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+	// ActionArchived means the order left the database for the archive.
+	ActionArchived = "archived"
+)
+
+const systemPrincipal = "system"
+
+type sourceKey struct{}
+
+// WithSource returns a copy of ctx carrying the source of changes.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFromContext returns the source of changes stored in ctx.
+func SourceFromContext(ctx context.Context) string {
+	source, ok := ctx.Value(sourceKey{}).(string)
+	if !ok {
+		return "unknown"
+	}
+	return source
+}
+
+// PrincipalFromContext returns the subject of the authenticated caller,
+// changes without a caller such as stream processing are attributed to the system.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.Subject == "" {
+		return systemPrincipal
+	}
+	return principal.Subject
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Change is the value of a single field before and after the change.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff compares JSON representations of before and after and returns changed
+// fields keyed by their path, e.g. "delivery.phone" or "items[0].price".
+// A nil before or after describes creation or deletion.
+func Diff(before any, after any) (map[string]Change, error) {
+	b, err := flatten(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := flatten(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for path, bv := range b {
+		av, ok := a[path]
+		if !ok || !equal(bv, av) {
+			changes[path] = Change{Before: bv, After: av}
+		}
+	}
+	for path, av := range a {
+		if _, ok := b[path]; !ok {
+			changes[path] = Change{Before: nil, After: av}
+		}
+	}
+
+	return changes, nil
+}
+
+func flatten(v any) (map[string]any, error) {
+	flat := make(map[string]any)
+	if v == nil {
+		return flat, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic any
+	err = json.Unmarshal(data, &generic)
+	if err != nil {
+		return nil, err
+	}
+
+	walk("", generic, flat)
+	return flat, nil
+}
+
+func walk(prefix string, v any, flat map[string]any) {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, nested := range value {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			walk(path, nested, flat)
+		}
+	case []any:
+		for i, nested := range value {
+			walk(fmt.Sprintf("%s[%d]", prefix, i), nested, flat)
+		}
+	default:
+		flat[prefix] = value
+	}
+}
+
+func equal(a any, b any) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
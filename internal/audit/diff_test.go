@@ -0,0 +1,32 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+func TestDiff(t *testing.T) {
+	before := model.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: model.Delivery{Phone: "+7***1234"},
+		Items:    []model.Item{{ChrtID: 9934930, Price: 453}},
+	}
+	after := before
+	after.Delivery.Phone = "[erased]"
+	after.Items = []model.Item{{ChrtID: 9934930, Price: 500}}
+
+	changes, err := Diff(before, after)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Change{
+		"delivery.phone": {Before: "+7***1234", After: "[erased]"},
+		"items[0].price": {Before: float64(453), After: float64(500)},
+	}, changes)
+
+	created, err := Diff(nil, after)
+	require.NoError(t, err)
+	assert.Equal(t, Change{Before: nil, After: "b563feb7b2b84b6test"}, created["order_uid"])
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// APIKeys maps static API keys to their scopes.
+type APIKeys map[string][]string
+
+// ParseAPIKeys parses keys in the form "key1=scope1 scope2;key2=admin".
+func ParseAPIKeys(raw string) (APIKeys, error) {
+	keys := make(APIKeys)
+	if raw == "" {
+		return keys, nil
+	}
+
+	for _, entry := range strings.Split(raw, ";") {
+		key, scopes, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || key == "" || strings.TrimSpace(scopes) == "" {
+			return nil, fmt.Errorf("invalid api key entry, expected key=scope1 scope2")
+		}
+		keys[key] = strings.Fields(scopes)
+	}
+
+	return keys, nil
+}
+
+// Authenticator resolves API keys and bearer tokens to principals.
+type Authenticator struct {
+	apiKeys  APIKeys
+	jwks     *JWKS
+	issuer   string
+	audience string
+}
+
+func NewAuthenticator(apiKeys APIKeys, jwks *JWKS, issuer string, audience string) *Authenticator {
+	return &Authenticator{
+		apiKeys:  apiKeys,
+		jwks:     jwks,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+// APIKey authenticates a static API key.
+func (a *Authenticator) APIKey(key string) (Principal, error) {
+	scopes, ok := a.apiKeys[key]
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	return Principal{
+		Subject: "apikey:" + fingerprint(key),
+		Scopes:  scopes,
+	}, nil
+}
+
+// Bearer authenticates an HS256 or RS256 JWT signed by a key from the JWKS.
+func (a *Authenticator) Bearer(token string) (Principal, error) {
+	if a.jwks == nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if a.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.issuer))
+	}
+	if a.audience != "" {
+		opts = append(opts, jwt.WithAudience(a.audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, a.jwks.Keyfunc, opts...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims.GetSubject()
+	return Principal{
+		Subject: subject,
+		Scopes:  scopesFromClaims(claims),
+	}, nil
+}
+
+// scopesFromClaims reads the space separated "scope" claim or the "scopes" array claim.
+func scopesFromClaims(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	raw, ok := claims["scopes"].([]any)
+	if !ok {
+		return nil
+	}
+
+	scopes := make([]string, 0, len(raw))
+	for _, s := range raw {
+		if scope, ok := s.(string); ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// fingerprint identifies an API key in logs and audit without revealing it.
+func fingerprint(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
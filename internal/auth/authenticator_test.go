@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBearer(t *testing.T) {
+	hmacSecret := []byte("test-hmac-secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks := writeJWKS(t, map[string]any{
+		"keys": []map[string]string{
+			{"kty": "oct", "kid": "hs", "k": base64.RawURLEncoding.EncodeToString(hmacSecret)},
+			{
+				"kty": "RSA", "kid": "rs",
+				"n": base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+		},
+	})
+	authenticator := NewAuthenticator(APIKeys{}, jwks, "wb-auth", "")
+
+	claims := jwt.MapClaims{
+		"sub":   "support",
+		"iss":   "wb-auth",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": ScopeOrdersRead + " " + ScopeStatsRead,
+	}
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr bool
+	}{
+		{name: "HS256", token: func() string { return sign(t, jwt.SigningMethodHS256, "hs", claims, hmacSecret) }},
+		{name: "RS256", token: func() string { return sign(t, jwt.SigningMethodRS256, "rs", claims, rsaKey) }},
+		{
+			name:    "HS256 signed with RSA public key",
+			token:   func() string { return sign(t, jwt.SigningMethodHS256, "rs", claims, rsaKey.N.Bytes()) },
+			wantErr: true,
+		},
+		{
+			name:    "wrong secret",
+			token:   func() string { return sign(t, jwt.SigningMethodHS256, "hs", claims, []byte("other")) },
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			token: func() string {
+				expired := jwt.MapClaims{"sub": "support", "iss": "wb-auth", "exp": time.Now().Add(-time.Hour).Unix()}
+				return sign(t, jwt.SigningMethodHS256, "hs", expired, hmacSecret)
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				foreign := jwt.MapClaims{"sub": "support", "iss": "other", "exp": time.Now().Add(time.Hour).Unix()}
+				return sign(t, jwt.SigningMethodHS256, "hs", foreign, hmacSecret)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Bearer(tt.token())
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidCredentials), err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "support", principal.Subject)
+			assert.True(t, principal.HasScope(ScopeOrdersRead))
+			assert.False(t, principal.HasScope(ScopeOrdersWrite))
+		})
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	keys, err := ParseAPIKeys("reader-key=orders:read stats:read;admin-key=admin")
+	require.NoError(t, err)
+	authenticator := NewAuthenticator(keys, nil, "", "")
+
+	reader, err := authenticator.APIKey("reader-key")
+	require.NoError(t, err)
+	assert.True(t, reader.HasScope(ScopeStatsRead))
+	assert.False(t, reader.HasScope(ScopeOrdersWrite))
+
+	admin, err := authenticator.APIKey("admin-key")
+	require.NoError(t, err)
+	assert.True(t, admin.HasScope(ScopeOrdersWrite))
+
+	_, err = authenticator.APIKey("unknown")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func writeJWKS(t *testing.T, set map[string]any) *JWKS {
+	t.Helper()
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	jwks, err := LoadJWKS(path)
+	require.NoError(t, err)
+	return jwks
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwksKey struct {
+	alg string
+	key any
+}
+
+// JWKS holds verification keys loaded from a local JSON Web Key Set file.
+// Symmetric "oct" keys verify HS256 tokens, "RSA" keys verify RS256 tokens.
+type JWKS struct {
+	keys map[string]jwksKey
+}
+
+// LoadJWKS reads the key set from the file.
+func LoadJWKS(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err = json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := &JWKS{keys: make(map[string]jwksKey, len(set.Keys))}
+	for _, k := range set.Keys {
+		if k.Kid == "" {
+			return nil, errors.New("jwks key without kid")
+		}
+
+		switch k.Kty {
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return nil, fmt.Errorf("jwks key %s: %w", k.Kid, err)
+			}
+			jwks.keys[k.Kid] = jwksKey{alg: jwt.SigningMethodHS256.Alg(), key: secret}
+		case "RSA":
+			pub, err := rsaPublicKey(k.N, k.E)
+			if err != nil {
+				return nil, fmt.Errorf("jwks key %s: %w", k.Kid, err)
+			}
+			jwks.keys[k.Kid] = jwksKey{alg: jwt.SigningMethodRS256.Alg(), key: pub}
+		default:
+			return nil, fmt.Errorf("jwks key %s: unsupported kty %q", k.Kid, k.Kty)
+		}
+	}
+
+	return jwks, nil
+}
+
+// Keyfunc selects the verification key by the token kid and checks that the
+// token algorithm matches the key type.
+func (j *JWKS) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	if token.Method.Alg() != k.alg {
+		return nil, fmt.Errorf("algorithm %s does not match key %s", token.Method.Alg(), kid)
+	}
+
+	return k.key, nil
+}
+
+func rsaPublicKey(n string, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nb),
+		E: int(new(big.Int).SetBytes(eb).Int64()),
+	}, nil
+}
@@ -0,0 +1,40 @@
+// Package auth implements authentication of API callers with static API keys and JWTs.
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Scopes granted to API callers.
+const (
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+	ScopeStatsRead   = "stats:read"
+	ScopePIIRead     = "pii:read"
+	ScopeAdmin       = "admin"
+)
+
+// Principal is an authenticated API caller.
+type Principal struct {
+	Subject string
+	Scopes  []string
+}
+
+// HasScope reports whether the principal is granted the scope, admin is granted every scope.
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 
@@ -11,9 +12,18 @@ import (
 type Cache struct {
 	mu     sync.RWMutex
 	items  map[string]model.Order
+	hits   atomic.Uint64
+	misses atomic.Uint64
 	logger *zap.Logger
 }
 
+// Stats describes the cache content and lookups since start.
+type Stats struct {
+	Orders int    `json:"orders"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
 func NewCache(logger *zap.Logger) *Cache {
 	return &Cache{
 		mu:     sync.RWMutex{},
@@ -36,12 +46,22 @@ func (c *Cache) GetOrder(key string) (model.Order, bool) {
 	value, ok := c.items[key]
 
 	if ok {
+		c.hits.Add(1)
 		c.logger.Info("Get from cache", zap.String("key", key))
+	} else {
+		c.misses.Add(1)
 	}
 
 	return value, ok
 }
 
+func (c *Cache) DeleteOrder(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+}
+
 func (c *Cache) RestoreCache(orders []model.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -50,3 +70,14 @@ func (c *Cache) RestoreCache(orders []model.Order) {
 		c.items[order.OrderUID] = order
 	}
 }
+
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return Stats{
+		Orders: len(c.items),
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+}
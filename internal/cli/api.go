@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/msmkdenis/wb-order-nats/internal/cache/memory"
+	"github.com/msmkdenis/wb-order-nats/internal/handlers"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/service"
+)
+
+const requestTimeout = time.Minute
+
+// apiClient calls the wborder HTTP API with an API key.
+type apiClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func newAPIClient(baseURL string, apiKey string) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// do sends the request with body encoded as JSON, if not nil, and decodes the response into out.
+func (a *apiClient) do(method string, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, a.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if a.apiKey != "" {
+		req.Header.Set("X-API-Key", a.apiKey)
+	}
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr map[string]string
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &apiErr) == nil && apiErr["Error"] != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, apiErr["Error"])
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *command) cache(args []string) error {
+	name, _, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+
+	var stats memory.Stats
+	switch name {
+	case "stats":
+		err = c.api.do(http.MethodGet, "/api/v1/admin/cache", nil, &stats)
+	case "warm":
+		err = c.api.do(http.MethodPost, "/api/v1/admin/cache/warm", nil, &stats)
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	return c.print(stats)
+}
+
+func (c *command) order(args []string) error {
+	name, rest, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "get":
+		if len(rest) != 1 {
+			return errUsage
+		}
+		var order model.Order
+		err = c.api.do(http.MethodGet, "/api/v1/order/"+url.PathEscape(rest[0]), nil, &order)
+		if err != nil {
+			return err
+		}
+		return c.print(order)
+	case "export":
+		return c.exportOrders(rest)
+	default:
+		return errUsage
+	}
+}
+
+// exportOrders writes all orders as NDJSON, ready for publish.
+func (c *command) exportOrders(args []string) error {
+	flags := flag.NewFlagSet("order export", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	output := flags.String("o", "-", "output file, - for stdout")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	var orders []model.Order
+	err := c.api.do(http.MethodGet, "/api/v1/order/", nil, &orders)
+	if err != nil {
+		return err
+	}
+
+	out := c.stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	enc := json.NewEncoder(out)
+	for _, order := range orders {
+		err = enc.Encode(order)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.stderr, "exported %d orders\n", len(orders))
+	return nil
+}
+
+func (c *command) dlq(args []string) error {
+	name, rest, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "list":
+		flags := flag.NewFlagSet("dlq list", flag.ContinueOnError)
+		flags.SetOutput(c.stderr)
+		limit := flags.Int("limit", 100, "maximum number of orders")
+		if err := flags.Parse(rest); err != nil || flags.NArg() != 0 {
+			return errUsage
+		}
+
+		var quarantined []model.QuarantinedOrder
+		err = c.api.do(http.MethodGet, "/api/v1/admin/quarantine?limit="+strconv.Itoa(*limit), nil, &quarantined)
+		if err != nil {
+			return err
+		}
+		return c.print(quarantined)
+	case "requeue":
+		flags := flag.NewFlagSet("dlq requeue", flag.ContinueOnError)
+		flags.SetOutput(c.stderr)
+		all := flags.Bool("all", false, "requeue all quarantined orders")
+		if err := flags.Parse(rest); err != nil || (*all == (flags.NArg() > 0)) {
+			return errUsage
+		}
+
+		var result service.RequeueResult
+		err = c.api.do(http.MethodPost, "/api/v1/admin/quarantine/requeue", handlers.RequeueRequest{IDs: flags.Args(), All: *all}, &result)
+		if err != nil {
+			return err
+		}
+		return c.print(result)
+	default:
+		return errUsage
+	}
+}
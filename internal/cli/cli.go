@@ -0,0 +1,117 @@
+// Package cli implements wbctl, the command line tool for operating wborder and natsproducer.
+//
+// Commands working with the running service (cache, order, dlq) call the wborder HTTP API,
+// commands working with infrastructure (migrate, replay, publish) use the internal packages
+// with the configuration of wborder.env and natsproducer.env.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/msmkdenis/wb-order-nats/internal/app/natsproducer"
+)
+
+const usage = `usage: wbctl [-url URL] [-api-key KEY] <command> [flags] [args]
+
+Commands:
+  publish [flags] <file|->         publish orders of a JSON array or NDJSON file to NATS
+  replay [flags]                   republish messages of a subject from a sequence or time
+  migrate up|down|to|force|status  manage migrations of all databases or of -db NAME, -dry-run
+                                   prints the plan, force requires -db and takes a version or none
+  cache stats|warm                 show cache statistics, load all orders into the cache
+  order get <order_uid>            print an order
+  order export [-o file]           export all orders as NDJSON
+  dlq list [-limit N]              list quarantined orders
+  dlq requeue -all | <id>...       publish quarantined orders again
+
+Flags:
+`
+
+// errUsage reports invalid arguments, the usage is printed instead of the error.
+var errUsage = errors.New("invalid usage")
+
+type command struct {
+	api    *apiClient
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Run executes the command line and returns the process exit code.
+func Run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("wbctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	baseURL := flags.String("url", envOr("WBCTL_URL", "http://127.0.0.1:7000"), "wborder base URL, WBCTL_URL")
+	apiKey := flags.String("api-key", os.Getenv("WBCTL_API_KEY"), "wborder API key with the admin scope, WBCTL_API_KEY")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	cmd := &command{
+		api:    newAPIClient(*baseURL, *apiKey),
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	name, rest := flags.Arg(0), flags.Args()[1:]
+	var err error
+	switch name {
+	case "publish":
+		return natsproducer.PublishFileCommand(rest)
+	case "replay":
+		err = cmd.replay(rest)
+	case "migrate":
+		err = cmd.migrate(rest)
+	case "cache":
+		err = cmd.cache(rest)
+	case "order":
+		err = cmd.order(rest)
+	case "dlq":
+		err = cmd.dlq(rest)
+	default:
+		err = errUsage
+	}
+
+	switch {
+	case errors.Is(err, errUsage):
+		flags.Usage()
+		return 2
+	case err != nil:
+		fmt.Fprintln(stderr, "wbctl:", err)
+		return 1
+	}
+	return 0
+}
+
+// subcommand returns the first argument and the rest, or errUsage if there are none.
+func subcommand(args []string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", nil, errUsage
+	}
+	return args[0], args[1:], nil
+}
+
+func (c *command) print(v any) error {
+	out := json.NewEncoder(c.stdout)
+	out.SetIndent("", "  ")
+	return out.Encode(v)
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
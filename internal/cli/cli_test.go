@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/msmkdenis/wb-order-nats/internal/handlers"
+	"github.com/msmkdenis/wb-order-nats/internal/shard"
+)
+
+func run(t *testing.T, server *httptest.Server, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(append([]string{"-url", server.URL, "-api-key", "secret"}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestAPICommands(t *testing.T) {
+	var requeued handlers.RequeueRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+		switch r.Method + " " + r.URL.RequestURI() {
+		case "GET /api/v1/admin/cache":
+			_, _ = w.Write([]byte(`{"orders": 2, "hits": 5, "misses": 1}`))
+		case "GET /api/v1/order/":
+			_, _ = w.Write([]byte(`[{"order_uid": "a"}, {"order_uid": "b"}]`))
+		case "POST /api/v1/admin/quarantine/requeue":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&requeued))
+			_, _ = w.Write([]byte(`{"order_uids": ["a"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"Error": "order not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	code, stdout, _ := run(t, server, "cache", "stats")
+	assert.Equal(t, 0, code)
+	assert.JSONEq(t, `{"orders": 2, "hits": 5, "misses": 1}`, stdout)
+
+	code, stdout, _ = run(t, server, "order", "export")
+	assert.Equal(t, 0, code)
+	lines := bytes.Split(bytes.TrimSpace([]byte(stdout)), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.Contains(t, string(lines[1]), `"order_uid":"b"`)
+
+	code, stdout, _ = run(t, server, "dlq", "requeue", "6f1c8c1e-8d0a-4f7e-9a6b-0c6f1c8c1e8d")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, []string{"6f1c8c1e-8d0a-4f7e-9a6b-0c6f1c8c1e8d"}, requeued.IDs)
+	assert.JSONEq(t, `{"order_uids": ["a"]}`, stdout)
+
+	code, _, stderr := run(t, server, "order", "get", "missing")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "order not found")
+}
+
+func TestUsage(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	for _, args := range [][]string{{}, {"unknown"}, {"cache"}, {"dlq", "requeue"}, {"dlq", "requeue", "-all", "id"},
+		{"migrate", "force", "3"}, {"migrate", "force", "-db", "main", "x"}, {"migrate", "to", "-db", "main", "none"}} {
+		code, _, stderr := run(t, server, args...)
+		assert.Equal(t, 2, code, args)
+		assert.Contains(t, stderr, "usage: wbctl", args)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		raw     string
+		force   bool
+		want    int64
+		wantErr bool
+	}{
+		{raw: "3", want: 3},
+		{raw: "0", want: 0},
+		{raw: "-1", wantErr: true},
+		{raw: "none", wantErr: true},
+		{raw: "3", force: true, want: 3},
+		{raw: "-1", force: true, want: -1},
+		{raw: "none", force: true, want: -1},
+		{raw: "-2", force: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		version, err := parseVersion(tt.raw, tt.force)
+		if tt.wantErr {
+			assert.Error(t, err, tt.raw)
+			continue
+		}
+		assert.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.want, version, tt.raw)
+	}
+}
+
+func TestSelectDatabase(t *testing.T) {
+	databases := []shard.Shard{{Name: "main", URI: "postgres://main"}, {Name: "shard1", URI: "postgres://shard1"}}
+
+	selected, err := selectDatabase(databases, "shard1")
+	assert.NoError(t, err)
+	assert.Equal(t, databases[1:], selected)
+
+	_, err = selectDatabase(databases, "shard2")
+	assert.ErrorContains(t, err, "unknown database")
+}
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/config"
+	"github.com/msmkdenis/wb-order-nats/internal/shard"
+	"github.com/msmkdenis/wb-order-nats/internal/storage/db"
+)
+
+// migrationStatus is printed by migrate status and after every migration.
+type migrationStatus struct {
+	Database string    `json:"database"`
+	Version  uint      `json:"version"`
+	Dirty    bool      `json:"dirty"`
+	Latest   uint      `json:"latest"`
+	Pending  []db.Step `json:"pending"`
+}
+
+// migrationPlan is printed by -dry-run instead of migrating.
+type migrationPlan struct {
+	Database string    `json:"database"`
+	Version  uint      `json:"version"`
+	Steps    []db.Step `json:"steps"`
+}
+
+// migrate runs migrate up, down [-steps N], to <version>, force <version> or status on the
+// main database and every shard of DATABASE_SHARDS, or only on the database selected by -db,
+// which force requires. -dry-run prints the migrations to run instead of running them.
+func (c *command) migrate(args []string) error {
+	name, rest, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("migrate "+name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	steps := flags.Int("steps", 1, "number of migrations to roll back")
+	dryRun := flags.Bool("dry-run", false, "print the migrations to run without running them")
+	only := flags.String("db", "", "migrate only this database, main or a shard name")
+	if err := flags.Parse(rest); err != nil || *steps < 1 {
+		return errUsage
+	}
+
+	var version int64
+	switch name {
+	case "up", "down", "status":
+		if flags.NArg() != 0 {
+			return errUsage
+		}
+	case "to", "force":
+		if flags.NArg() != 1 || (name == "force" && *only == "") {
+			return errUsage
+		}
+		version, err = parseVersion(flags.Arg(0), name == "force")
+		if err != nil {
+			return errUsage
+		}
+	default:
+		return errUsage
+	}
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return err
+	}
+	databases, err := migrationDatabases(config.NewConfig())
+	if err != nil {
+		return err
+	}
+	if *only != "" {
+		databases, err = selectDatabase(databases, *only)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, database := range databases {
+		err = c.migrateDatabase(database, logger, name, *steps, version, *dryRun)
+		if err != nil {
+			return fmt.Errorf("%s: %w", database.Name, err)
+		}
+	}
+	return nil
+}
+
+// migrationDatabases returns the main database and the shards not sharing its URI.
+func migrationDatabases(cfg *config.Config) ([]shard.Shard, error) {
+	shards, err := shard.Parse(cfg.DatabaseShards)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []shard.Shard{{Name: "main", URI: cfg.DatabaseURI}}
+	for _, s := range shards {
+		if s.URI != cfg.DatabaseURI {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+// selectDatabase returns the database with the name.
+func selectDatabase(databases []shard.Shard, name string) ([]shard.Shard, error) {
+	for _, database := range databases {
+		if database.Name == name {
+			return []shard.Shard{database}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown database %q", name)
+}
+
+// parseVersion parses the version argument of migrate to and force. force also accepts none,
+// or -1 after --, to mark the database as having no migrations applied.
+func parseVersion(raw string, force bool) (int64, error) {
+	if force && raw == "none" {
+		return -1, nil
+	}
+	version, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if version < 0 && !(force && version == -1) {
+		return 0, fmt.Errorf("invalid version %d", version)
+	}
+	return version, nil
+}
+
+func (c *command) migrateDatabase(database shard.Shard, logger *zap.Logger, name string, steps int, version int64, dryRun bool) error {
+	migrations, err := db.NewMigrations(database.URI, logger)
+	if err != nil {
+		return err
+	}
+	defer migrations.Close()
+
+	if dryRun {
+		return c.planMigrations(migrations, database.Name, name, steps, uint(version))
+	}
+
+	switch name {
+	case "up":
+		err = migrations.MigrateUp()
+	case "down":
+		err = migrations.MigrateDown(steps)
+	case "to":
+		err = migrations.MigrateTo(uint(version))
+	case "force":
+		err = migrations.Force(int(version))
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.migrationStatus(migrations, database.Name)
+}
+
+func (c *command) planMigrations(migrations *db.Migrations, database string, name string, steps int, version uint) error {
+	current, _, err := migrations.Version()
+	if err != nil {
+		return err
+	}
+
+	var plan []db.Step
+	switch name {
+	case "up":
+		version, err = db.Latest()
+		if err == nil {
+			plan, err = migrations.Plan(version)
+		}
+	case "down":
+		plan, err = migrations.PlanDown(steps)
+	case "to":
+		plan, err = migrations.Plan(version)
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.print(migrationPlan{Database: database, Version: current, Steps: plan})
+}
+
+func (c *command) migrationStatus(migrations *db.Migrations, database string) error {
+	version, dirty, err := migrations.Version()
+	if err != nil {
+		return err
+	}
+	latest, err := db.Latest()
+	if err != nil {
+		return err
+	}
+
+	status := migrationStatus{Database: database, Version: version, Dirty: dirty, Latest: latest, Pending: []db.Step{}}
+	if dirty {
+		fmt.Fprintf(c.stderr, "migration %d failed midway, repair the schema and run migrate force -db %s <version>\n", version, database)
+	} else if version <= latest {
+		status.Pending, err = migrations.Plan(latest)
+		if err != nil {
+			return err
+		}
+	}
+	return c.print(status)
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/stan.go"
+
+	"github.com/msmkdenis/wb-order-nats/internal/config"
+)
+
+// replayReport is printed when a replay finishes.
+type replayReport struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Replayed      int    `json:"replayed"`
+	FirstSequence uint64 `json:"first_sequence,omitempty"`
+	LastSequence  uint64 `json:"last_sequence,omitempty"`
+}
+
+// replay republishes messages stored by NATS Streaming. It stops at the first message published
+// after the replay started, so replaying a subject into itself ends, at -count messages or
+// when no message arrived for -idle.
+func (c *command) replay(args []string) error {
+	cfg := config.NewConfig()
+
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	from := flags.String("subject", cfg.NatsSubject, "subject to replay")
+	to := flags.String("to", "", "subject to publish to, the replayed subject by default")
+	seq := flags.Uint64("seq", 0, "start at the sequence")
+	since := flags.Duration("since", 0, "start at messages published this long ago")
+	all := flags.Bool("all", false, "start at the first stored message")
+	count := flags.Int("count", 0, "stop after the number of messages, 0 means no limit")
+	idle := flags.Duration("idle", 5*time.Second, "stop when no message arrived for this long")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	var start stan.SubscriptionOption
+	starts := 0
+	if *seq > 0 {
+		start, starts = stan.StartAtSequence(*seq), starts+1
+	}
+	if *since > 0 {
+		start, starts = stan.StartAtTimeDelta(*since), starts+1
+	}
+	if *all {
+		start, starts = stan.DeliverAllAvailable(), starts+1
+	}
+	if starts != 1 || *from == "" {
+		fmt.Fprintln(c.stderr, "exactly one of -seq, -since and -all is required")
+		return errUsage
+	}
+	if *to == "" {
+		*to = *from
+	}
+
+	clientID := fmt.Sprintf("%s-replay-%d", cfg.NatsClient, os.Getpid())
+	sc, err := stan.Connect(cfg.NatsCluster, clientID, stan.NatsURL(cfg.NatsURL))
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	msgs := make(chan *stan.Msg, 256)
+	done := make(chan struct{})
+	defer close(done)
+	started := time.Now().UnixNano()
+	sub, err := sc.Subscribe(*from, func(msg *stan.Msg) {
+		select {
+		case msgs <- msg:
+		case <-done:
+		}
+	}, start)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	report := replayReport{From: *from, To: *to}
+	for *count == 0 || report.Replayed < *count {
+		var msg *stan.Msg
+		select {
+		case msg = <-msgs:
+		case <-time.After(*idle):
+		}
+		if msg == nil || msg.Timestamp >= started {
+			break
+		}
+
+		err = sc.Publish(*to, msg.Data)
+		if err != nil {
+			return errors.Join(fmt.Errorf("replay stopped at sequence %d: %w", msg.Sequence, err), c.print(report))
+		}
+		if report.FirstSequence == 0 {
+			report.FirstSequence = msg.Sequence
+		}
+		report.LastSequence = msg.Sequence
+		report.Replayed++
+	}
+
+	return c.print(report)
+}
@@ -0,0 +1,99 @@
+// Package codec implements wire formats of order messages.
+package codec
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/schema"
+)
+
+// Content types of the supported codecs.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeMsgPack  = "application/msgpack"
+)
+
+// NATS Streaming messages have no headers, so the content type travels as a prefix:
+// headerMagic, one byte of content type length and the content type itself.
+// A zero first byte never starts a JSON, msgpack map or valid protobuf message.
+var headerMagic = []byte{0x00, 'c', 't'}
+
+// Schemas upgrades payloads of older schema versions for all codecs, upgraders registered
+// here apply to JSON, msgpack and protobuf messages alike.
+var Schemas = schema.NewRegistry()
+
+var (
+	jsonCodec     = NewJSON(Schemas)
+	protobufCodec = NewProtobuf(Schemas)
+	msgPackCodec  = NewMsgPack(Schemas)
+)
+
+// Codec converts orders to and from their wire representation.
+type Codec interface {
+	ContentType() string
+	Marshal(order model.Order) ([]byte, error)
+	Unmarshal(data []byte) (model.Order, error)
+}
+
+// ByName returns the codec by its short name: json, protobuf or msgpack.
+func ByName(name string) (Codec, error) {
+	switch name {
+	case "", "json":
+		return jsonCodec, nil
+	case "protobuf", "proto":
+		return protobufCodec, nil
+	case "msgpack":
+		return msgPackCodec, nil
+	default:
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+}
+
+// ByContentType returns the codec by its content type.
+func ByContentType(contentType string) (Codec, error) {
+	switch contentType {
+	case ContentTypeJSON:
+		return jsonCodec, nil
+	case ContentTypeProtobuf:
+		return protobufCodec, nil
+	case ContentTypeMsgPack:
+		return msgPackCodec, nil
+	default:
+		return nil, fmt.Errorf("unknown content type %q", contentType)
+	}
+}
+
+// Encode marshals the order and prefixes it with the codec content type header.
+func Encode(c Codec, order model.Order) ([]byte, error) {
+	body, err := c.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := c.ContentType()
+	data := make([]byte, 0, len(headerMagic)+1+len(contentType)+len(body))
+	data = append(data, headerMagic...)
+	data = append(data, byte(len(contentType)))
+	data = append(data, contentType...)
+	data = append(data, body...)
+
+	return data, nil
+}
+
+// SplitHeader returns the content type from the message header and the message body.
+// Messages without a header are returned as is with an empty content type.
+func SplitHeader(data []byte) (string, []byte, error) {
+	if !bytes.HasPrefix(data, headerMagic) {
+		return "", data, nil
+	}
+
+	rest := data[len(headerMagic):]
+	if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+		return "", nil, fmt.Errorf("truncated content type header")
+	}
+
+	return string(rest[1 : 1+int(rest[0])]), rest[1+int(rest[0]):], nil
+}
@@ -0,0 +1,147 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// fullOrder sets every field of the order, so round trips cover all of them.
+func fullOrder() model.Order {
+	return model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			RequestID:    "request-1",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       intPtr(1817),
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: intPtr(1500),
+			GoodsTotal:   intPtr(317),
+			CustomFee:    intPtr(7),
+		},
+		Items: []model.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:            "en",
+		InternalSignature: "v1=k1:abc",
+		CustomerID:        "test",
+		DeliveryService:   "meest",
+		Shardkey:          "9",
+		SmID:              99,
+		DateCreated:       "2021-11-26T06:22:19Z",
+		OofShard:          "1",
+		Status:            model.StatusPaid,
+	}
+}
+
+func assertNoZeroFields(t *testing.T, v reflect.Value, path string) {
+	t.Helper()
+	if v.IsZero() {
+		t.Errorf("%s is not set in the fixture", path)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		assertNoZeroFields(t, v.Elem(), path)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			assertNoZeroFields(t, v.Field(i), path+"."+v.Type().Field(i).Name)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			assertNoZeroFields(t, v.Index(i), path)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	order := fullOrder()
+	assertNoZeroFields(t, reflect.ValueOf(order), "Order")
+
+	for _, name := range []string{"json", "msgpack", "protobuf"} {
+		t.Run(name, func(t *testing.T) {
+			c, err := ByName(name)
+			require.NoError(t, err)
+
+			data, err := c.Marshal(order)
+			require.NoError(t, err)
+			decoded, err := c.Unmarshal(data)
+			require.NoError(t, err)
+			assert.Equal(t, order, decoded)
+
+			framed, err := Encode(c, order)
+			require.NoError(t, err)
+			contentType, body, err := SplitHeader(framed)
+			require.NoError(t, err)
+			assert.Equal(t, c.ContentType(), contentType)
+			assert.Equal(t, data, body)
+
+			byType, err := ByContentType(contentType)
+			require.NoError(t, err)
+			assert.Same(t, c, byType)
+		})
+	}
+}
+
+func TestProtoParity(t *testing.T) {
+	order := fullOrder()
+	assert.Equal(t, order, FromProto(ToProto(order)))
+
+	order.Payment.Amount = nil
+	assert.Nil(t, FromProto(ToProto(order)).Payment.Amount, "unset amounts stay unset")
+}
+
+func TestSplitHeader(t *testing.T) {
+	plain := []byte(`{"order_uid":"1"}`)
+	contentType, body, err := SplitHeader(plain)
+	require.NoError(t, err)
+	assert.Empty(t, contentType, "messages without a header have no content type")
+	assert.Equal(t, plain, body)
+
+	_, _, err = SplitHeader(append(append([]byte{}, headerMagic...), 10, 'a'))
+	assert.Error(t, err, "truncated header")
+	_, _, err = SplitHeader(headerMagic)
+	assert.Error(t, err, "header without length")
+
+	framed := append(append([]byte{}, headerMagic...), byte(len("text/xml")))
+	framed = append(framed, "text/xml<order/>"...)
+	contentType, _, err = SplitHeader(framed)
+	require.NoError(t, err)
+	_, err = ByContentType(contentType)
+	assert.ErrorContains(t, err, `unknown content type "text/xml"`)
+
+	_, err = ByName("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,29 @@
+package codec
+
+import (
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/schema"
+)
+
+// JSON encodes orders as versioned JSON envelopes, decoding older versions through the schema registry.
+type JSON struct {
+	schemas *schema.Registry
+}
+
+func NewJSON(schemas *schema.Registry) *JSON {
+	return &JSON{
+		schemas: schemas,
+	}
+}
+
+func (j *JSON) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (j *JSON) Marshal(order model.Order) ([]byte, error) {
+	return schema.Wrap(order)
+}
+
+func (j *JSON) Unmarshal(data []byte) (model.Order, error) {
+	return j.schemas.Decode(data)
+}
@@ -0,0 +1,84 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/schema"
+)
+
+type msgPackEnvelope struct {
+	SchemaVersion int         `json:"schema_version"`
+	Order         model.Order `json:"order"`
+}
+
+// rawMsgPackEnvelope defers decoding the order until its schema version is known.
+type rawMsgPackEnvelope struct {
+	SchemaVersion int                `json:"schema_version"`
+	Order         msgpack.RawMessage `json:"order"`
+}
+
+// MsgPack encodes orders as MessagePack maps keyed by the same names as JSON, so orders of
+// older schema versions are converted to JSON and upgraded by the schema registry.
+type MsgPack struct {
+	schemas *schema.Registry
+}
+
+func NewMsgPack(schemas *schema.Registry) *MsgPack {
+	return &MsgPack{
+		schemas: schemas,
+	}
+}
+
+func (m *MsgPack) ContentType() string {
+	return ContentTypeMsgPack
+}
+
+func (m *MsgPack) Marshal(order model.Order) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+
+	err := enc.Encode(msgPackEnvelope{
+		SchemaVersion: schema.CurrentVersion,
+		Order:         order,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (m *MsgPack) Unmarshal(data []byte) (model.Order, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	var envelope rawMsgPackEnvelope
+	err := dec.Decode(&envelope)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	dec = msgpack.NewDecoder(bytes.NewReader(envelope.Order))
+	dec.SetCustomStructTag("json")
+	if envelope.SchemaVersion == schema.CurrentVersion {
+		var order model.Order
+		err = dec.Decode(&order)
+		return order, err
+	}
+
+	var payload map[string]any
+	err = dec.Decode(&payload)
+	if err != nil {
+		return model.Order{}, err
+	}
+	upgradable, err := json.Marshal(payload)
+	if err != nil {
+		return model.Order{}, err
+	}
+	return m.schemas.DecodePayload(envelope.SchemaVersion, upgradable)
+}
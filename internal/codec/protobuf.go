@@ -0,0 +1,198 @@
+package codec
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/schema"
+	orderv1 "github.com/msmkdenis/wb-order-nats/pkg/api/order/v1"
+)
+
+//go:generate protoc -I ../../api/proto --go_out=../../pkg/api --go_opt=paths=source_relative order/v1/order.proto
+
+// Protobuf encodes orders as order.v1.OrderMessage. Orders of older schema versions are
+// converted to the JSON of the fields order.v1 knows and upgraded by the schema registry.
+type Protobuf struct {
+	schemas *schema.Registry
+}
+
+func NewProtobuf(schemas *schema.Registry) *Protobuf {
+	return &Protobuf{
+		schemas: schemas,
+	}
+}
+
+func (p *Protobuf) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (p *Protobuf) Marshal(order model.Order) ([]byte, error) {
+	return proto.Marshal(&orderv1.OrderMessage{
+		SchemaVersion: schema.CurrentVersion,
+		Order:         ToProto(order),
+	})
+}
+
+func (p *Protobuf) Unmarshal(data []byte) (model.Order, error) {
+	var msg orderv1.OrderMessage
+	err := proto.Unmarshal(data, &msg)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	// proto3 scalars have no presence, so an omitted version reads as 0 and
+	// is taken for the legacy version rather than an unknown one.
+	version := int(msg.GetSchemaVersion())
+	if version == 0 {
+		version = schema.LegacyVersion
+	}
+
+	order := FromProto(msg.GetOrder())
+	if version == schema.CurrentVersion {
+		return order, nil
+	}
+
+	payload, err := json.Marshal(order)
+	if err != nil {
+		return model.Order{}, err
+	}
+	return p.schemas.DecodePayload(version, payload)
+}
+
+// ToProto converts the order to its protobuf representation.
+func ToProto(o model.Order) *orderv1.Order {
+	items := make([]*orderv1.Item, 0, len(o.Items))
+	for _, i := range o.Items {
+		items = append(items, &orderv1.Item{
+			ChrtId:      int64(i.ChrtID),
+			TrackNumber: i.TrackNumber,
+			Price:       int64(i.Price),
+			Rid:         i.Rid,
+			Name:        i.Name,
+			Sale:        int64(i.Sale),
+			Size:        i.Size,
+			TotalPrice:  int64(i.TotalPrice),
+			NmId:        int64(i.NmID),
+			Brand:       i.Brand,
+			Status:      int64(i.Status),
+		})
+	}
+
+	return &orderv1.Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &orderv1.Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &orderv1.Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       toInt64Ptr(o.Payment.Amount),
+			PaymentDt:    o.Payment.PaymentDt,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: toInt64Ptr(o.Payment.DeliveryCost),
+			GoodsTotal:   toInt64Ptr(o.Payment.GoodsTotal),
+			CustomFee:    toInt64Ptr(o.Payment.CustomFee),
+		},
+		Items:             items,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.Shardkey,
+		SmId:              int64(o.SmID),
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+		Status:            o.Status,
+	}
+}
+
+// FromProto converts the protobuf order to the model.
+func FromProto(o *orderv1.Order) model.Order {
+	items := make([]model.Item, 0, len(o.GetItems()))
+	for _, i := range o.GetItems() {
+		items = append(items, model.Item{
+			ChrtID:      int(i.GetChrtId()),
+			TrackNumber: i.GetTrackNumber(),
+			Price:       int(i.GetPrice()),
+			Rid:         i.GetRid(),
+			Name:        i.GetName(),
+			Sale:        int(i.GetSale()),
+			Size:        i.GetSize(),
+			TotalPrice:  int(i.GetTotalPrice()),
+			NmID:        int(i.GetNmId()),
+			Brand:       i.GetBrand(),
+			Status:      int(i.GetStatus()),
+		})
+	}
+
+	d := o.GetDelivery()
+	p := o.GetPayment()
+	if p == nil {
+		p = &orderv1.Payment{}
+	}
+
+	return model.Order{
+		OrderUID:    o.GetOrderUid(),
+		TrackNumber: o.GetTrackNumber(),
+		Entry:       o.GetEntry(),
+		Delivery: model.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		},
+		Payment: model.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       toIntPtr(p.Amount),
+			PaymentDt:    p.GetPaymentDt(),
+			Bank:         p.GetBank(),
+			DeliveryCost: toIntPtr(p.DeliveryCost),
+			GoodsTotal:   toIntPtr(p.GoodsTotal),
+			CustomFee:    toIntPtr(p.CustomFee),
+		},
+		Items:             items,
+		Locale:            o.GetLocale(),
+		InternalSignature: o.GetInternalSignature(),
+		CustomerID:        o.GetCustomerId(),
+		DeliveryService:   o.GetDeliveryService(),
+		Shardkey:          o.GetShardkey(),
+		SmID:              int(o.GetSmId()),
+		DateCreated:       o.GetDateCreated(),
+		OofShard:          o.GetOofShard(),
+		Status:            o.GetStatus(),
+	}
+}
+
+func toInt64Ptr(v *int) *int64 {
+	if v == nil {
+		return nil
+	}
+	i := int64(*v)
+	return &i
+}
+
+func toIntPtr(v *int64) *int {
+	if v == nil {
+		return nil
+	}
+	i := int(*v)
+	return &i
+}
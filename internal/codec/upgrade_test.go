@@ -0,0 +1,74 @@
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/schema"
+	orderv1 "github.com/msmkdenis/wb-order-nats/pkg/api/order/v1"
+)
+
+// upgradeRegistry upgrades version 0 payloads, which had no status, to the current version.
+func upgradeRegistry() *schema.Registry {
+	registry := schema.NewRegistry()
+	registry.RegisterUpgrader(0, func(payload []byte) ([]byte, error) {
+		var order map[string]any
+		if err := json.Unmarshal(payload, &order); err != nil {
+			return nil, err
+		}
+		order["status"] = model.StatusCreated
+		return json.Marshal(order)
+	})
+	return registry
+}
+
+func TestUpgradeOlderVersions(t *testing.T) {
+	registry := upgradeRegistry()
+
+	packed, err := msgpack.Marshal(map[string]any{
+		"schema_version": 0,
+		"order":          map[string]any{"order_uid": "old-msgpack", "sm_id": 99},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		codec Codec
+		data  []byte
+		uid   string
+	}{
+		{codec: NewMsgPack(registry), data: packed, uid: "old-msgpack"},
+		{codec: NewJSON(registry), data: []byte(`{"schema_version":0,"payload":{"order_uid":"old-json","sm_id":99}}`), uid: "old-json"},
+	} {
+		t.Run(tt.codec.ContentType(), func(t *testing.T) {
+			order, err := tt.codec.Unmarshal(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.uid, order.OrderUID)
+			assert.Equal(t, 99, order.SmID)
+			assert.Equal(t, model.StatusCreated, order.Status)
+		})
+	}
+
+	unknown, err := proto.Marshal(&orderv1.OrderMessage{SchemaVersion: schema.CurrentVersion + 1})
+	require.NoError(t, err)
+	_, err = NewProtobuf(registry).Unmarshal(unknown)
+	assert.ErrorIs(t, err, schema.ErrUnknownVersion)
+}
+
+func TestProtobufOmittedVersion(t *testing.T) {
+	registry := upgradeRegistry()
+
+	data, err := proto.Marshal(&orderv1.OrderMessage{Order: &orderv1.Order{OrderUid: "unversioned", SmId: 99}})
+	require.NoError(t, err)
+
+	order, err := NewProtobuf(registry).Unmarshal(data)
+	require.NoError(t, err, "an omitted version is the legacy version")
+	assert.Equal(t, "unversioned", order.OrderUID)
+	assert.Equal(t, 99, order.SmID)
+	assert.Empty(t, order.Status, "the version 0 upgrade only applies to versioned formats")
+}
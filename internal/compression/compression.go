@@ -0,0 +1,113 @@
+// Package compression implements optional compression of order messages.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported compression algorithms.
+const (
+	None = ""
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// DefaultMaxSize limits decompressed messages unless a Decompressor with another limit is used.
+const DefaultMaxSize = 16 << 20
+
+// ErrTooLarge is returned when the decompressed data exceeds the limit.
+var ErrTooLarge = errors.New("decompressed message is too large")
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+
+	defaultDecompressor, _ = NewDecompressor(DefaultMaxSize)
+)
+
+// Supported reports whether the algorithm is known.
+func Supported(algorithm string) bool {
+	return algorithm == None || algorithm == Gzip || algorithm == Zstd
+}
+
+// Compress compresses the data with the algorithm, None returns the data as is.
+func Compress(algorithm string, data []byte) ([]byte, error) {
+	switch algorithm {
+	case None:
+		return data, nil
+	case Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", algorithm)
+	}
+}
+
+// Decompress decompresses the data with DefaultMaxSize, see Decompressor.Decompress.
+func Decompress(data []byte) ([]byte, string, error) {
+	return defaultDecompressor.Decompress(data)
+}
+
+// Decompressor decompresses messages up to a maximum size, so a small message can not
+// expand to gigabytes in memory.
+type Decompressor struct {
+	maxSize int64
+	zstd    *zstd.Decoder
+}
+
+func NewDecompressor(maxSize int64) (*Decompressor, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("maximum decompressed size must be positive")
+	}
+
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	if err != nil {
+		return nil, err
+	}
+	return &Decompressor{maxSize: maxSize, zstd: decoder}, nil
+}
+
+// Decompress detects the compression by its magic prefix and decompresses the data.
+// It returns the detected algorithm, uncompressed data is returned as is with None.
+func (d *Decompressor) Decompress(data []byte) ([]byte, string, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, Gzip, err
+		}
+		defer r.Close()
+		out, err := io.ReadAll(io.LimitReader(r, d.maxSize+1))
+		if err == nil && int64(len(out)) > d.maxSize {
+			return nil, Gzip, fmt.Errorf("%w, limit %d bytes", ErrTooLarge, d.maxSize)
+		}
+		return out, Gzip, err
+	case bytes.HasPrefix(data, zstdMagic):
+		out, err := d.zstd.DecodeAll(data, nil)
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) || int64(len(out)) > d.maxSize {
+			return nil, Zstd, fmt.Errorf("%w, limit %d bytes", ErrTooLarge, d.maxSize)
+		}
+		return out, Zstd, err
+	default:
+		return data, None, nil
+	}
+}
@@ -0,0 +1,67 @@
+package compression
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"order_uid":"b563feb7b2b84b6test"}`), 100)
+	for _, algorithm := range []string{Gzip, Zstd} {
+		t.Run(algorithm, func(t *testing.T) {
+			compressed, err := Compress(algorithm, data)
+			require.NoError(t, err)
+			assert.Less(t, len(compressed), len(data))
+
+			out, detected, err := Decompress(compressed)
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, detected)
+			assert.Equal(t, data, out)
+		})
+	}
+
+	_, err := Compress("lz4", data)
+	assert.Error(t, err)
+}
+
+func TestPassthrough(t *testing.T) {
+	data := []byte(`{"order_uid":"b563feb7b2b84b6test"}`)
+	same, err := Compress(None, data)
+	require.NoError(t, err)
+	assert.Equal(t, data, same)
+
+	out, detected, err := Decompress(data)
+	require.NoError(t, err)
+	assert.Equal(t, None, detected)
+	assert.Equal(t, data, out)
+}
+
+func TestSizeLimit(t *testing.T) {
+	const limit = 64 << 10
+	d, err := NewDecompressor(limit)
+	require.NoError(t, err)
+
+	for _, algorithm := range []string{Gzip, Zstd} {
+		t.Run(algorithm, func(t *testing.T) {
+			bomb, err := Compress(algorithm, make([]byte, 1<<20))
+			require.NoError(t, err)
+			require.Less(t, len(bomb), 4096, "a small message expanding to a megabyte")
+
+			_, detected, err := d.Decompress(bomb)
+			assert.ErrorIs(t, err, ErrTooLarge)
+			assert.Equal(t, algorithm, detected)
+
+			fits, err := Compress(algorithm, make([]byte, limit))
+			require.NoError(t, err)
+			out, _, err := d.Decompress(fits)
+			require.NoError(t, err)
+			assert.Len(t, out, limit)
+		})
+	}
+
+	_, err = NewDecompressor(0)
+	assert.Error(t, err)
+}
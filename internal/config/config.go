@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/gommon/log"
@@ -10,15 +11,47 @@ import (
 
 type Config struct {
 	Address         string
+	GRPCAddress     string
 	DatabaseURI     string
+	DatabaseShards  string
+	MigrationMode   string
 	NatsCluster     string
 	NatsClient      string
 	NatsURL         string
 	NatsSubject     string
 	NatsQGroup      string
 	NatsDurable     string
+	NatsCodec       string
+	MaxMessageSize  int64
+	StatusSubject   string
 	NatsSubscribers int
 	Workers         int
+	PartitionKey    string
+	SignatureMode   string
+	SignatureKeys   string
+	APIKeys         string
+	JWKSFile        string
+	JWTIssuer       string
+	JWTAudience     string
+	ErasureMode     string
+	OutboxSubject   string
+	OutboxInterval  time.Duration
+
+	RequeueSignatureKey string
+
+	WebhookInterval     time.Duration
+	WebhookAttempts     int
+	WebhookAllowPrivate bool
+
+	PartitionAhead         int
+	PartitionRetention     int
+	PartitionRetentionMode string
+	PartitionInterval      time.Duration
+
+	ArchiveDir      string
+	ArchiveAge      time.Duration
+	ArchiveBatch    int
+	ArchiveInterval time.Duration
 }
 
 func NewConfig() *Config {
@@ -29,13 +62,34 @@ func NewConfig() *Config {
 
 	config := &Config{}
 	config.Address = os.Getenv("RUN_ADDRESS")
+	config.GRPCAddress = os.Getenv("GRPC_ADDRESS")
 	config.DatabaseURI = os.Getenv("DATABASE_URI")
+	config.DatabaseShards = os.Getenv("DATABASE_SHARDS")
+	config.MigrationMode = os.Getenv("MIGRATION_MODE")
 	config.NatsCluster = os.Getenv("NATS_CLUSTER")
 	config.NatsClient = os.Getenv("NATS_CLIENT")
 	config.NatsURL = os.Getenv("NATS_URL")
 	config.NatsSubject = os.Getenv("NATS_SUBJECT")
 	config.NatsQGroup = os.Getenv("NATS_QGROUP")
 	config.NatsDurable = os.Getenv("NATS_DURABLE")
+	config.NatsCodec = os.Getenv("NATS_CODEC")
+	config.StatusSubject = os.Getenv("NATS_STATUS_SUBJECT")
+	config.PartitionKey = os.Getenv("PARTITION_KEY")
+	config.SignatureMode = os.Getenv("SIGNATURE_MODE")
+	config.SignatureKeys = os.Getenv("SIGNATURE_KEYS")
+	config.RequeueSignatureKey = os.Getenv("REQUEUE_SIGNATURE_KEY")
+	config.APIKeys = os.Getenv("API_KEYS")
+	config.JWKSFile = os.Getenv("JWKS_FILE")
+	config.JWTIssuer = os.Getenv("JWT_ISSUER")
+	config.JWTAudience = os.Getenv("JWT_AUDIENCE")
+	config.ErasureMode = os.Getenv("ERASURE_MODE")
+	config.OutboxSubject = os.Getenv("OUTBOX_SUBJECT")
+	config.PartitionRetentionMode = os.Getenv("PARTITION_RETENTION_MODE")
+	config.ArchiveDir = os.Getenv("ARCHIVE_DIR")
+
+	if config.MaxMessageSize, err = strconv.ParseInt(os.Getenv("MAX_MESSAGE_SIZE"), 10, 64); err != nil {
+		config.MaxMessageSize = 16 << 20
+	}
 
 	if config.NatsSubscribers, err = strconv.Atoi(os.Getenv("NATS_SUBSCRIBERS")); err != nil {
 		config.NatsSubscribers = 5
@@ -45,5 +99,49 @@ func NewConfig() *Config {
 		config.Workers = 10
 	}
 
+	if config.WebhookInterval, err = time.ParseDuration(os.Getenv("WEBHOOK_INTERVAL")); err != nil {
+		config.WebhookInterval = 5 * time.Second
+	}
+
+	if config.WebhookAttempts, err = strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err != nil {
+		config.WebhookAttempts = 8
+	}
+
+	if config.WebhookAllowPrivate, err = strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE")); err != nil {
+		config.WebhookAllowPrivate = false
+	}
+
+	if config.OutboxInterval, err = time.ParseDuration(os.Getenv("OUTBOX_INTERVAL")); err != nil {
+		config.OutboxInterval = time.Second
+	}
+
+	if config.PartitionAhead, err = strconv.Atoi(os.Getenv("PARTITION_AHEAD_MONTHS")); err != nil {
+		config.PartitionAhead = 3
+	}
+
+	if config.PartitionRetention, err = strconv.Atoi(os.Getenv("PARTITION_RETENTION_MONTHS")); err != nil {
+		config.PartitionRetention = 0
+	}
+
+	if config.PartitionRetentionMode == "" {
+		config.PartitionRetentionMode = "detach"
+	}
+
+	if config.PartitionInterval, err = time.ParseDuration(os.Getenv("PARTITION_INTERVAL")); err != nil {
+		config.PartitionInterval = time.Hour
+	}
+
+	if config.ArchiveAge, err = time.ParseDuration(os.Getenv("ARCHIVE_AGE")); err != nil {
+		config.ArchiveAge = 0
+	}
+
+	if config.ArchiveBatch, err = strconv.Atoi(os.Getenv("ARCHIVE_BATCH")); err != nil {
+		config.ArchiveBatch = 1000
+	}
+
+	if config.ArchiveInterval, err = time.ParseDuration(os.Getenv("ARCHIVE_INTERVAL")); err != nil {
+		config.ArchiveInterval = time.Hour
+	}
+
 	return config
 }
@@ -3,6 +3,9 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,24 +14,52 @@ import (
 	"github.com/nats-io/stan.go"
 	"go.uber.org/zap"
 
+	"github.com/msmkdenis/wb-order-nats/internal/audit"
+	"github.com/msmkdenis/wb-order-nats/internal/codec"
+	"github.com/msmkdenis/wb-order-nats/internal/compression"
 	"github.com/msmkdenis/wb-order-nats/internal/metrics"
 	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/service"
+	"github.com/msmkdenis/wb-order-nats/internal/signature"
+)
+
+// Partition keys supported by the partitioned dispatch mode.
+// Orders with the same key value are always handled by the same worker.
+const (
+	PartitionByOrderUID   = "order_uid"
+	PartitionByCustomerID = "customer_id"
+	PartitionByShardkey   = "shardkey"
 )
 
 type OrderService interface {
 	Save(ctx context.Context, order model.Order) error
+	Quarantine(ctx context.Context, order model.Order, reason string) error
+	ChangeStatus(ctx context.Context, event model.StatusEvent) error
+	ReportFailure(ctx context.Context, orderUID string, reason string) error
 }
 
 type StatisticsPusher interface {
 	PushStats(message metrics.MessageStat)
 }
 
+// orderMessage is a decoded order passed from subscribers to workers.
+type orderMessage struct {
+	order  model.Order
+	decode *metrics.DecodeStat
+}
+
 type NatsClient struct {
 	client     stan.Conn
 	os         OrderService
 	sp         StatisticsPusher
 	logger     *zap.Logger
-	ordersChan chan model.Order
+	ordersChan chan orderMessage
+	partitions []chan orderMessage
+	keyFunc    func(order model.Order) string
+	codecs     map[string]codec.Codec
+	decompress func(data []byte) ([]byte, string, error)
+	verifier   *signature.Verifier
+	sigMode    string
 	validate   *validator.Validate
 	wg         *sync.WaitGroup
 }
@@ -45,13 +76,29 @@ func NewNatsClient(cluster string, clientID string, natsURL string, wg *sync.Wai
 		os:         service,
 		sp:         sp,
 		logger:     logger,
-		ordersChan: make(chan model.Order),
+		ordersChan: make(chan orderMessage),
+		codecs:     make(map[string]codec.Codec),
+		decompress: compression.Decompress,
 		validate:   validator.New(),
 		wg:         wg,
 	}, nil
 }
 
-func (n *NatsClient) OrderProcessingRun(subject string, qGroup string, durable string, subscribers int, workers int, unsubscribe chan struct{}) error {
+// OrderProcessingRun subscribes to the subject and starts workers saving orders.
+// With an empty partitionKey all workers share one channel, otherwise orders are
+// hashed by the key onto a fixed worker, so orders with the same key are saved sequentially.
+// Partitioned dispatch uses a single subscriber, queue subscribers receive messages concurrently
+// and could pass orders with the same key to the worker out of order.
+func (n *NatsClient) OrderProcessingRun(subject string, qGroup string, durable string, subscribers int, workers int, partitionKey string, unsubscribe chan struct{}) error {
+	err := n.setPartitioning(partitionKey, workers)
+	if err != nil {
+		return err
+	}
+	if n.partitions != nil && subscribers > 1 {
+		n.logger.Info("partitioned dispatch uses a single subscriber", zap.Int("configured", subscribers))
+		subscribers = 1
+	}
+
 	counter := atomic.Int64{}
 	for i := 0; i < subscribers; i++ {
 		go func() {
@@ -81,6 +128,9 @@ func (n *NatsClient) OrderProcessingRun(subject string, qGroup string, durable s
 			if counter.Load() == int64(subscribers) {
 				n.logger.Info("all subscribers unsubscribed")
 				close(n.ordersChan)
+				for _, partition := range n.partitions {
+					close(partition)
+				}
 				break
 			}
 		}
@@ -89,10 +139,94 @@ func (n *NatsClient) OrderProcessingRun(subject string, qGroup string, durable s
 	return nil
 }
 
+// Status events are acknowledged manually. An event failing for a reason that may go away, e.g.
+// its order is not saved yet, is redelivered after statusAckWait, up to statusMaxRedeliveries times.
+const (
+	statusAckWait         = 5 * time.Second
+	statusMaxRedeliveries = 20
+)
+
+// StatusProcessingRun subscribes to order status change events.
+// Events are applied one at a time in the order they were published, an unacknowledged event
+// holds back the following ones until it is redelivered.
+func (n *NatsClient) StatusProcessingRun(subject string, qGroup string, durable string, unsubscribe chan struct{}) error {
+	n.logger.Info("subscribing", zap.String("subject", subject), zap.String("qGroup", qGroup), zap.String("durable", durable))
+	sc, err := n.client.QueueSubscribe(subject, qGroup, n.consumeStatus(), stan.DurableName(durable),
+		stan.DeliverAllAvailable(), stan.MaxInflight(1), stan.SetManualAckMode(), stan.AckWait(statusAckWait))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-unsubscribe
+		n.logger.Info("unsubscribing status events...")
+		err := sc.Unsubscribe()
+		if err != nil {
+			n.logger.Info("error", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (n *NatsClient) consumeStatus() stan.MsgHandler {
+	return func(msg *stan.Msg) {
+		event, ack, err := n.applyStatus(msg.Data, msg.RedeliveryCount)
+		if !ack {
+			// the event is redelivered, only its final outcome is counted
+			n.logger.Info("error", zap.Error(err), zap.Bool("redelivered", true))
+			return
+		}
+		ackErr := msg.Ack()
+		if ackErr != nil {
+			n.logger.Info("error", zap.Error(ackErr))
+		}
+
+		m := metrics.MessageStat{
+			ID:        event.OrderUID,
+			Kind:      metrics.KindStatus,
+			Status:    "success",
+			Message:   "status " + event.Status,
+			Processed: time.Now().UTC(),
+		}
+		if m.ID == "" {
+			m.ID = "unknown"
+		}
+		if err != nil {
+			n.logger.Info("error", zap.Error(err))
+			m.Status = "error"
+			m.Message = err.Error()
+		}
+		go n.sp.PushStats(m)
+	}
+}
+
+// applyStatus applies the status event and reports whether it is done with and may be
+// acknowledged. Malformed events, unknown statuses and transitions the state machine forbids
+// never apply and are dropped, other errors such as a missing order, a concurrent change or a
+// database failure are retried until the redeliveries run out.
+func (n *NatsClient) applyStatus(data []byte, redeliveries uint32) (model.StatusEvent, bool, error) {
+	var event model.StatusEvent
+	err := json.Unmarshal(data, &event)
+	if err == nil {
+		err = n.validate.Struct(event)
+	}
+	if err != nil {
+		return event, true, err
+	}
+
+	err = n.os.ChangeStatus(audit.WithSource(context.Background(), audit.SourceNats), event)
+	if err == nil || errors.Is(err, service.ErrUnknownStatus) || errors.Is(err, service.ErrInvalidTransition) {
+		return event, true, err
+	}
+	if redeliveries >= statusMaxRedeliveries {
+		return event, true, fmt.Errorf("dropped after %d redeliveries: %w", redeliveries, err)
+	}
+	return event, false, err
+}
+
 func (n *NatsClient) consumeOrder() stan.MsgHandler {
 	return func(msg *stan.Msg) {
-		var order model.Order
-		err := json.Unmarshal(msg.Data, &order)
+		order, decodeStat, err := n.decode(msg)
 		if err != nil {
 			go func() {
 				m := metrics.MessageStat{
@@ -100,12 +234,16 @@ func (n *NatsClient) consumeOrder() stan.MsgHandler {
 					Status:    "error",
 					Message:   err.Error(),
 					Processed: time.Now().UTC(),
+					Decode:    decodeStat,
 				}
 				n.sp.PushStats(m)
 				n.logger.Info("error", zap.Error(err))
 			}()
 		} else {
 			err = n.validate.Struct(order)
+			if err == nil {
+				err = n.verify(order)
+			}
 			if err != nil {
 				go func(order model.Order) {
 					m := metrics.MessageStat{
@@ -113,42 +251,199 @@ func (n *NatsClient) consumeOrder() stan.MsgHandler {
 						Status:    "error",
 						Message:   err.Error(),
 						Processed: time.Now().UTC(),
+						Decode:    decodeStat,
 					}
 					n.sp.PushStats(m)
 					n.logger.Info("error", zap.Error(err))
+					n.reportFailure(order.OrderUID, err)
 				}(order)
 			} else {
-				n.ordersChan <- order
+				n.dispatch(orderMessage{order: order, decode: decodeStat})
 			}
 		}
 	}
 }
 
+// Publish publishes the message on the client connection and waits for the acknowledgement.
+func (n *NatsClient) Publish(subject string, data []byte) error {
+	return n.client.Publish(subject, data)
+}
+
+// RegisterCodec sets the codec used for messages of the subject that carry no content type header.
+// Subjects without a registered codec are decoded as JSON.
+func (n *NatsClient) RegisterCodec(subject string, c codec.Codec) {
+	n.codecs[subject] = c
+}
+
+// SetMaxMessageSize limits the decompressed size of messages, compression.DefaultMaxSize by default.
+func (n *NatsClient) SetMaxMessageSize(size int64) error {
+	decompressor, err := compression.NewDecompressor(size)
+	if err != nil {
+		return err
+	}
+
+	n.decompress = decompressor.Decompress
+	return nil
+}
+
+// decode decompresses the message if needed and decodes it with the codec
+// chosen by the content type header or the subject.
+func (n *NatsClient) decode(msg *stan.Msg) (model.Order, *metrics.DecodeStat, error) {
+	start := time.Now()
+	data, compressed, err := n.decompress(msg.Data)
+	stat := &metrics.DecodeStat{
+		Compression: compressed,
+		WireSize:    len(msg.Data),
+		RawSize:     len(data),
+	}
+	defer func() {
+		stat.Duration = time.Since(start)
+		if compressed != compression.None && stat.WireSize > 0 {
+			stat.CompressionRatio = float64(stat.RawSize) / float64(stat.WireSize)
+		}
+	}()
+	if err != nil {
+		return model.Order{}, stat, err
+	}
+
+	contentType, body, err := codec.SplitHeader(data)
+	if err != nil {
+		return model.Order{}, stat, err
+	}
+
+	var c codec.Codec
+	if contentType != "" {
+		c, err = codec.ByContentType(contentType)
+		if err != nil {
+			return model.Order{}, stat, err
+		}
+	} else if c = n.codecs[msg.Subject]; c == nil {
+		c, _ = codec.ByName("json")
+	}
+
+	order, err := c.Unmarshal(body)
+	return order, stat, err
+}
+
+// SetVerifier enables signature verification of incoming orders.
+// In reject mode unsigned or forged orders are dropped, in quarantine mode they are stored for inspection.
+func (n *NatsClient) SetVerifier(verifier *signature.Verifier, mode string) error {
+	switch mode {
+	case "", signature.ModeOff:
+		n.verifier = nil
+	case signature.ModeReject, signature.ModeQuarantine:
+		n.verifier = verifier
+	default:
+		return fmt.Errorf("unknown signature mode %q", mode)
+	}
+
+	n.sigMode = mode
+	return nil
+}
+
+func (n *NatsClient) verify(order model.Order) error {
+	if n.verifier == nil {
+		return nil
+	}
+
+	err := n.verifier.Verify(order)
+	if err == nil {
+		return nil
+	}
+
+	if n.sigMode == signature.ModeQuarantine {
+		qErr := n.os.Quarantine(context.Background(), order, err.Error())
+		if qErr != nil {
+			return fmt.Errorf("%w, quarantine failed: %w", err, qErr)
+		}
+		return fmt.Errorf("quarantined: %w", err)
+	}
+
+	return err
+}
+
+// reportFailure emits an order.failed event for orders that carry an id.
+func (n *NatsClient) reportFailure(orderUID string, cause error) {
+	if orderUID == "" {
+		return
+	}
+
+	err := n.os.ReportFailure(audit.WithSource(context.Background(), audit.SourceNats), orderUID, cause.Error())
+	if err != nil {
+		n.logger.Info("error", zap.Error(err))
+	}
+}
+
+func (n *NatsClient) setPartitioning(partitionKey string, workers int) error {
+	switch partitionKey {
+	case "":
+		return nil
+	case PartitionByOrderUID:
+		n.keyFunc = func(order model.Order) string { return order.OrderUID }
+	case PartitionByCustomerID:
+		n.keyFunc = func(order model.Order) string { return order.CustomerID }
+	case PartitionByShardkey:
+		n.keyFunc = func(order model.Order) string { return order.Shardkey }
+	default:
+		return fmt.Errorf("unknown partition key %q", partitionKey)
+	}
+
+	n.partitions = make([]chan orderMessage, workers)
+	for i := range n.partitions {
+		n.partitions[i] = make(chan orderMessage)
+	}
+	n.logger.Info("partitioned dispatch enabled", zap.String("key", partitionKey), zap.Int("partitions", workers))
+	return nil
+}
+
+func (n *NatsClient) dispatch(msg orderMessage) {
+	if n.partitions == nil {
+		n.ordersChan <- msg
+		return
+	}
+
+	n.partitions[partition(n.keyFunc(msg.order), len(n.partitions))] <- msg
+}
+
+func partition(key string, partitions int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key)) //nolint:errcheck
+	return int(h.Sum32() % uint32(partitions))
+}
+
 func (n *NatsClient) WorkerSaveRun(workers int) {
 	for i := 0; i < workers; i++ {
-		go func(i int) {
-			for order := range n.ordersChan {
-				err := n.os.Save(context.Background(), order)
+		orders := n.ordersChan
+		if n.partitions != nil {
+			orders = n.partitions[i]
+		}
+		go func(i int, orders chan orderMessage) {
+			for msg := range orders {
+				order, decodeStat := msg.order, msg.decode
+				err := n.os.Save(audit.WithSource(context.Background(), audit.SourceNats), order)
 				if err != nil {
-					n.logger.Info("error", zap.Error(err))
+					n.logger.Info("error", zap.Object("order", order), zap.Error(err))
 					go func(order model.Order) {
 						m := metrics.MessageStat{
 							ID:        order.OrderUID,
 							Status:    "error",
 							Message:   err.Error(),
 							Processed: time.Now().UTC(),
+							Decode:    decodeStat,
 						}
 						n.sp.PushStats(m)
 						n.logger.Info("error", zap.Error(err))
+						n.reportFailure(order.OrderUID, err)
 					}(order)
 				} else {
-					n.logger.Info("saved", zap.String("id", order.OrderUID))
+					n.logger.Info("saved", zap.Object("order", order))
 					go func(order model.Order) {
 						m := metrics.MessageStat{
 							ID:        order.OrderUID,
 							Status:    "success",
 							Message:   "ok",
 							Processed: time.Now().UTC(),
+							Decode:    decodeStat,
 						}
 						n.sp.PushStats(m)
 					}(order)
@@ -156,6 +451,6 @@ func (n *NatsClient) WorkerSaveRun(workers int) {
 			}
 			n.logger.Info("worker stopped", zap.Int("id", i))
 			n.wg.Done()
-		}(i)
+		}(i, orders)
 	}
 }
@@ -0,0 +1,125 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/service"
+)
+
+func TestSetPartitioningKeys(t *testing.T) {
+	order := model.Order{OrderUID: "uid", CustomerID: "customer", Shardkey: "9"}
+	for key, want := range map[string]string{
+		PartitionByOrderUID:   "uid",
+		PartitionByCustomerID: "customer",
+		PartitionByShardkey:   "9",
+	} {
+		n := &NatsClient{logger: zap.NewNop()}
+		require.NoError(t, n.setPartitioning(key, 4), key)
+		assert.Equal(t, want, n.keyFunc(order), key)
+		assert.Len(t, n.partitions, 4, key)
+	}
+
+	n := &NatsClient{logger: zap.NewNop()}
+	require.NoError(t, n.setPartitioning("", 4))
+	assert.Nil(t, n.partitions, "no partitioning without a key")
+	assert.Error(t, n.setPartitioning("track_number", 4))
+}
+
+func TestPartitionSpread(t *testing.T) {
+	const partitions = 8
+	counts := make([]int, partitions)
+	for i := 0; i < 8000; i++ {
+		p := partition(fmt.Sprintf("customer-%d", i), partitions)
+		require.True(t, p >= 0 && p < partitions)
+		counts[p]++
+	}
+	for p, count := range counts {
+		assert.InDelta(t, 1000, count, 250, "partition %d", p)
+	}
+	assert.Equal(t, partition("customer-1", partitions), partition("customer-1", partitions))
+}
+
+func TestDispatchPreservesOrderPerKey(t *testing.T) {
+	n := &NatsClient{logger: zap.NewNop()}
+	require.NoError(t, n.setPartitioning(PartitionByCustomerID, 4))
+
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	var wg sync.WaitGroup
+	for _, orders := range n.partitions {
+		wg.Add(1)
+		go func(orders chan orderMessage) {
+			defer wg.Done()
+			for msg := range orders {
+				mu.Lock()
+				received[msg.order.CustomerID] = append(received[msg.order.CustomerID], msg.order.OrderUID)
+				mu.Unlock()
+			}
+		}(orders)
+	}
+
+	sent := make(map[string][]string)
+	for i := 0; i < 200; i++ {
+		customer := fmt.Sprintf("customer-%d", i%7)
+		uid := fmt.Sprintf("order-%03d", i)
+		sent[customer] = append(sent[customer], uid)
+		n.dispatch(orderMessage{order: model.Order{OrderUID: uid, CustomerID: customer}})
+	}
+	for _, orders := range n.partitions {
+		close(orders)
+	}
+	wg.Wait()
+
+	assert.Equal(t, sent, received)
+}
+
+type fakeOrderService struct {
+	OrderService
+	err error
+}
+
+func (f *fakeOrderService) ChangeStatus(context.Context, model.StatusEvent) error {
+	return f.err
+}
+
+func TestApplyStatusAck(t *testing.T) {
+	valid := []byte(`{"order_uid": "order", "status": "paid"}`)
+	tests := []struct {
+		name         string
+		data         []byte
+		err          error
+		redeliveries uint32
+		ack          bool
+	}{
+		{name: "applied", data: valid, ack: true},
+		{name: "malformed", data: []byte(`{"order_uid":`), ack: true},
+		{name: "missing status", data: []byte(`{"order_uid": "order"}`), ack: true},
+		{name: "unknown status", data: valid, err: service.ErrUnknownStatus, ack: true},
+		{name: "invalid transition", data: valid, err: fmt.Errorf("%w from a to b", service.ErrInvalidTransition), ack: true},
+		{name: "order not saved yet", data: valid, err: pgx.ErrNoRows, ack: false},
+		{name: "concurrent change", data: valid, err: service.ErrStatusConflict, ack: false},
+		{name: "database failure", data: valid, err: errors.New("connection refused"), ack: false},
+		{name: "redeliveries exhausted", data: valid, err: pgx.ErrNoRows, redeliveries: statusMaxRedeliveries, ack: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NatsClient{os: &fakeOrderService{err: tt.err}, validate: validator.New(), logger: zap.NewNop()}
+			_, ack, err := n.applyStatus(tt.data, tt.redeliveries)
+			assert.Equal(t, tt.ack, ack)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
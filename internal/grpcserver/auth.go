@@ -0,0 +1,104 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+	orderv1 "github.com/msmkdenis/wb-order-nats/pkg/api/order/v1"
+)
+
+type Authenticator interface {
+	APIKey(key string) (auth.Principal, error)
+	Bearer(token string) (auth.Principal, error)
+}
+
+// scopes lists the scope required by every method, methods missing here are rejected.
+// Health checks are public.
+var scopes = map[string]string{
+	orderv1.OrderService_GetOrder_FullMethodName:   auth.ScopeOrdersRead,
+	orderv1.OrderService_ListOrders_FullMethodName: auth.ScopeOrdersRead,
+	orderv1.OrderService_SaveOrder_FullMethodName:  auth.ScopeOrdersWrite,
+	orderv1.OrderService_GetStats_FullMethodName:   auth.ScopeStatsRead,
+}
+
+const healthPrefix = "/grpc.health.v1.Health/"
+
+// authInterceptor authenticates calls by the x-api-key or authorization: Bearer metadata,
+// the same credentials the HTTP API accepts.
+type authInterceptor struct {
+	authenticator Authenticator
+	logger        *zap.Logger
+}
+
+func (a *authInterceptor) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authInterceptor) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+func (a *authInterceptor) authorize(ctx context.Context, method string) (context.Context, error) {
+	if strings.HasPrefix(method, healthPrefix) {
+		return ctx, nil
+	}
+
+	scope, ok := scopes[method]
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "unknown method")
+	}
+
+	principal, err := a.authenticate(ctx)
+	if err != nil {
+		a.logger.Info("Unauthenticated", zap.String("method", method), zap.Error(err))
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	if !principal.HasScope(scope) {
+		a.logger.Info("Forbidden", zap.String("subject", principal.Subject), zap.String("scope", scope))
+		return nil, status.Error(codes.PermissionDenied, "insufficient scope, required "+scope)
+	}
+
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+func (a *authInterceptor) authenticate(ctx context.Context) (auth.Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get("x-api-key"); len(keys) > 0 && keys[0] != "" {
+		return a.authenticator.APIKey(keys[0])
+	}
+
+	if values := md.Get("authorization"); len(values) > 0 {
+		scheme, token, ok := strings.Cut(values[0], " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return a.authenticator.Bearer(strings.TrimSpace(token))
+		}
+	}
+
+	return auth.Principal{}, auth.ErrNoCredentials
+}
+
+// serverStream replaces the stream context with the authorized one.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,208 @@
+// Package grpcserver exposes orders and processing statistics over gRPC.
+package grpcserver
+
+//go:generate protoc -I ../../api/proto --go_out=../../pkg/api --go_opt=paths=source_relative --go-grpc_out=../../pkg/api --go-grpc_opt=paths=source_relative order/v1/order_service.proto
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/msmkdenis/wb-order-nats/internal/audit"
+	"github.com/msmkdenis/wb-order-nats/internal/codec"
+	"github.com/msmkdenis/wb-order-nats/internal/metrics"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/pii"
+	orderv1 "github.com/msmkdenis/wb-order-nats/pkg/api/order/v1"
+)
+
+type OrderService interface {
+	Save(ctx context.Context, order model.Order) error
+	FindByID(ctx context.Context, orderID string) (*model.Order, error)
+	FindAll(ctx context.Context) ([]model.Order, error)
+	FindByTrackNumber(ctx context.Context, trackNumber string) ([]model.Order, error)
+	FindByCustomerID(ctx context.Context, customerID string) ([]model.Order, error)
+	FindByStatus(ctx context.Context, status string) ([]model.Order, error)
+}
+
+type StatisticsGetter interface {
+	GetStats() map[string][]metrics.MessageStat
+	GetStatsCount() *metrics.MessageStatCount
+}
+
+// Server serves orderv1.OrderService and the gRPC health service.
+type Server struct {
+	orderv1.UnimplementedOrderServiceServer
+	orderService OrderService
+	statGetter   StatisticsGetter
+	server       *grpc.Server
+	health       *health.Server
+	validate     *validator.Validate
+	logger       *zap.Logger
+}
+
+func NewServer(orderService OrderService, statGetter StatisticsGetter, authenticator Authenticator, logger *zap.Logger) *Server {
+	a := &authInterceptor{authenticator: authenticator, logger: logger}
+	s := &Server{
+		orderService: orderService,
+		statGetter:   statGetter,
+		server:       grpc.NewServer(grpc.UnaryInterceptor(a.unary), grpc.StreamInterceptor(a.stream)),
+		health:       health.NewServer(),
+		validate:     validator.New(),
+		logger:       logger,
+	}
+
+	orderv1.RegisterOrderServiceServer(s.server, s)
+	healthpb.RegisterHealthServer(s.server, s.health)
+	s.health.SetServingStatus(orderv1.OrderService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return s
+}
+
+// Run serves gRPC on the address until Shutdown is called.
+func (s *Server) Run(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("gRPC server started", zap.String("address", address))
+	err = s.server.Serve(listener)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown reports NOT_SERVING to health checks and waits for in-flight calls,
+// running calls are cancelled once ctx is done.
+func (s *Server) Shutdown(ctx context.Context) {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+	s.logger.Info("gRPC server stopped")
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+
+	order, err := s.orderService.FindByID(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	return codec.ToProto(pii.OrderFor(ctx, *order)), nil
+}
+
+func (s *Server) ListOrders(req *orderv1.ListOrdersRequest, stream orderv1.OrderService_ListOrdersServer) error {
+	ctx := stream.Context()
+
+	var orders []model.Order
+	var err error
+	switch {
+	case req.GetCustomerId() != "":
+		orders, err = s.orderService.FindByCustomerID(ctx, req.GetCustomerId())
+	case req.GetTrackNumber() != "":
+		orders, err = s.orderService.FindByTrackNumber(ctx, req.GetTrackNumber())
+	case req.GetStatus() != "":
+		orders, err = s.orderService.FindByStatus(ctx, req.GetStatus())
+	default:
+		orders, err = s.orderService.FindAll(ctx)
+	}
+	if err != nil {
+		return s.toStatus(err)
+	}
+
+	for _, order := range orders {
+		if !matches(req, order) {
+			continue
+		}
+		err = stream.Send(codec.ToProto(pii.OrderFor(ctx, order)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func matches(req *orderv1.ListOrdersRequest, order model.Order) bool {
+	return (req.GetCustomerId() == "" || req.GetCustomerId() == order.CustomerID) &&
+		(req.GetTrackNumber() == "" || req.GetTrackNumber() == order.TrackNumber) &&
+		(req.GetStatus() == "" || req.GetStatus() == order.Status)
+}
+
+func (s *Server) SaveOrder(ctx context.Context, req *orderv1.SaveOrderRequest) (*orderv1.Order, error) {
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order is required")
+	}
+
+	order := codec.FromProto(req.GetOrder())
+	err := s.validate.Struct(order)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = s.orderService.Save(audit.WithSource(ctx, audit.SourceGRPC), order)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	order.Status = model.StatusCreated
+	return codec.ToProto(pii.OrderFor(ctx, order)), nil
+}
+
+func (s *Server) GetStats(_ context.Context, req *orderv1.GetStatsRequest) (*orderv1.GetStatsResponse, error) {
+	counts := s.statGetter.GetStatsCount()
+	resp := &orderv1.GetStatsResponse{
+		Processed:        counts.Processed.Load(),
+		Failed:           counts.Failed.Load(),
+		Compressed:       counts.Compressed.Load(),
+		CompressionRatio: counts.CompressionRatio(),
+		AvgDecodeUs:      float64(counts.AvgDecode()) / float64(time.Microsecond),
+	}
+
+	if req.GetOrderUid() != "" {
+		for _, stat := range s.statGetter.GetStats()[req.GetOrderUid()] {
+			resp.Messages = append(resp.Messages, &orderv1.MessageStat{
+				Id:                stat.ID,
+				Status:            stat.Status,
+				Message:           stat.Message,
+				ProcessedUnixNano: stat.Processed.UnixNano(),
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *Server) toStatus(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status.Error(codes.NotFound, "order not found")
+	}
+
+	s.logger.Error("error", zap.Error(err))
+	return status.Error(codes.Internal, err.Error())
+}
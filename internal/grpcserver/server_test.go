@@ -0,0 +1,135 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+	"github.com/msmkdenis/wb-order-nats/internal/metrics"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	orderv1 "github.com/msmkdenis/wb-order-nats/pkg/api/order/v1"
+)
+
+type fakeOrders struct {
+	orders   []model.Order
+	statuses []string
+}
+
+func (f *fakeOrders) Save(context.Context, model.Order) error { return nil }
+
+func (f *fakeOrders) FindByID(_ context.Context, orderID string) (*model.Order, error) {
+	for _, o := range f.orders {
+		if o.OrderUID == orderID {
+			return &o, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (f *fakeOrders) FindAll(context.Context) ([]model.Order, error) { return f.orders, nil }
+
+func (f *fakeOrders) FindByTrackNumber(context.Context, string) ([]model.Order, error) {
+	return f.orders, nil
+}
+
+func (f *fakeOrders) FindByCustomerID(context.Context, string) ([]model.Order, error) {
+	return f.orders, nil
+}
+
+func (f *fakeOrders) FindByStatus(_ context.Context, status string) ([]model.Order, error) {
+	f.statuses = append(f.statuses, status)
+	var orders []model.Order
+	for _, o := range f.orders {
+		if o.Status == status {
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+func TestServer(t *testing.T) {
+	keys, err := auth.ParseAPIKeys("reader=orders:read;pii=orders:read pii:read")
+	require.NoError(t, err)
+
+	orders := &fakeOrders{orders: []model.Order{
+		{OrderUID: "a", TrackNumber: "T1", Status: model.StatusCreated, Delivery: model.Delivery{Phone: "+79001234567"}},
+		{OrderUID: "b", TrackNumber: "T1", Status: model.StatusPaid},
+	}}
+	server := NewServer(orders, metrics.NewMessageStatsUseCase(zap.NewNop()), auth.NewAuthenticator(keys, nil, "", ""), zap.NewNop())
+
+	listener := bufconn.Listen(1 << 20)
+	go server.server.Serve(listener) //nolint:errcheck
+	defer server.Shutdown(context.Background())
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := orderv1.NewOrderServiceClient(conn)
+	ctx := context.Background()
+	as := func(key string) context.Context { return metadata.AppendToOutgoingContext(ctx, "x-api-key", key) }
+
+	_, err = client.GetOrder(ctx, &orderv1.GetOrderRequest{OrderUid: "a"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetStats(as("reader"), &orderv1.GetStatsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.GetOrder(as("reader"), &orderv1.GetOrderRequest{OrderUid: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	masked, err := client.GetOrder(as("reader"), &orderv1.GetOrderRequest{OrderUid: "a"})
+	require.NoError(t, err)
+	assert.NotEqual(t, "+79001234567", masked.GetDelivery().GetPhone())
+
+	full, err := client.GetOrder(as("pii"), &orderv1.GetOrderRequest{OrderUid: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, "+79001234567", full.GetDelivery().GetPhone())
+
+	stream, err := client.ListOrders(as("reader"), &orderv1.ListOrdersRequest{TrackNumber: "T1", Status: model.StatusPaid})
+	require.NoError(t, err)
+	var listed []string
+	for {
+		order, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		listed = append(listed, order.GetOrderUid())
+	}
+	assert.Equal(t, []string{"b"}, listed)
+
+	stream, err = client.ListOrders(as("reader"), &orderv1.ListOrdersRequest{Status: model.StatusCreated})
+	require.NoError(t, err)
+	listed = nil
+	for {
+		order, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		listed = append(listed, order.GetOrderUid())
+	}
+	assert.Equal(t, []string{"a"}, listed)
+	assert.Equal(t, []string{model.StatusCreated}, orders.statuses, "a status filter is pushed down to the repository")
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: orderv1.OrderService_ServiceDesc.ServiceName})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
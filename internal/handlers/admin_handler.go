@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+	"github.com/msmkdenis/wb-order-nats/internal/cache/memory"
+	"github.com/msmkdenis/wb-order-nats/internal/middleware"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/service"
+)
+
+const (
+	defaultQuarantineLimit = 100
+	maxQuarantineLimit     = 1_000
+)
+
+type AdminService interface {
+	EraseCustomer(ctx context.Context, customerID string) (*service.ErasureResult, error)
+	RestoreCache() error
+	Quarantined(ctx context.Context, limit int) ([]model.QuarantinedOrder, error)
+	Requeue(ctx context.Context, ids []string, all bool) (*service.RequeueResult, error)
+}
+
+type CacheStatter interface {
+	Stats() memory.Stats
+}
+
+// RequeueRequest selects quarantined orders to publish again, either by id or all of them.
+type RequeueRequest struct {
+	IDs []string `json:"ids" validate:"dive,uuid"`
+	All bool     `json:"all"`
+}
+
+type AdminHandler struct {
+	adminService AdminService
+	cache        CacheStatter
+	validator    *validator.Validate
+	logger       *zap.Logger
+}
+
+func NewAdminHandler(e *echo.Echo, service AdminService, cache CacheStatter, authMiddleware *middleware.AuthMiddleware, logger *zap.Logger) *AdminHandler {
+	handler := &AdminHandler{
+		adminService: service,
+		cache:        cache,
+		validator:    validator.New(),
+		logger:       logger,
+	}
+
+	requireAdmin := authMiddleware.Require(auth.ScopeAdmin)
+	e.DELETE("/api/v1/admin/customer/:customerID", handler.EraseCustomer, requireAdmin)
+	e.GET("/api/v1/admin/cache", handler.CacheStats, requireAdmin)
+	e.POST("/api/v1/admin/cache/warm", handler.WarmCache, requireAdmin)
+	e.GET("/api/v1/admin/quarantine", handler.Quarantine, requireAdmin)
+	e.POST("/api/v1/admin/quarantine/requeue", handler.Requeue, requireAdmin)
+
+	return handler
+}
+
+func (h *AdminHandler) EraseCustomer(c echo.Context) error {
+	customerID := c.Param("customerID")
+	if customerID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"Error": "customer id is required"})
+	}
+
+	result, err := h.adminService.EraseCustomer(c.Request().Context(), customerID)
+	if err != nil && result != nil && len(result.OrderUIDs) > 0 {
+		// report the orders erased before the failure, erasing the customer again erases the rest
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]any{"Error": err.Error(), "erased": result})
+	}
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *AdminHandler) CacheStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.cache.Stats())
+}
+
+// WarmCache loads all orders from the database into the cache.
+func (h *AdminHandler) WarmCache(c echo.Context) error {
+	err := h.adminService.RestoreCache()
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, h.cache.Stats())
+}
+
+func (h *AdminHandler) Quarantine(c echo.Context) error {
+	limit := defaultQuarantineLimit
+	if raw := c.QueryParam("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxQuarantineLimit {
+			return c.JSON(http.StatusBadRequest, map[string]string{"Error": "limit must be between 1 and " + strconv.Itoa(maxQuarantineLimit)})
+		}
+	}
+
+	quarantined, err := h.adminService.Quarantined(c.Request().Context(), limit)
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, quarantined)
+}
+
+// Requeue publishes quarantined orders to the orders subject again, e.g. after a signing key was fixed.
+func (h *AdminHandler) Requeue(c echo.Context) error {
+	var request RequeueRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"Error": err.Error()})
+	}
+
+	err = h.validator.Struct(request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"Error": err.Error()})
+	}
+	if len(request.IDs) == 0 && !request.All {
+		return c.JSON(http.StatusBadRequest, map[string]string{"Error": "ids or all are required"})
+	}
+
+	result, err := h.adminService.Requeue(c.Request().Context(), request.IDs, request.All)
+	if errors.Is(err, service.ErrRequeueDisabled) {
+		return c.JSON(http.StatusConflict, map[string]string{"Error": err.Error()})
+	}
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -1,22 +1,39 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
 	"github.com/msmkdenis/wb-order-nats/internal/metrics"
+	"github.com/msmkdenis/wb-order-nats/internal/middleware"
 )
 
 type StatisticsGetter interface {
 	GetStats() map[string][]metrics.MessageStat
 	GetStatsCount() *metrics.MessageStatCount
+	Subscribe(lastID uint64, statuses []string) *metrics.Subscription
+	Unsubscribe(s *metrics.Subscription)
 }
 
+// heartbeatInterval keeps idle stream connections open through proxies.
+const heartbeatInterval = 15 * time.Second
+
 type StatCountsDTO struct {
-	Processed int `json:"processed"`
-	Failed    int `json:"failed"`
+	Processed        int     `json:"processed"`
+	Failed           int     `json:"failed"`
+	Compressed       int     `json:"compressed"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	AvgDecodeMicros  float64 `json:"avg_decode_us"`
+	StatusProcessed  int     `json:"status_processed"`
+	StatusFailed     int     `json:"status_failed"`
 }
 
 type StatisticsHandler struct {
@@ -24,14 +41,15 @@ type StatisticsHandler struct {
 	logger     *zap.Logger
 }
 
-func NewStatisticsHandler(e *echo.Echo, service StatisticsGetter, logger *zap.Logger) *StatisticsHandler {
+func NewStatisticsHandler(e *echo.Echo, service StatisticsGetter, authMiddleware *middleware.AuthMiddleware, logger *zap.Logger) *StatisticsHandler {
 	handler := &StatisticsHandler{
 		statGetter: service,
 		logger:     logger,
 	}
 
-	e.GET("/api/v1/stats", handler.GetStats)
-	e.GET("/api/v1/stats/counts", handler.GetStatsCount)
+	e.GET("/api/v1/stats", handler.GetStats, authMiddleware.Require(auth.ScopeStatsRead))
+	e.GET("/api/v1/stats/counts", handler.GetStatsCount, authMiddleware.Require(auth.ScopeStatsRead))
+	e.GET("/api/v1/stats/stream", handler.Stream, authMiddleware.Require(auth.ScopeStatsRead))
 
 	return handler
 }
@@ -43,11 +61,82 @@ func (h *StatisticsHandler) GetStats(c echo.Context) error {
 }
 
 func (h *StatisticsHandler) GetStatsCount(c echo.Context) error {
-	statCounts := h.statGetter.GetStatsCount()
-	answer := StatCountsDTO{
-		Processed: int(statCounts.Processed.Load()),
-		Failed:    int(statCounts.Failed.Load()),
+	return c.JSON(http.StatusOK, newStatCountsDTO(h.statGetter.GetStatsCount()))
+}
+
+func newStatCountsDTO(statCounts *metrics.MessageStatCount) StatCountsDTO {
+	return StatCountsDTO{
+		Processed:        int(statCounts.Processed.Load()),
+		Failed:           int(statCounts.Failed.Load()),
+		Compressed:       int(statCounts.Compressed.Load()),
+		CompressionRatio: statCounts.CompressionRatio(),
+		AvgDecodeMicros:  float64(statCounts.AvgDecode()) / float64(time.Microsecond),
+		StatusProcessed:  int(statCounts.StatusProcessed.Load()),
+		StatusFailed:     int(statCounts.StatusFailed.Load()),
+	}
+}
+
+// Stream pushes processed message stats as Server-Sent Events. Stats are filtered by the
+// comma separated ?status= list and resumed after the Last-Event-ID header (or ?last_event_id=).
+// The stream ends when the client falls behind, EventSource then reconnects and resumes, and
+// on shutdown.
+func (h *StatisticsHandler) Stream(c echo.Context) error {
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		var err error
+		lastID, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"Error": "invalid last event id"})
+		}
 	}
 
-	return c.JSON(http.StatusOK, answer)
+	var statuses []string
+	if status := c.QueryParam("status"); status != "" {
+		statuses = strings.Split(status, ",")
+	}
+
+	subscription := h.statGetter.Subscribe(lastID, statuses)
+	defer h.statGetter.Unsubscribe(subscription)
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				return nil
+			}
+			w.Flush()
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// the subscriber lagged behind or the server is shutting down
+				h.logger.Info("stats stream subscription closed")
+				return nil
+			}
+			data, err := json.Marshal(event.Stat)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: stat\ndata: %s\n\n", event.ID, data)
+			if err != nil {
+				return nil
+			}
+			w.Flush()
+		}
+	}
 }
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+	"github.com/msmkdenis/wb-order-nats/internal/cache/memory"
+	"github.com/msmkdenis/wb-order-nats/internal/metrics"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/openapi"
+	"github.com/msmkdenis/wb-order-nats/internal/service"
+)
+
+// OpenAPI describes the wborder API. Every route registered by the handlers of this package
+// must be described here, TestOpenAPICoversRoutes fails otherwise.
+func OpenAPI() *openapi.Document {
+	d := openapi.New("wborder", "1.0.0", "Orders consumed from NATS Streaming, processing statistics and administration.")
+	tags := func(tag string) []string { return []string{tag} }
+	internalError := []int{http.StatusInternalServerError}
+
+	d.Add(http.MethodPost, "/api/v1/order", openapi.Operation{
+		Summary: "Save order", Tags: tags("orders"), Scope: auth.ScopeOrdersWrite,
+		Request: model.Order{}, Response: model.Order{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusInternalServerError},
+	})
+	d.Add(http.MethodGet, "/api/v1/order/:orderID", openapi.Operation{
+		Summary: "Get order", Description: "Personal data is masked without the pii:read scope. Archived orders are read from the archive " +
+			"and returned with the header X-Order-Source: archive.",
+		Tags: tags("orders"), Scope: auth.ScopeOrdersRead, Response: model.Order{}, Errors: internalError,
+	})
+	d.Add(http.MethodGet, "/api/v1/order/", openapi.Operation{
+		Summary: "List orders", Tags: tags("orders"), Scope: auth.ScopeOrdersRead, Response: []model.Order{}, Errors: internalError,
+	})
+	d.Add(http.MethodGet, "/api/v1/order/:orderID/history", openapi.Operation{
+		Summary: "Order audit trail", Tags: tags("orders"), Scope: auth.ScopeOrdersRead, Response: []model.OrderAudit{}, Errors: internalError,
+	})
+	d.Add(http.MethodGet, "/api/v1/order/:orderID/status-history", openapi.Operation{
+		Summary: "Order status history", Tags: tags("orders"), Scope: auth.ScopeOrdersRead, Response: []model.StatusChange{}, Errors: internalError,
+	})
+
+	d.Add(http.MethodGet, "/api/v1/stats", openapi.Operation{
+		Summary: "Processed messages by order id", Tags: tags("stats"), Scope: auth.ScopeStatsRead,
+		Response: map[string][]metrics.MessageStat{},
+	})
+	d.Add(http.MethodGet, "/api/v1/stats/counts", openapi.Operation{
+		Summary: "Processing counters", Tags: tags("stats"), Scope: auth.ScopeStatsRead, Response: StatCountsDTO{},
+	})
+	d.Add(http.MethodGet, "/api/v1/stats/stream", openapi.Operation{
+		Summary:     "Live stream of processed messages",
+		Description: "Server-Sent Events, each event has an id, type `stat` and a MessageStat as data.",
+		Tags:        tags("stats"), Scope: auth.ScopeStatsRead, ContentType: "text/event-stream",
+		Query: []openapi.Parameter{
+			{Name: "status", In: "query", Description: "Comma separated statuses to stream, e.g. success,error", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "last_event_id", In: "query", Description: "Resume after the event, same as the Last-Event-ID header", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "Last-Event-ID", In: "header", Description: "Resume after the event", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Errors: []int{http.StatusBadRequest},
+	})
+
+	d.Add(http.MethodDelete, "/api/v1/admin/customer/:customerID", openapi.Operation{
+		Summary: "Erase customer data", Tags: tags("admin"), Scope: auth.ScopeAdmin,
+		Response: service.ErasureResult{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	})
+	d.Add(http.MethodGet, "/api/v1/admin/cache", openapi.Operation{
+		Summary: "Cache statistics", Tags: tags("admin"), Scope: auth.ScopeAdmin, Response: memory.Stats{},
+	})
+	d.Add(http.MethodPost, "/api/v1/admin/cache/warm", openapi.Operation{
+		Summary: "Load all orders into the cache", Tags: tags("admin"), Scope: auth.ScopeAdmin,
+		Response: memory.Stats{}, Errors: internalError,
+	})
+	d.Add(http.MethodGet, "/api/v1/admin/quarantine", openapi.Operation{
+		Summary: "Quarantined orders", Description: "Orders rejected by signature verification, latest first.",
+		Tags: tags("admin"), Scope: auth.ScopeAdmin,
+		Query:    []openapi.Parameter{{Name: "limit", In: "query", Description: "100 by default, 1000 at most", Schema: &openapi.Schema{Type: "integer"}}},
+		Response: []model.QuarantinedOrder{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	})
+	d.Add(http.MethodPost, "/api/v1/admin/quarantine/requeue", openapi.Operation{
+		Summary: "Requeue quarantined orders", Description: "Publishes the orders to the orders subject again through the outbox and removes them from the quarantine.",
+		Tags: tags("admin"), Scope: auth.ScopeAdmin, Request: RequeueRequest{}, Response: service.RequeueResult{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+	})
+
+	d.Add(http.MethodPost, "/api/v1/webhooks", openapi.Operation{
+		Summary: "Subscribe to order events", Description: "The signing secret is generated when omitted and returned only in this response.",
+		Tags: tags("webhooks"), Scope: auth.ScopeAdmin, Request: model.WebhookSubscription{},
+		Status: http.StatusCreated, Response: model.WebhookSubscription{},
+		Errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	})
+	d.Add(http.MethodGet, "/api/v1/webhooks", openapi.Operation{
+		Summary: "List webhook subscriptions", Tags: tags("webhooks"), Scope: auth.ScopeAdmin,
+		Response: []model.WebhookSubscription{}, Errors: internalError,
+	})
+	d.Add(http.MethodDelete, "/api/v1/webhooks/:id", openapi.Operation{
+		Summary: "Delete webhook subscription", Tags: tags("webhooks"), Scope: auth.ScopeAdmin,
+		Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	})
+	d.Add(http.MethodGet, "/api/v1/webhooks/:id/deliveries", openapi.Operation{
+		Summary: "Latest deliveries of the subscription", Tags: tags("webhooks"), Scope: auth.ScopeAdmin,
+		Response: []model.WebhookDelivery{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	})
+
+	uiPage := func(summary string, scope string) openapi.Operation {
+		return openapi.Operation{
+			Summary: summary, Tags: tags("ui"), ContentType: "text/html",
+			Description: "Web UI page, authenticated by the session cookie. Requires `" + scope + "`, redirects to /ui/login otherwise.",
+		}
+	}
+	d.Add(http.MethodGet, "/ui/login", openapi.Operation{Summary: "Login page", Tags: tags("ui"), ContentType: "text/html"})
+	d.Add(http.MethodPost, "/ui/login", openapi.Operation{
+		Summary: "Start a web UI session", Description: "Form field `token` holds an API key or JWT.",
+		Tags: tags("ui"), Status: http.StatusSeeOther,
+	})
+	d.Add(http.MethodGet, "/ui/logout", openapi.Operation{Summary: "End the web UI session", Tags: tags("ui"), Status: http.StatusSeeOther})
+	search := uiPage("Order search", auth.ScopeOrdersRead)
+	search.Query = []openapi.Parameter{
+		{Name: "q", In: "query", Description: "Search value", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "by", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []any{"id", "track", "customer"}}},
+	}
+	d.Add(http.MethodGet, "/ui/", search)
+	d.Add(http.MethodGet, "/ui/orders/:orderID", uiPage("Order page", auth.ScopeOrdersRead))
+	d.Add(http.MethodGet, "/ui/stats", uiPage("Processing statistics page", auth.ScopeStatsRead))
+	d.Add(http.MethodGet, "/ui/failed", uiPage("Failed and quarantined messages page", auth.ScopeStatsRead))
+
+	return d
+}
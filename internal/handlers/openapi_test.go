@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+	"github.com/msmkdenis/wb-order-nats/internal/middleware"
+	"github.com/msmkdenis/wb-order-nats/internal/openapi"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	logger := zap.NewNop()
+	authMiddleware := middleware.NewAuthMiddleware(auth.NewAuthenticator(nil, nil, "", ""), logger)
+
+	e := echo.New()
+	NewOrderHandler(e, nil, middleware.NewCacheMiddleware(nil, logger), authMiddleware, logger)
+	NewStatisticsHandler(e, nil, authMiddleware, logger)
+	NewAdminHandler(e, nil, nil, authMiddleware, logger)
+	NewWebhookHandler(e, nil, authMiddleware, logger)
+	NewUIHandler(e, nil, nil, authMiddleware, logger)
+
+	doc := OpenAPI()
+	openapi.Serve(e, doc)
+
+	assert.Empty(t, doc.Undocumented(openapi.Routes(e)), "routes missing from handlers.OpenAPI()")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var served map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &served))
+	assert.Equal(t, openapi.Version, served["openapi"])
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "swagger-ui")
+}
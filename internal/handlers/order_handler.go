@@ -8,16 +8,27 @@ import (
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/msmkdenis/wb-order-nats/internal/audit"
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
 	"github.com/msmkdenis/wb-order-nats/internal/middleware"
 	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/pii"
 )
 
 type OrderService interface {
 	Save(ctx context.Context, order model.Order) error
-	FindByID(ctx context.Context, orderID string) (*model.Order, error)
+	Lookup(ctx context.Context, orderID string) (*model.Order, bool, error)
 	FindAll(ctx context.Context) ([]model.Order, error)
+	History(ctx context.Context, orderID string) ([]model.OrderAudit, error)
+	StatusHistory(ctx context.Context, orderID string) ([]model.StatusChange, error)
 }
 
+// HeaderOrderSource is set to SourceArchive on orders read from the archive.
+const (
+	HeaderOrderSource = "X-Order-Source"
+	SourceArchive     = "archive"
+)
+
 type OrderHandler struct {
 	orderService OrderService
 	cache        *middleware.CacheMiddleware
@@ -25,7 +36,7 @@ type OrderHandler struct {
 	validator    *validator.Validate
 }
 
-func NewOrderHandler(e *echo.Echo, service OrderService, cache *middleware.CacheMiddleware, logger *zap.Logger) *OrderHandler {
+func NewOrderHandler(e *echo.Echo, service OrderService, cache *middleware.CacheMiddleware, authMiddleware *middleware.AuthMiddleware, logger *zap.Logger) *OrderHandler {
 	handler := &OrderHandler{
 		orderService: service,
 		cache:        cache,
@@ -33,9 +44,11 @@ func NewOrderHandler(e *echo.Echo, service OrderService, cache *middleware.Cache
 		validator:    validator.New(),
 	}
 
-	e.POST("/api/v1/order", handler.SaveOrder)
-	e.GET("/api/v1/order/:orderID", handler.FindOrderByID, cache.GetFromCache())
-	e.GET("/api/v1/order/", handler.FindAll)
+	e.POST("/api/v1/order", handler.SaveOrder, authMiddleware.Require(auth.ScopeOrdersWrite))
+	e.GET("/api/v1/order/:orderID", handler.FindOrderByID, authMiddleware.Require(auth.ScopeOrdersRead), cache.GetFromCache())
+	e.GET("/api/v1/order/", handler.FindAll, authMiddleware.Require(auth.ScopeOrdersRead))
+	e.GET("/api/v1/order/:orderID/history", handler.History, authMiddleware.Require(auth.ScopeOrdersRead))
+	e.GET("/api/v1/order/:orderID/status-history", handler.StatusHistory, authMiddleware.Require(auth.ScopeOrdersRead))
 
 	return handler
 }
@@ -61,25 +74,28 @@ func (h *OrderHandler) SaveOrder(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"Error while validating request": err.Error()})
 	}
 
-	err = h.orderService.Save(context.TODO(), order)
+	err = h.orderService.Save(audit.WithSource(c.Request().Context(), audit.SourceHTTP), order)
 	if err != nil {
 		h.logger.Error("error", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
 	}
 
-	return c.JSON(200, order)
+	return c.JSON(200, pii.OrderFor(c.Request().Context(), order))
 }
 
 func (h *OrderHandler) FindOrderByID(c echo.Context) error {
 	orderID := c.Param("orderID")
 
-	order, err := h.orderService.FindByID(context.Background(), orderID)
+	order, archived, err := h.orderService.Lookup(context.Background(), orderID)
 	if err != nil {
 		h.logger.Error("error", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
 	}
+	if archived {
+		c.Response().Header().Set(HeaderOrderSource, SourceArchive)
+	}
 
-	return c.JSON(200, order)
+	return c.JSON(200, pii.OrderFor(c.Request().Context(), *order))
 }
 
 func (h *OrderHandler) FindAll(c echo.Context) error {
@@ -89,5 +105,29 @@ func (h *OrderHandler) FindAll(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
 	}
 
-	return c.JSON(200, orders)
+	return c.JSON(200, pii.OrdersFor(c.Request().Context(), orders))
+}
+
+func (h *OrderHandler) History(c echo.Context) error {
+	orderID := c.Param("orderID")
+
+	history, err := h.orderService.History(c.Request().Context(), orderID)
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.JSON(200, history)
+}
+
+func (h *OrderHandler) StatusHistory(c echo.Context) error {
+	orderID := c.Param("orderID")
+
+	history, err := h.orderService.StatusHistory(c.Request().Context(), orderID)
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.JSON(200, history)
 }
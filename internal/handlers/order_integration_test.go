@@ -21,6 +21,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/msmkdenis/wb-order-nats/internal/app/natsproducer"
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
 	"github.com/msmkdenis/wb-order-nats/internal/cache/memory"
 	"github.com/msmkdenis/wb-order-nats/internal/config"
 	"github.com/msmkdenis/wb-order-nats/internal/consumer"
@@ -102,18 +103,19 @@ func (s *IntegrationTestSuite) SetupTest() {
 
 	unsubscribe := make(chan struct{})
 	if s.natsClient != nil {
-		err = s.natsClient.OrderProcessingRun("orders", "test-queue", "test-durable", 10, 20, unsubscribe)
+		err = s.natsClient.OrderProcessingRun("orders", "test-queue", "test-durable", 10, 20, "", unsubscribe)
 		if err != nil {
 			logger.Error("failed to run order processing", zap.Error(err))
 		}
 	}
 
 	cacheMiddleware := middleware.NewCacheMiddleware(s.cache, logger)
+	authMiddleware := middleware.NewAuthMiddleware(auth.NewAuthenticator(auth.APIKeys{}, nil, "", ""), logger)
 
 	s.echo = echo.New()
 
-	s.orderHandler = NewOrderHandler(s.echo, s.orderService, cacheMiddleware, logger)
-	s.statisticsHandler = NewStatisticsHandler(s.echo, statService, logger)
+	s.orderHandler = NewOrderHandler(s.echo, s.orderService, cacheMiddleware, authMiddleware, logger)
+	s.statisticsHandler = NewStatisticsHandler(s.echo, statService, authMiddleware, logger)
 
 	producer := natsproducer.New("test-cluster", "test-sender", fmt.Sprintf("http://%s:%d", s.natsHost, s.natsPort.Int()), logger)
 	s.producerHandler = natsproducer.NewProducerHandler(s.echo, producer, logger)
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+	"github.com/msmkdenis/wb-order-nats/internal/metrics"
+	"github.com/msmkdenis/wb-order-nats/internal/middleware"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/pii"
+)
+
+const (
+	uiLoginURL = "/ui/login"
+	// uiListLimit caps failed and quarantined messages shown on the failures page.
+	uiListLimit = 100
+)
+
+type UIOrderService interface {
+	FindByID(ctx context.Context, orderID string) (*model.Order, error)
+	FindByTrackNumber(ctx context.Context, trackNumber string) ([]model.Order, error)
+	FindByCustomerID(ctx context.Context, customerID string) ([]model.Order, error)
+	StatusHistory(ctx context.Context, orderID string) ([]model.StatusChange, error)
+	Quarantined(ctx context.Context, limit int) ([]model.QuarantinedOrder, error)
+}
+
+// page holds the data shared by all UI pages.
+type page struct {
+	Title     string
+	Principal string
+	Error     string
+}
+
+type searchPage struct {
+	page
+	Query  string
+	By     string
+	Orders []model.Order
+}
+
+type orderPage struct {
+	page
+	Order         *model.Order
+	StatusHistory []model.StatusChange
+}
+
+type failureReason struct {
+	Reason string
+	Count  int
+}
+
+type statsPage struct {
+	page
+	Counts  StatCountsDTO
+	Reasons []failureReason
+}
+
+type failedPage struct {
+	page
+	Failed      []metrics.MessageStat
+	Quarantined []model.QuarantinedOrder
+}
+
+// UIHandler serves the built-in web UI for support staff.
+type UIHandler struct {
+	orderService UIOrderService
+	statGetter   StatisticsGetter
+	auth         *middleware.AuthMiddleware
+	logger       *zap.Logger
+}
+
+func NewUIHandler(e *echo.Echo, orderService UIOrderService, statGetter StatisticsGetter, authMiddleware *middleware.AuthMiddleware, logger *zap.Logger) *UIHandler {
+	handler := &UIHandler{
+		orderService: orderService,
+		statGetter:   statGetter,
+		auth:         authMiddleware,
+		logger:       logger,
+	}
+
+	e.GET("/ui/login", handler.LoginForm)
+	e.POST("/ui/login", handler.Login)
+	e.GET("/ui/logout", handler.Logout)
+	e.GET("/ui/", handler.Search, authMiddleware.RequireSession(auth.ScopeOrdersRead, uiLoginURL))
+	e.GET("/ui/orders/:orderID", handler.Order, authMiddleware.RequireSession(auth.ScopeOrdersRead, uiLoginURL))
+	e.GET("/ui/stats", handler.Stats, authMiddleware.RequireSession(auth.ScopeStatsRead, uiLoginURL))
+	e.GET("/ui/failed", handler.Failed, authMiddleware.RequireSession(auth.ScopeStatsRead, uiLoginURL))
+
+	return handler
+}
+
+func newPage(c echo.Context, title string) page {
+	p := page{Title: title}
+	if principal, ok := auth.FromContext(c.Request().Context()); ok {
+		p.Principal = principal.Subject
+	}
+	return p
+}
+
+func (h *UIHandler) LoginForm(c echo.Context) error {
+	return c.Render(http.StatusOK, "login.html", page{Title: "Вход"})
+}
+
+func (h *UIHandler) Login(c echo.Context) error {
+	err := h.auth.StartSession(c, c.FormValue("token"))
+	if err != nil {
+		h.logger.Info("UI login failed", zap.Error(err))
+		return c.Render(http.StatusUnauthorized, "login.html", page{Title: "Вход", Error: "Неверный ключ"})
+	}
+
+	return c.Redirect(http.StatusSeeOther, "/ui/")
+}
+
+func (h *UIHandler) Logout(c echo.Context) error {
+	h.auth.EndSession(c)
+	return c.Redirect(http.StatusSeeOther, uiLoginURL)
+}
+
+// Search finds orders by order_uid, track number or customer id, a single order found by id is opened directly.
+func (h *UIHandler) Search(c echo.Context) error {
+	ctx := c.Request().Context()
+	data := searchPage{page: newPage(c, "Поиск заказа"), Query: c.QueryParam("q"), By: c.QueryParam("by")}
+	if data.By == "" {
+		data.By = "id"
+	}
+	if data.Query == "" {
+		return c.Render(http.StatusOK, "search.html", data)
+	}
+
+	var err error
+	switch data.By {
+	case "id":
+		var order *model.Order
+		order, err = h.orderService.FindByID(ctx, data.Query)
+		if err == nil {
+			return c.Redirect(http.StatusSeeOther, "/ui/orders/"+order.OrderUID)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = nil
+		}
+	case "track":
+		data.Orders, err = h.orderService.FindByTrackNumber(ctx, data.Query)
+	case "customer":
+		data.Orders, err = h.orderService.FindByCustomerID(ctx, data.Query)
+	default:
+		data.Error = "Неизвестный тип поиска"
+		return c.Render(http.StatusBadRequest, "search.html", data)
+	}
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		data.Error = err.Error()
+		return c.Render(http.StatusInternalServerError, "search.html", data)
+	}
+
+	data.Orders = pii.OrdersFor(ctx, data.Orders)
+	return c.Render(http.StatusOK, "search.html", data)
+}
+
+func (h *UIHandler) Order(c echo.Context) error {
+	ctx := c.Request().Context()
+	orderID := c.Param("orderID")
+	data := orderPage{page: newPage(c, "Заказ "+orderID)}
+
+	order, err := h.orderService.FindByID(ctx, orderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		data.Error = "Заказ не найден"
+		return c.Render(http.StatusNotFound, "error.html", data)
+	}
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		data.Error = err.Error()
+		return c.Render(http.StatusInternalServerError, "error.html", data)
+	}
+	masked := pii.OrderFor(ctx, *order)
+	data.Order = &masked
+
+	data.StatusHistory, err = h.orderService.StatusHistory(ctx, orderID)
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		data.Error = err.Error()
+	}
+
+	return c.Render(http.StatusOK, "order.html", data)
+}
+
+// Stats shows processing counters and failures grouped by reason.
+func (h *UIHandler) Stats(c echo.Context) error {
+	data := statsPage{
+		page:   newPage(c, "Статистика обработки"),
+		Counts: newStatCountsDTO(h.statGetter.GetStatsCount()),
+	}
+
+	reasons := make(map[string]int)
+	for _, stat := range h.failedStats() {
+		reasons[stat.Message]++
+	}
+	for reason, count := range reasons {
+		data.Reasons = append(data.Reasons, failureReason{Reason: reason, Count: count})
+	}
+	slices.SortFunc(data.Reasons, func(a, b failureReason) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return cmp.Compare(a.Reason, b.Reason)
+	})
+
+	return c.Render(http.StatusOK, "stats.html", data)
+}
+
+// Failed lists the latest failed messages and quarantined orders.
+func (h *UIHandler) Failed(c echo.Context) error {
+	data := failedPage{page: newPage(c, "Ошибки обработки")}
+
+	data.Failed = h.failedStats()
+	slices.SortFunc(data.Failed, func(a, b metrics.MessageStat) int {
+		return b.Processed.Compare(a.Processed)
+	})
+	if len(data.Failed) > uiListLimit {
+		data.Failed = data.Failed[:uiListLimit]
+	}
+
+	var err error
+	data.Quarantined, err = h.orderService.Quarantined(c.Request().Context(), uiListLimit)
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		data.Error = err.Error()
+	}
+
+	return c.Render(http.StatusOK, "failed.html", data)
+}
+
+func (h *UIHandler) failedStats() []metrics.MessageStat {
+	var failed []metrics.MessageStat
+	for _, stats := range h.statGetter.GetStats() {
+		for _, stat := range stats {
+			if stat.Status == "error" {
+				failed = append(failed, stat)
+			}
+		}
+	}
+	return failed
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+	"github.com/msmkdenis/wb-order-nats/internal/middleware"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/service"
+)
+
+type WebhookService interface {
+	Subscribe(ctx context.Context, subscription model.WebhookSubscription) (*model.WebhookSubscription, error)
+	Subscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	Unsubscribe(ctx context.Context, id string) error
+	Deliveries(ctx context.Context, subscriptionID string) ([]model.WebhookDelivery, error)
+}
+
+type WebhookHandler struct {
+	webhookService WebhookService
+	logger         *zap.Logger
+	validator      *validator.Validate
+}
+
+func NewWebhookHandler(e *echo.Echo, service WebhookService, authMiddleware *middleware.AuthMiddleware, logger *zap.Logger) *WebhookHandler {
+	handler := &WebhookHandler{
+		webhookService: service,
+		logger:         logger,
+		validator:      validator.New(),
+	}
+
+	requireAdmin := authMiddleware.Require(auth.ScopeAdmin)
+	e.POST("/api/v1/webhooks", handler.Subscribe, requireAdmin)
+	e.GET("/api/v1/webhooks", handler.Subscriptions, requireAdmin)
+	e.DELETE("/api/v1/webhooks/:id", handler.Unsubscribe, requireAdmin)
+	e.GET("/api/v1/webhooks/:id/deliveries", handler.Deliveries, requireAdmin)
+
+	return handler
+}
+
+func (h *WebhookHandler) Subscribe(c echo.Context) error {
+	var subscription model.WebhookSubscription
+	err := c.Bind(&subscription)
+	if err != nil {
+		h.logger.Info("Error while binding request", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"Error while binding request": err.Error()})
+	}
+
+	err = h.validator.Struct(subscription)
+	if err != nil {
+		h.logger.Info("Error while validating request", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"Error while validating request": err.Error()})
+	}
+
+	created, err := h.webhookService.Subscribe(c.Request().Context(), subscription)
+	if errors.Is(err, service.ErrInvalidSubscription) {
+		h.logger.Info("Error while validating request", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"Error while validating request": err.Error()})
+	}
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, created)
+}
+
+func (h *WebhookHandler) Subscriptions(c echo.Context) error {
+	subscriptions, err := h.webhookService.Subscriptions(c.Request().Context())
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, subscriptions)
+}
+
+func (h *WebhookHandler) Unsubscribe(c echo.Context) error {
+	id, err := h.subscriptionID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"Error": err.Error()})
+	}
+
+	err = h.webhookService.Unsubscribe(c.Request().Context(), id)
+	if errors.Is(err, service.ErrSubscriptionNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"Error": err.Error()})
+	}
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) Deliveries(c echo.Context) error {
+	id, err := h.subscriptionID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"Error": err.Error()})
+	}
+
+	deliveries, err := h.webhookService.Deliveries(c.Request().Context(), id)
+	if err != nil {
+		h.logger.Error("error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// subscriptionID returns the :id path parameter, subscription ids are UUIDs.
+func (h *WebhookHandler) subscriptionID(c echo.Context) (string, error) {
+	id := c.Param("id")
+	if h.validator.Var(id, "required,uuid") != nil {
+		return "", fmt.Errorf("invalid subscription id %q", id)
+	}
+	return id, nil
+}
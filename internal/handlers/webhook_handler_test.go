@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+type fakeWebhookService struct {
+	WebhookService
+}
+
+func (f *fakeWebhookService) Unsubscribe(context.Context, string) error {
+	return nil
+}
+
+func (f *fakeWebhookService) Deliveries(context.Context, string) ([]model.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func TestWebhookSubscriptionID(t *testing.T) {
+	e := echo.New()
+	handler := &WebhookHandler{webhookService: &fakeWebhookService{}, logger: zap.NewNop(), validator: validator.New()}
+
+	tests := []struct {
+		name   string
+		id     string
+		handle echo.HandlerFunc
+		want   int
+	}{
+		{name: "unsubscribe", id: "2b3a4f7e-5d1c-4c1e-9f3e-7a1b2c3d4e5f", handle: handler.Unsubscribe, want: http.StatusNoContent},
+		{name: "unsubscribe invalid id", id: "42", handle: handler.Unsubscribe, want: http.StatusBadRequest},
+		{name: "deliveries", id: "2b3a4f7e-5d1c-4c1e-9f3e-7a1b2c3d4e5f", handle: handler.Deliveries, want: http.StatusOK},
+		{name: "deliveries invalid id", id: "not-a-uuid", handle: handler.Deliveries, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+
+			assert.NoError(t, tt.handle(c))
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
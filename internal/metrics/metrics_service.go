@@ -9,16 +9,56 @@ import (
 	"go.uber.org/zap"
 )
 
+// KindStatus marks stats of order status events, stats of orders have no kind.
+const KindStatus = "status"
+
 type MessageStat struct {
-	ID        string    `json:"id"`
-	Status    string    `json:"status"`
-	Message   string    `json:"message"`
-	Processed time.Time `json:"processed"`
+	ID        string      `json:"id"`
+	Kind      string      `json:"kind,omitempty"`
+	Status    string      `json:"status"`
+	Message   string      `json:"message"`
+	Processed time.Time   `json:"processed"`
+	Decode    *DecodeStat `json:"decode,omitempty"`
+}
+
+// DecodeStat describes how the message payload was decoded.
+type DecodeStat struct {
+	Compression      string        `json:"compression,omitempty"`
+	CompressionRatio float64       `json:"compression_ratio,omitempty"`
+	WireSize         int           `json:"wire_size"`
+	RawSize          int           `json:"raw_size"`
+	Duration         time.Duration `json:"duration"`
 }
 
 type MessageStatCount struct {
-	Processed atomic.Int64 `json:"processed"`
-	Failed    atomic.Int64 `json:"failed"`
+	Processed           atomic.Int64 `json:"processed"`
+	Failed              atomic.Int64 `json:"failed"`
+	Decoded             atomic.Int64 `json:"decoded"`
+	DecodeNanos         atomic.Int64 `json:"decode_nanos"`
+	Compressed          atomic.Int64 `json:"compressed"`
+	CompressedRawBytes  atomic.Int64 `json:"compressed_raw_bytes"`
+	CompressedWireBytes atomic.Int64 `json:"compressed_wire_bytes"`
+	// status events are counted apart from orders
+	StatusProcessed atomic.Int64 `json:"status_processed"`
+	StatusFailed    atomic.Int64 `json:"status_failed"`
+}
+
+// CompressionRatio returns the average raw to wire size ratio of compressed messages.
+func (c *MessageStatCount) CompressionRatio() float64 {
+	wire := c.CompressedWireBytes.Load()
+	if wire == 0 {
+		return 0
+	}
+	return float64(c.CompressedRawBytes.Load()) / float64(wire)
+}
+
+// AvgDecode returns the average time spent decoding a message.
+func (c *MessageStatCount) AvgDecode() time.Duration {
+	decoded := c.Decoded.Load()
+	if decoded == 0 {
+		return 0
+	}
+	return time.Duration(c.DecodeNanos.Load() / decoded)
 }
 
 type MessageStatsUseCase struct {
@@ -27,6 +67,7 @@ type MessageStatsUseCase struct {
 	logger            *zap.Logger
 	processedMessages chan MessageStat
 	msc               *MessageStatCount
+	stream            *Broadcaster
 }
 
 func NewMessageStatsUseCase(logger *zap.Logger) *MessageStatsUseCase {
@@ -36,6 +77,7 @@ func NewMessageStatsUseCase(logger *zap.Logger) *MessageStatsUseCase {
 		logger:            logger,
 		processedMessages: make(chan MessageStat),
 		msc:               &MessageStatCount{},
+		stream:            NewBroadcaster(),
 	}
 }
 
@@ -45,6 +87,17 @@ func (m *MessageStatsUseCase) PushStats(message MessageStat) {
 
 func (m *MessageStatsUseCase) ProcessedMessagesRun(ctx context.Context) {
 	for msg := range m.processedMessages {
+		if msg.Kind == KindStatus {
+			// status events only go to their counters and the stream, the per order stats
+			// and counts are left to orders
+			m.msc.StatusProcessed.Add(1)
+			if msg.Status == "error" {
+				m.msc.StatusFailed.Add(1)
+			}
+			m.stream.Publish(msg)
+			continue
+		}
+
 		m.mu.Lock()
 		m.statistics[msg.ID] = append(m.statistics[msg.ID], msg)
 		m.mu.Unlock()
@@ -52,9 +105,33 @@ func (m *MessageStatsUseCase) ProcessedMessagesRun(ctx context.Context) {
 		if msg.Status == "error" {
 			m.msc.Failed.Add(1)
 		}
+		if msg.Decode != nil {
+			m.msc.Decoded.Add(1)
+			m.msc.DecodeNanos.Add(int64(msg.Decode.Duration))
+			if msg.Decode.Compression != "" {
+				m.msc.Compressed.Add(1)
+				m.msc.CompressedRawBytes.Add(int64(msg.Decode.RawSize))
+				m.msc.CompressedWireBytes.Add(int64(msg.Decode.WireSize))
+			}
+		}
+		m.stream.Publish(msg)
 	}
 }
 
+// Subscribe streams processed message stats, see Broadcaster.Subscribe.
+func (m *MessageStatsUseCase) Subscribe(lastID uint64, statuses []string) *Subscription {
+	return m.stream.Subscribe(lastID, statuses)
+}
+
+func (m *MessageStatsUseCase) Unsubscribe(s *Subscription) {
+	m.stream.Unsubscribe(s)
+}
+
+// CloseStreams ends all stat streams, call it before shutting the HTTP server down.
+func (m *MessageStatsUseCase) CloseStreams() {
+	m.stream.Close()
+}
+
 func (m *MessageStatsUseCase) GetStats() map[string][]MessageStat {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessedMessagesStatusEvents(t *testing.T) {
+	m := NewMessageStatsUseCase(zap.NewNop())
+	done := make(chan struct{})
+	go func() {
+		m.ProcessedMessagesRun(context.Background())
+		close(done)
+	}()
+
+	m.PushStats(MessageStat{ID: "order1", Status: "success"})
+	m.PushStats(MessageStat{ID: "order1", Kind: KindStatus, Status: "error"})
+	m.PushStats(MessageStat{ID: "order1", Kind: KindStatus, Status: "success"})
+	close(m.processedMessages)
+	<-done
+
+	counts := m.GetStatsCount()
+	assert.Equal(t, int64(1), counts.Processed.Load())
+	assert.Equal(t, int64(0), counts.Failed.Load(), "failed status events are not failed orders")
+	assert.Equal(t, int64(2), counts.StatusProcessed.Load())
+	assert.Equal(t, int64(1), counts.StatusFailed.Load())
+	assert.Equal(t, []MessageStat{{ID: "order1", Status: "success"}}, m.GetStats()["order1"])
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"slices"
+	"sync"
+)
+
+const (
+	// historySize is the number of recent events kept for clients resuming with Last-Event-ID.
+	historySize = 1024
+	// subscriberBuffer is the number of events a subscriber may lag behind before it is dropped.
+	subscriberBuffer = 256
+)
+
+// StreamEvent is a processed message stat with its position in the stream.
+type StreamEvent struct {
+	ID   uint64
+	Stat MessageStat
+}
+
+// Subscription receives stream events until it is closed. Events is closed when the
+// subscriber falls behind, the client is expected to reconnect with the last received id, and
+// when the broadcaster is closed.
+type Subscription struct {
+	Events   <-chan StreamEvent
+	events   chan StreamEvent
+	statuses []string
+}
+
+func (s *Subscription) accepts(event StreamEvent) bool {
+	return len(s.statuses) == 0 || slices.Contains(s.statuses, event.Stat.Status)
+}
+
+// Broadcaster fans processed message stats out to live subscribers without ever blocking the publisher.
+type Broadcaster struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []StreamEvent
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		history:     make([]StreamEvent, 0, historySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next id to the stat and hands it to every subscriber. Subscribers with
+// a full buffer are dropped instead of being waited for.
+func (b *Broadcaster) Publish(stat MessageStat) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := StreamEvent{ID: b.lastID, Stat: stat}
+	if len(b.history) == historySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, event)
+
+	for s := range b.subscribers {
+		if !s.accepts(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			delete(b.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// Subscribe registers a subscriber interested in stats with one of the statuses, all stats when
+// statuses is empty. Kept events after lastID are replayed first, lastID 0 means live events only.
+func (b *Broadcaster) Subscribe(lastID uint64, statuses []string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &Subscription{statuses: statuses}
+	var replay []StreamEvent
+	if lastID > 0 {
+		for _, event := range b.history {
+			if event.ID > lastID && s.accepts(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	s.events = make(chan StreamEvent, len(replay)+subscriberBuffer)
+	for _, event := range replay {
+		s.events <- event
+	}
+	s.Events = s.events
+	if b.closed {
+		close(s.events)
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+
+	return s
+}
+
+// Unsubscribe stops delivery to the subscriber.
+func (b *Broadcaster) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Close closes the events of every subscriber and of those subscribing later, so streams end
+// before a graceful shutdown waits for open connections.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
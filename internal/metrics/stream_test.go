@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drain(s *Subscription) []StreamEvent {
+	var events []StreamEvent
+	for {
+		select {
+		case event, ok := <-s.Events:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster()
+	b.Publish(MessageStat{ID: "1", Status: "success"})
+	b.Publish(MessageStat{ID: "2", Status: "error"})
+	b.Publish(MessageStat{ID: "3", Status: "success"})
+
+	t.Run("replays after last id with filter", func(t *testing.T) {
+		s := b.Subscribe(1, []string{"success"})
+		defer b.Unsubscribe(s)
+
+		events := drain(s)
+		require.Len(t, events, 1)
+		assert.Equal(t, uint64(3), events[0].ID)
+	})
+
+	t.Run("live only without last id", func(t *testing.T) {
+		s := b.Subscribe(0, nil)
+		defer b.Unsubscribe(s)
+
+		b.Publish(MessageStat{ID: "4", Status: "error"})
+		events := drain(s)
+		require.Len(t, events, 1)
+		assert.Equal(t, "4", events[0].Stat.ID)
+	})
+
+	t.Run("slow subscriber is dropped", func(t *testing.T) {
+		s := b.Subscribe(0, nil)
+		for i := 0; i <= subscriberBuffer; i++ {
+			b.Publish(MessageStat{ID: "x", Status: "success"})
+		}
+
+		assert.Len(t, drain(s), subscriberBuffer)
+		_, ok := <-s.Events
+		assert.False(t, ok)
+		b.Unsubscribe(s)
+	})
+}
+
+func TestBroadcasterClose(t *testing.T) {
+	b := NewBroadcaster()
+	s := b.Subscribe(0, nil)
+	b.Close()
+
+	_, ok := <-s.Events
+	assert.False(t, ok, "open subscriptions end on close")
+	b.Unsubscribe(s)
+
+	late := b.Subscribe(0, nil)
+	_, ok = <-late.Events
+	assert.False(t, ok, "subscriptions after close end at once")
+	b.Unsubscribe(late)
+
+	b.Publish(MessageStat{ID: "1", Status: "success"})
+}
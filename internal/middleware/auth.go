@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+)
+
+type Authenticator interface {
+	APIKey(key string) (auth.Principal, error)
+	Bearer(token string) (auth.Principal, error)
+}
+
+type AuthMiddleware struct {
+	authenticator Authenticator
+	logger        *zap.Logger
+}
+
+func NewAuthMiddleware(authenticator Authenticator, logger *zap.Logger) *AuthMiddleware {
+	return &AuthMiddleware{
+		authenticator: authenticator,
+		logger:        logger,
+	}
+}
+
+// Require authenticates the caller by X-API-Key or Authorization: Bearer header
+// and rejects callers without the scope. The principal is put into the request context.
+func (m *AuthMiddleware) Require(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, err := m.authenticate(c.Request())
+			if err != nil {
+				m.logger.Info("Unauthorized", zap.String("URI", c.Request().RequestURI), zap.Error(err))
+				c.Response().Header().Set("WWW-Authenticate", "Bearer")
+				return c.JSON(http.StatusUnauthorized, map[string]string{"Error": "unauthorized"})
+			}
+
+			if !principal.HasScope(scope) {
+				m.logger.Info("Forbidden", zap.String("subject", principal.Subject), zap.String("scope", scope))
+				return c.JSON(http.StatusForbidden, map[string]string{"Error": "insufficient scope, required " + scope})
+			}
+
+			c.SetRequest(c.Request().WithContext(auth.WithPrincipal(c.Request().Context(), principal)))
+			return next(c)
+		}
+	}
+}
+
+// SessionCookie holds the API key or JWT of a web UI session.
+const SessionCookie = "wborder_session"
+
+// RequireSession is Require for web UI pages: credentials are read from the session cookie
+// and callers without a valid session are redirected to loginURL.
+func (m *AuthMiddleware) RequireSession(scope string, loginURL string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cookie, err := c.Cookie(SessionCookie)
+			if err != nil {
+				return c.Redirect(http.StatusSeeOther, loginURL)
+			}
+
+			principal, err := m.authenticateToken(cookie.Value)
+			if err != nil {
+				m.logger.Info("Unauthorized", zap.String("URI", c.Request().RequestURI), zap.Error(err))
+				return c.Redirect(http.StatusSeeOther, loginURL)
+			}
+
+			if !principal.HasScope(scope) {
+				m.logger.Info("Forbidden", zap.String("subject", principal.Subject), zap.String("scope", scope))
+				return c.String(http.StatusForbidden, "insufficient scope, required "+scope)
+			}
+
+			c.SetRequest(c.Request().WithContext(auth.WithPrincipal(c.Request().Context(), principal)))
+			return next(c)
+		}
+	}
+}
+
+// StartSession verifies the API key or JWT and stores it in the session cookie.
+func (m *AuthMiddleware) StartSession(c echo.Context, token string) error {
+	_, err := m.authenticateToken(token)
+	if err != nil {
+		return err
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/ui",
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+func (m *AuthMiddleware) EndSession(c echo.Context) {
+	c.SetCookie(&http.Cookie{
+		Name:     SessionCookie,
+		Path:     "/ui",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// authenticateToken treats tokens of three dot separated parts as JWT and the rest as API keys.
+func (m *AuthMiddleware) authenticateToken(token string) (auth.Principal, error) {
+	if token == "" {
+		return auth.Principal{}, auth.ErrNoCredentials
+	}
+	if strings.Count(token, ".") == 2 {
+		return m.authenticator.Bearer(token)
+	}
+	return m.authenticator.APIKey(token)
+}
+
+func (m *AuthMiddleware) authenticate(r *http.Request) (auth.Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return m.authenticator.APIKey(key)
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return m.authenticator.Bearer(strings.TrimSpace(token))
+	}
+
+	return auth.Principal{}, auth.ErrNoCredentials
+}
@@ -5,6 +5,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/pii"
 )
 
 type CacheGetter interface {
@@ -34,7 +35,7 @@ func (m *CacheMiddleware) GetFromCache() echo.MiddlewareFunc {
 			}
 			c.Response().Header().Set("Content-Type", "application/json")
 			c.Response().Header().Set("X-Cache", "Cached")
-			return c.JSON(200, order)
+			return c.JSON(200, pii.OrderFor(c.Request().Context(), order))
 		}
 	}
 }
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type OrderAudit struct {
+	ID        int64           `json:"id" db:"id"`
+	OrderUID  string          `json:"order_uid" db:"order_uid"`
+	Action    string          `json:"action" db:"action"`
+	Source    string          `json:"source" db:"source"`
+	Principal string          `json:"principal" db:"principal"`
+	Diff      json.RawMessage `json:"diff" db:"diff"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"go.uber.org/zap/zapcore"
+
+	"github.com/msmkdenis/wb-order-nats/pkg/mask"
+)
+
+// MarshalLogObject writes the delivery to zap logs with personal data masked.
+func (d Delivery) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", mask.Name(d.Name))
+	enc.AddString("phone", mask.Phone(d.Phone))
+	enc.AddString("zip", mask.Prefix(3)(d.Zip))
+	enc.AddString("city", d.City)
+	enc.AddString("address", mask.Redact(d.Address))
+	enc.AddString("region", d.Region)
+	enc.AddString("email", mask.Email(d.Email))
+	return nil
+}
+
+// MarshalLogObject writes order identifiers and the masked delivery to zap logs.
+func (o Order) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("order_uid", o.OrderUID)
+	enc.AddString("track_number", o.TrackNumber)
+	enc.AddString("customer_id", o.CustomerID)
+	enc.AddInt("items", len(o.Items))
+	enc.AddString("date_created", o.DateCreated)
+	enc.AddString("status", o.Status)
+	return enc.AddObject("delivery", o.Delivery)
+}
@@ -15,4 +15,5 @@ type Order struct {
 	SmID              int      `json:"sm_id" db:"sm_id" validate:"required"`
 	DateCreated       string   `json:"date_created" db:"date_created" validate:"required"`
 	OofShard          string   `json:"oof_shard" db:"oof_shard" validate:"required"`
+	Status            string   `json:"status,omitempty" db:"status"`
 }
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxMessage is a message written to the outbox together with an order change
+// and published to NATS Streaming by the outbox relay.
+type OutboxMessage struct {
+	ID       int64           `db:"id"`
+	Subject  string          `db:"subject"`
+	Payload  json.RawMessage `db:"payload"`
+	Attempts int             `db:"attempts"`
+}
+
+// OrderSaved is the normalised event republished for other services once an order is committed,
+// the order carries masked personal data.
+type OrderSaved struct {
+	Type       string    `json:"type"`
+	OrderUID   string    `json:"order_uid"`
+	CustomerID string    `json:"customer_id"`
+	Status     string    `json:"status"`
+	SavedAt    time.Time `json:"saved_at"`
+	Order      Order     `json:"order"`
+}
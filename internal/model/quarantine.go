@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// QuarantinedOrder is an order rejected by signature verification, see wb_demo.quarantine.
+type QuarantinedOrder struct {
+	ID        string    `json:"id" db:"id"`
+	OrderUID  string    `json:"order_uid" db:"order_uid"`
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Order statuses.
+const (
+	StatusCreated    = "created"
+	StatusPaid       = "paid"
+	StatusAssembling = "assembling"
+	StatusShipped    = "shipped"
+	StatusDelivered  = "delivered"
+	StatusCancelled  = "cancelled"
+	StatusReturned   = "returned"
+)
+
+// StatusEvent requests a change of the order status.
+type StatusEvent struct {
+	OrderUID string `json:"order_uid" validate:"required"`
+	Status   string `json:"status" validate:"required"`
+	Reason   string `json:"reason"`
+}
+
+// StatusChange is an entry of the order status history.
+type StatusChange struct {
+	ID         int64     `json:"id" db:"id"`
+	OrderUID   string    `json:"order_uid" db:"order_uid"`
+	FromStatus *string   `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	Reason     string    `json:"reason" db:"reason"`
+	Source     string    `json:"source" db:"source"`
+	ChangedAt  time.Time `json:"changed_at" db:"changed_at"`
+}
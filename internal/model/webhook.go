@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Order events delivered to webhook subscribers.
+const (
+	EventOrderSaved   = "order.saved"
+	EventOrderFailed  = "order.failed"
+	EventOrderUpdated = "order.updated"
+)
+
+// Event is an order event written to the webhook outbox.
+type Event struct {
+	Type     string
+	OrderUID string
+	Payload  json.RawMessage
+}
+
+// PendingEvent is a webhook event not fanned out to subscriptions yet.
+type PendingEvent struct {
+	ID        string          `db:"id"`
+	Type      string          `db:"event_type"`
+	OrderUID  string          `db:"order_uid"`
+	Payload   json.RawMessage `db:"payload"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+type WebhookSubscription struct {
+	ID        string    `json:"id" db:"id"`
+	URL       string    `json:"url" db:"url" validate:"required,url"`
+	Secret    string    `json:"secret,omitempty" db:"secret"`
+	Events    []string  `json:"events" db:"events" validate:"required,min=1,dive,oneof=order.saved order.failed order.updated"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string    `json:"id" db:"id"`
+	SubscriptionID string    `json:"subscription_id" db:"subscription_id"`
+	EventID        string    `json:"event_id" db:"event_id"`
+	EventType      string    `json:"event_type" db:"event_type"`
+	OrderUID       string    `json:"order_uid" db:"order_uid"`
+	Status         string    `json:"status" db:"status"`
+	Attempts       int       `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int      `json:"last_status_code" db:"last_status_code"`
+	LastError      string    `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PendingDelivery is a webhook delivery leased for sending.
+type PendingDelivery struct {
+	ID        string          `db:"id"`
+	Attempts  int             `db:"attempts"`
+	URL       string          `db:"url"`
+	Secret    string          `db:"secret"`
+	EventID   string          `db:"event_id"`
+	EventType string          `db:"event_type"`
+	OrderUID  string          `db:"order_uid"`
+	Payload   json.RawMessage `db:"payload"`
+	CreatedAt time.Time       `db:"created_at"`
+}
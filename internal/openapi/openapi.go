@@ -0,0 +1,208 @@
+// Package openapi builds OpenAPI 3 documents from handler descriptions and model types
+// and serves them together with Swagger UI.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.0.3"
+
+// Security scheme names, see Document.Add.
+const (
+	SchemeAPIKey = "apiKey"
+	SchemeBearer = "bearer"
+)
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]*PathItem `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// PathItem is an OpenAPI operation object.
+type PathItem struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Operation describes a route for Document.Add.
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// Scope is the scope required by the auth middleware, empty for public routes.
+	Scope string
+	// Query lists query parameters, path parameters are derived from the path.
+	Query []Parameter
+	// Request is a value of the JSON request body type, nil without a body.
+	Request any
+	// Status is the success status code, 200 by default.
+	Status int
+	// Response is a value of the success response body type, nil for an empty response.
+	Response any
+	// ContentType of the success response, application/json by default.
+	ContentType string
+	// Errors lists error status codes returned with the {"Error": "..."} body.
+	Errors []int
+}
+
+// ErrorBody is the error response of the API.
+type ErrorBody struct {
+	Error string `json:"Error"`
+}
+
+func New(title string, version string, description string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version, Description: description},
+		Paths:   make(map[string]map[string]*PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				SchemeAPIKey: {Type: "apiKey", In: "header", Name: "X-API-Key"},
+				SchemeBearer: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+}
+
+var echoParam = regexp.MustCompile(`:(\w+)`)
+
+// Add describes the route registered in echo with the method and path, e.g. GET /api/v1/order/:orderID.
+func (d *Document) Add(method string, path string, op Operation) {
+	item := &PathItem{
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Responses:   make(map[string]*Response),
+	}
+
+	for _, m := range echoParam.FindAllStringSubmatch(path, -1) {
+		item.Parameters = append(item.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	item.Parameters = append(item.Parameters, op.Query...)
+
+	if op.Request != nil {
+		item.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: d.schemaOf(reflect.TypeOf(op.Request))}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if op.Response != nil || op.ContentType != "" {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		schema := &Schema{Type: "string"}
+		if op.Response != nil {
+			schema = d.schemaOf(reflect.TypeOf(op.Response))
+		}
+		success.Content = map[string]*MediaType{contentType: {Schema: schema}}
+	}
+	item.Responses[strconv.Itoa(status)] = success
+
+	errors := op.Errors
+	if op.Scope != "" {
+		item.Security = []map[string][]string{{SchemeAPIKey: {}}, {SchemeBearer: {op.Scope}}}
+		item.Description = strings.TrimSpace(item.Description + "\n\nRequired scope: `" + op.Scope + "`.")
+		errors = append(errors, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	for _, code := range errors {
+		item.Responses[strconv.Itoa(code)] = &Response{
+			Description: http.StatusText(code),
+			Content:     map[string]*MediaType{"application/json": {Schema: d.schemaOf(reflect.TypeOf(ErrorBody{}))}},
+		}
+	}
+
+	openAPIPath := echoParam.ReplaceAllString(path, "{$1}")
+	if d.Paths[openAPIPath] == nil {
+		d.Paths[openAPIPath] = make(map[string]*PathItem)
+	}
+	d.Paths[openAPIPath][strings.ToLower(method)] = item
+}
+
+// Has reports whether the echo route is described.
+func (d *Document) Has(method string, path string) bool {
+	_, ok := d.Paths[echoParam.ReplaceAllString(path, "{$1}")][strings.ToLower(method)]
+	return ok
+}
+
+// Route is a method and path pair as registered in echo.
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Undocumented returns the routes missing from the document, sorted by path.
+func (d *Document) Undocumented(routes []Route) []Route {
+	var missing []Route
+	for _, r := range routes {
+		if !d.Has(r.Method, r.Path) {
+			missing = append(missing, r)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Path+missing[i].Method < missing[j].Path+missing[j].Method
+	})
+	return missing
+}
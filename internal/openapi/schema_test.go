@@ -0,0 +1,46 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+type order struct {
+	ID     string   `json:"id" validate:"required,uuid"`
+	Amount *int     `json:"amount" validate:"gte=0,required"`
+	Tags   []string `json:"tags" validate:"min=1,dive,oneof=a b"`
+	Items  []item   `json:"items"`
+	Note   string   `json:"note,omitempty"`
+	hidden string   //nolint:unused
+}
+
+func TestSchema(t *testing.T) {
+	d := New("test", "1", "")
+	d.Add(http.MethodPost, "/orders/:id", Operation{Scope: "orders:write", Request: order{}, Response: order{}, Errors: []int{http.StatusBadRequest}})
+
+	op := d.Paths["/orders/{id}"]["post"]
+	require.NotNil(t, op)
+	assert.Equal(t, "id", op.Parameters[0].Name)
+	assert.Equal(t, "#/components/schemas/order", op.RequestBody.Content["application/json"].Schema.Ref)
+	assert.Contains(t, op.Responses, "401")
+	assert.Contains(t, op.Responses, "400")
+	assert.True(t, d.Has(http.MethodPost, "/orders/:id"))
+
+	s := d.Components.Schemas["order"]
+	assert.Equal(t, []string{"id", "amount"}, s.Required)
+	assert.Equal(t, "uuid", s.Properties["id"].Format)
+	assert.True(t, s.Properties["amount"].Nullable)
+	assert.Equal(t, 0.0, *s.Properties["amount"].Minimum)
+	assert.Equal(t, 1, *s.Properties["tags"].MinItems)
+	assert.Equal(t, []any{"a", "b"}, s.Properties["tags"].Items.Enum)
+	assert.NotContains(t, s.Properties, "hidden")
+
+	assert.Equal(t, 64, *d.Components.Schemas["item"].Properties["name"].MaxLength)
+}
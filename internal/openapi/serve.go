@@ -0,0 +1,48 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// swaggerInitializer points the embedded Swagger UI at the served document.
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// Serve registers GET /openapi.json and Swagger UI under /docs/ and describes both in the document.
+func Serve(e *echo.Echo, d *Document) {
+	d.Add(http.MethodGet, "/openapi.json", Operation{Summary: "OpenAPI document", Tags: []string{"docs"}, ContentType: "application/json"})
+	d.Add(http.MethodGet, "/docs", Operation{Summary: "Swagger UI", Tags: []string{"docs"}, Status: http.StatusMovedPermanently})
+	d.Add(http.MethodGet, "/docs/swagger-initializer.js", Operation{Summary: "Swagger UI configuration", Tags: []string{"docs"}, ContentType: "application/javascript"})
+	d.Add(http.MethodGet, "/docs/*", Operation{Summary: "Swagger UI assets", Tags: []string{"docs"}, ContentType: "text/html"})
+
+	e.GET("/openapi.json", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, d)
+	})
+	e.GET("/docs", func(c echo.Context) error {
+		return c.Redirect(http.StatusMovedPermanently, "/docs/")
+	})
+	e.GET("/docs/swagger-initializer.js", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, "application/javascript", []byte(swaggerInitializer))
+	})
+	e.GET("/docs/*", echo.WrapHandler(http.StripPrefix("/docs/", http.FileServer(http.FS(swaggerFiles.FS)))))
+}
+
+// Routes returns the routes registered in echo.
+func Routes(e *echo.Echo) []Route {
+	var routes []Route
+	for _, r := range e.Routes() {
+		routes = append(routes, Route{Method: r.Method, Path: r.Path})
+	}
+	return routes
+}
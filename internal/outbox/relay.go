@@ -0,0 +1,90 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/pkg/backoff"
+)
+
+const (
+	batchSize = 100
+	// lease postpones leased messages, so they are published again if the relay dies mid-batch.
+	lease      = 30 * time.Second
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+type Repository interface {
+	Lease(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, nextAttempt time.Time, lastError string) error
+}
+
+// Publisher publishes a message and waits for the server acknowledgement.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// Relay publishes messages committed to the outbox. A message is marked sent only after
+// NATS acknowledged it, so it is published at least once, consumers must deduplicate.
+type Relay struct {
+	repository Repository
+	publisher  Publisher
+	interval   time.Duration
+	logger     *zap.Logger
+}
+
+func NewRelay(repository Repository, publisher Publisher, interval time.Duration, logger *zap.Logger) *Relay {
+	return &Relay{
+		repository: repository,
+		publisher:  publisher,
+		interval:   interval,
+		logger:     logger,
+	}
+}
+
+// Run relays pending messages every interval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("outbox relay stopped")
+			return
+		case <-ticker.C:
+			r.relay(ctx)
+		}
+	}
+}
+
+func (r *Relay) relay(ctx context.Context) {
+	messages, err := r.repository.Lease(ctx, batchSize, lease)
+	if err != nil {
+		r.logger.Error("unable to lease outbox messages", zap.Error(err))
+		return
+	}
+
+	for _, m := range messages {
+		err = r.publisher.Publish(m.Subject, m.Payload)
+		if err != nil {
+			// The connection is most likely broken, the rest of the batch is retried once the lease expires.
+			r.logger.Info("unable to publish outbox message", zap.Int64("id", m.ID), zap.Int("attempt", m.Attempts+1), zap.Error(err))
+			err = r.repository.MarkFailed(ctx, m.ID, time.Now().Add(backoff.Exponential(m.Attempts+1, minBackoff, maxBackoff)), err.Error())
+			if err != nil {
+				r.logger.Error("unable to mark outbox message failed", zap.Int64("id", m.ID), zap.Error(err))
+			}
+			return
+		}
+
+		err = r.repository.MarkSent(ctx, m.ID)
+		if err != nil {
+			r.logger.Error("unable to mark outbox message sent", zap.Int64("id", m.ID), zap.Error(err))
+		}
+	}
+}
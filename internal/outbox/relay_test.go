@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+type fakeRepository struct {
+	messages []model.OutboxMessage
+	sent     []int64
+	failed   []int64
+}
+
+func (f *fakeRepository) Lease(context.Context, int, time.Duration) ([]model.OutboxMessage, error) {
+	return f.messages, nil
+}
+
+func (f *fakeRepository) MarkSent(_ context.Context, id int64) error {
+	f.sent = append(f.sent, id)
+	return nil
+}
+
+func (f *fakeRepository) MarkFailed(_ context.Context, id int64, _ time.Time, _ string) error {
+	f.failed = append(f.failed, id)
+	return nil
+}
+
+type fakePublisher struct {
+	failOn int64
+	calls  int64
+}
+
+func (f *fakePublisher) Publish(string, []byte) error {
+	f.calls++
+	if f.calls == f.failOn {
+		return errors.New("nats: connection closed")
+	}
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	repo := &fakeRepository{messages: []model.OutboxMessage{{ID: 1}, {ID: 2}, {ID: 3}}}
+	NewRelay(repo, &fakePublisher{failOn: 2}, time.Second, zap.NewNop()).relay(context.Background())
+
+	assert.Equal(t, []int64{1}, repo.sent)
+	assert.Equal(t, []int64{2}, repo.failed)
+}
@@ -0,0 +1,127 @@
+// Package partition maintains the monthly partitions of the order tables.
+package partition
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Retention modes of old partitions.
+const (
+	// RetentionDetach moves old partitions to the wb_demo_archive schema.
+	RetentionDetach = "detach"
+	// RetentionDrop drops old partitions.
+	RetentionDrop = "drop"
+)
+
+type Repository interface {
+	CreatePartitions(ctx context.Context, ahead int) ([]string, error)
+	DetachPartitions(ctx context.Context, cutoff time.Time, drop bool) ([]string, []string, error)
+}
+
+type Cache interface {
+	DeleteOrder(key string)
+}
+
+// ShardIndex forgets the shards of orders removed from their shard.
+type ShardIndex interface {
+	DeleteShards(ctx context.Context, orderUIDs []string) error
+}
+
+// Maintainer creates partitions ahead of time and removes partitions older than the retention.
+type Maintainer struct {
+	repository Repository
+	cache      Cache
+	shards     ShardIndex
+	ahead      int
+	retention  int
+	drop       bool
+	interval   time.Duration
+	logger     *zap.Logger
+}
+
+// NewMaintainer creates a maintainer keeping ahead months of partitions ready and retention
+// months of old partitions attached, zero retention keeps all of them. Orders of removed
+// partitions are evicted from the cache.
+func NewMaintainer(repository Repository, cache Cache, ahead int, retention int, mode string, interval time.Duration, logger *zap.Logger) (*Maintainer, error) {
+	if mode != RetentionDetach && mode != RetentionDrop {
+		return nil, fmt.Errorf("unknown partition retention mode %q", mode)
+	}
+	if ahead < 0 || retention < 0 {
+		return nil, fmt.Errorf("partition months must not be negative")
+	}
+
+	return &Maintainer{
+		repository: repository,
+		cache:      cache,
+		ahead:      ahead,
+		retention:  retention,
+		drop:       mode == RetentionDrop,
+		interval:   interval,
+		logger:     logger,
+	}, nil
+}
+
+// SetShardIndex makes the maintainer delete the order_shard rows of orders of removed partitions.
+func (m *Maintainer) SetShardIndex(shards ShardIndex) {
+	m.shards = shards
+}
+
+// Run maintains partitions right away and then every interval until ctx is done.
+func (m *Maintainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.maintain(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			m.logger.Info("partition maintainer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Maintainer) maintain(ctx context.Context, now time.Time) {
+	created, err := m.repository.CreatePartitions(ctx, m.ahead)
+	if err != nil {
+		m.logger.Error("unable to create partitions", zap.Error(err))
+	} else if len(created) > 0 {
+		m.logger.Info("partitions created", zap.Strings("partitions", created))
+	}
+
+	if m.retention == 0 {
+		return
+	}
+
+	detached, orderUIDs, err := m.repository.DetachPartitions(ctx, Cutoff(now, m.retention), m.drop)
+	if err != nil {
+		m.logger.Error("unable to detach partitions", zap.Error(err))
+		return
+	}
+	if len(detached) > 0 {
+		m.logger.Info("partitions detached", zap.Strings("partitions", detached), zap.Int("orders", len(orderUIDs)), zap.Bool("dropped", m.drop))
+	}
+
+	for _, orderUID := range orderUIDs {
+		m.cache.DeleteOrder(orderUID)
+	}
+	if m.shards != nil && len(orderUIDs) > 0 {
+		err = m.shards.DeleteShards(ctx, orderUIDs)
+		if err != nil {
+			m.logger.Error("unable to delete shards of detached orders", zap.Error(err))
+		}
+	}
+}
+
+// Cutoff returns the start of the oldest month kept by the retention, the current month counts
+// as the first one.
+func Cutoff(now time.Time, retention int) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month()-time.Month(retention-1), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,79 @@
+package partition
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeRepository struct {
+	ahead     int
+	cutoff    time.Time
+	drop      bool
+	detached  bool
+	createErr error
+}
+
+func (f *fakeRepository) CreatePartitions(_ context.Context, ahead int) ([]string, error) {
+	f.ahead = ahead
+	return nil, f.createErr
+}
+
+func (f *fakeRepository) DetachPartitions(_ context.Context, cutoff time.Time, drop bool) ([]string, []string, error) {
+	f.cutoff, f.drop, f.detached = cutoff, drop, true
+	return []string{"p202301"}, []string{"order1", "order2"}, nil
+}
+
+type fakeCache struct {
+	deleted []string
+}
+
+func (f *fakeCache) DeleteOrder(key string) {
+	f.deleted = append(f.deleted, key)
+}
+
+type fakeShardIndex struct {
+	deleted []string
+}
+
+func (f *fakeShardIndex) DeleteShards(_ context.Context, orderUIDs []string) error {
+	f.deleted = append(f.deleted, orderUIDs...)
+	return nil
+}
+
+func TestCutoff(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), Cutoff(now, 1))
+	assert.Equal(t, time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC), Cutoff(now, 12))
+}
+
+func TestMaintain(t *testing.T) {
+	now := time.Date(2024, time.March, 3, 0, 0, 0, 0, time.UTC)
+
+	repository := &fakeRepository{createErr: errors.New("locked")}
+	cache, shards := &fakeCache{}, &fakeShardIndex{}
+	m, err := NewMaintainer(repository, cache, 3, 6, RetentionDrop, time.Hour, zap.NewNop())
+	require.NoError(t, err)
+	m.SetShardIndex(shards)
+	m.maintain(context.Background(), now)
+	assert.Equal(t, 3, repository.ahead)
+	assert.True(t, repository.detached, "retention runs even if creating partitions failed")
+	assert.True(t, repository.drop)
+	assert.Equal(t, time.Date(2023, time.October, 1, 0, 0, 0, 0, time.UTC), repository.cutoff)
+	assert.Equal(t, []string{"order1", "order2"}, cache.deleted, "orders of removed partitions leave the cache")
+	assert.Equal(t, []string{"order1", "order2"}, shards.deleted, "orders of removed partitions lose their shard")
+
+	repository = &fakeRepository{}
+	m, err = NewMaintainer(repository, &fakeCache{}, 3, 0, RetentionDetach, time.Hour, zap.NewNop())
+	require.NoError(t, err)
+	m.maintain(context.Background(), now)
+	assert.False(t, repository.detached, "zero retention keeps all partitions")
+
+	_, err = NewMaintainer(repository, &fakeCache{}, 3, 0, "archive", time.Hour, zap.NewNop())
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+// Package pii implements masking of personal data in orders.
+package pii
+
+import (
+	"context"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/pkg/mask"
+)
+
+// Masker masks a single field value.
+type Masker func(value string) string
+
+// Policy defines maskers for delivery fields, a nil masker keeps the value as is.
+type Policy struct {
+	Name    Masker
+	Phone   Masker
+	Zip     Masker
+	City    Masker
+	Address Masker
+	Region  Masker
+	Email   Masker
+}
+
+// DefaultPolicy masks everything that identifies the recipient and keeps city and region.
+var DefaultPolicy = Policy{
+	Name:    mask.Name,
+	Phone:   mask.Phone,
+	Zip:     mask.Prefix(3),
+	Address: mask.Redact,
+	Email:   mask.Email,
+}
+
+// Erased replaces personal data of erased customers, it matches the anonymise queries of the repository.
+const Erased = "[erased]"
+
+// Erase returns the order with the personal delivery data replaced by Erased, city and region are kept.
+func Erase(o model.Order) model.Order {
+	o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip = Erased, Erased, Erased
+	o.Delivery.Address, o.Delivery.Email = Erased, Erased
+	return o
+}
+
+// Delivery returns the delivery with masked fields.
+func (p Policy) Delivery(d model.Delivery) model.Delivery {
+	return model.Delivery{
+		Name:    apply(p.Name, d.Name),
+		Phone:   apply(p.Phone, d.Phone),
+		Zip:     apply(p.Zip, d.Zip),
+		City:    apply(p.City, d.City),
+		Address: apply(p.Address, d.Address),
+		Region:  apply(p.Region, d.Region),
+		Email:   apply(p.Email, d.Email),
+	}
+}
+
+// Order returns the order with masked delivery.
+func (p Policy) Order(o model.Order) model.Order {
+	o.Delivery = p.Delivery(o.Delivery)
+	return o
+}
+
+// Orders returns a copy of orders with masked deliveries.
+func (p Policy) Orders(orders []model.Order) []model.Order {
+	masked := make([]model.Order, 0, len(orders))
+	for _, o := range orders {
+		masked = append(masked, p.Order(o))
+	}
+	return masked
+}
+
+// CanSeePII reports whether the caller in ctx is granted unmasked personal data.
+func CanSeePII(ctx context.Context) bool {
+	principal, ok := auth.FromContext(ctx)
+	return ok && principal.HasScope(auth.ScopePIIRead)
+}
+
+// OrderFor masks the order unless the caller in ctx may see personal data.
+func OrderFor(ctx context.Context, o model.Order) model.Order {
+	if CanSeePII(ctx) {
+		return o
+	}
+	return DefaultPolicy.Order(o)
+}
+
+// OrdersFor masks the orders unless the caller in ctx may see personal data.
+func OrdersFor(ctx context.Context, orders []model.Order) []model.Order {
+	if CanSeePII(ctx) {
+		return orders
+	}
+	return DefaultPolicy.Orders(orders)
+}
+
+func apply(m Masker, value string) string {
+	if m == nil || value == "" {
+		return value
+	}
+	return m(value)
+}
@@ -0,0 +1,73 @@
+package pii
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/msmkdenis/wb-order-nats/internal/auth"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/pkg/mask"
+)
+
+func TestOrderFor(t *testing.T) {
+	order := model.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+79161231234",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+	}
+	masked := model.Delivery{
+		Name:    "T*** T***",
+		Phone:   "+7***1234",
+		Zip:     "263***",
+		City:    "Kiryat Mozkin",
+		Address: mask.Hidden,
+		Region:  "Kraiot",
+		Email:   "t***@gmail.com",
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want model.Delivery
+	}{
+		{name: "anonymous", ctx: context.Background(), want: masked},
+		{name: "orders reader", ctx: withScopes(auth.ScopeOrdersRead), want: masked},
+		{name: "pii reader", ctx: withScopes(auth.ScopeOrdersRead, auth.ScopePIIRead), want: order.Delivery},
+		{name: "admin", ctx: withScopes(auth.ScopeAdmin), want: order.Delivery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := OrderFor(tt.ctx, order)
+			assert.Equal(t, tt.want, got.Delivery)
+			assert.Equal(t, order.OrderUID, got.OrderUID)
+
+			orders := OrdersFor(tt.ctx, []model.Order{order, order})
+			assert.Len(t, orders, 2)
+			for _, o := range orders {
+				assert.Equal(t, tt.want, o.Delivery)
+			}
+		})
+	}
+
+	assert.Equal(t, "Test Testov", order.Delivery.Name, "the original order is left as is")
+}
+
+func TestCanSeePII(t *testing.T) {
+	assert.False(t, CanSeePII(context.Background()))
+	assert.False(t, CanSeePII(withScopes(auth.ScopeStatsRead)))
+	assert.True(t, CanSeePII(withScopes(auth.ScopePIIRead)))
+	assert.True(t, CanSeePII(withScopes(auth.ScopeAdmin)))
+}
+
+func withScopes(scopes ...string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{Subject: "test", Scopes: scopes})
+}
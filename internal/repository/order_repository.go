@@ -3,10 +3,14 @@ package repository
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
+	"slices"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 
+	"github.com/msmkdenis/wb-order-nats/internal/audit"
 	"github.com/msmkdenis/wb-order-nats/internal/model"
 	"github.com/msmkdenis/wb-order-nats/internal/storage/db"
 	"github.com/msmkdenis/wb-order-nats/pkg/apperr"
@@ -21,6 +25,15 @@ var insertItem string
 //go:embed queries/insert_order.sql
 var insertOrder string
 
+//go:embed queries/insert_order_key.sql
+var insertOrderKey string
+
+//go:embed queries/insert_payment_key.sql
+var insertPaymentKey string
+
+//go:embed queries/insert_item_key.sql
+var insertItemKey string
+
 //go:embed queries/insert_payment.sql
 var insertPayment string
 
@@ -30,6 +43,81 @@ var selectFullOrder string
 //go:embed queries/select_all_full_orders.sql
 var selectAllFullOrders string
 
+//go:embed queries/select_full_orders_created_before.sql
+var selectFullOrdersCreatedBefore string
+
+//go:embed queries/insert_quarantine.sql
+var insertQuarantine string
+
+//go:embed queries/select_order_uids_by_customer.sql
+var selectOrderUIDsByCustomer string
+
+//go:embed queries/anonymise_delivery.sql
+var anonymiseDelivery string
+
+//go:embed queries/delete_orders.sql
+var deleteOrders string
+
+//go:embed queries/delete_quarantine_by_customer.sql
+var deleteQuarantineByCustomer string
+
+//go:embed queries/anonymise_outbox.sql
+var anonymiseOutbox string
+
+//go:embed queries/delete_outbox_by_customer.sql
+var deleteOutboxByCustomer string
+
+//go:embed queries/anonymise_webhook_events.sql
+var anonymiseWebhookEvents string
+
+//go:embed queries/delete_webhook_events.sql
+var deleteWebhookEvents string
+
+//go:embed queries/insert_erasure_audit.sql
+var insertErasureAudit string
+
+//go:embed queries/select_full_orders_by_customer.sql
+var selectFullOrdersByCustomer string
+
+//go:embed queries/insert_order_audit.sql
+var insertOrderAudit string
+
+//go:embed queries/insert_order_audits.sql
+var insertOrderAudits string
+
+//go:embed queries/select_order_audit_by_id.sql
+var selectOrderAuditByID string
+
+//go:embed queries/update_order_status.sql
+var updateOrderStatus string
+
+//go:embed queries/insert_status_history.sql
+var insertStatusHistory string
+
+//go:embed queries/select_status_history_by_id.sql
+var selectStatusHistoryByID string
+
+//go:embed queries/insert_webhook_event.sql
+var insertWebhookEvent string
+
+//go:embed queries/insert_outbox.sql
+var insertOutbox string
+
+//go:embed queries/select_full_orders_by_track.sql
+var selectFullOrdersByTrack string
+
+//go:embed queries/select_full_orders_by_status.sql
+var selectFullOrdersByStatus string
+
+//go:embed queries/select_quarantine.sql
+var selectQuarantine string
+
+//go:embed queries/requeue_quarantine.sql
+var requeueQuarantine string
+
+//go:embed queries/insert_encoded_outbox.sql
+var insertEncodedOutbox string
+
 type OrderRepository struct {
 	postgresPool *db.PostgresPool
 	logger       *zap.Logger
@@ -42,7 +130,10 @@ func NewOrderRepository(postgresPool *db.PostgresPool, logger *zap.Logger) *Orde
 	}
 }
 
-func (r *OrderRepository) Insert(ctx context.Context, o model.Order) error {
+// Insert saves the order and writes the audit entry, the outbox messages and the webhook events in the same transaction.
+// The keys of the order, its payment and items are written first, so a redelivered order fails
+// as a duplicate even with another date_created.
+func (r *OrderRepository) Insert(ctx context.Context, o model.Order, entry model.OrderAudit, outbox []model.OutboxMessage, events ...model.Event) error {
 	d := o.Delivery
 	p := o.Payment
 
@@ -73,18 +164,41 @@ func (r *OrderRepository) Insert(ctx context.Context, o model.Order) error {
 		return apperr.NewValueError("unable to prepare query", apperr.Caller(), err)
 	}
 
+	statusHistory, err := tx.Prepare(ctx, "insertStatusHistory", insertStatusHistory)
+	if err != nil {
+		return apperr.NewValueError("unable to prepare query", apperr.Caller(), err)
+	}
+
 	batch := &pgx.Batch{}
+	batch.Queue(insertOrderKey, o.OrderUID, o.DateCreated)
+	batch.Queue(insertPaymentKey, p.Transaction, o.OrderUID)
+	for _, i := range o.Items {
+		batch.Queue(insertItemKey, i.ChrtID, o.OrderUID)
+	}
+
 	batch.Queue(order.Name, o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
 		o.DeliveryService, o.Shardkey, o.SmID, o.DateCreated, o.OofShard)
 
-	batch.Queue(delivery.Name, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email, o.OrderUID)
+	batch.Queue(delivery.Name, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email, o.OrderUID, o.DateCreated)
 
 	batch.Queue(payment.Name, p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount, p.PaymentDt, p.Bank,
-		p.DeliveryCost, p.GoodsTotal, p.CustomFee, o.OrderUID)
+		p.DeliveryCost, p.GoodsTotal, p.CustomFee, o.OrderUID, o.DateCreated)
 
 	for _, i := range o.Items {
 		batch.Queue(item.Name, i.ChrtID, i.TrackNumber, i.Price, i.Rid, i.Name, i.Sale, i.Size, i.TotalPrice,
-			i.NmID, i.Brand, i.Status, o.OrderUID)
+			i.NmID, i.Brand, i.Status, o.OrderUID, o.DateCreated)
+	}
+
+	batch.Queue(statusHistory.Name, o.OrderUID, nil, model.StatusCreated, "", audit.SourceFromContext(ctx))
+
+	batch.Queue(insertOrderAudit, entry.OrderUID, entry.Action, entry.Source, entry.Principal, entry.Diff)
+
+	for _, m := range outbox {
+		batch.Queue(insertOutbox, m.Subject, m.Payload)
+	}
+
+	for _, e := range events {
+		batch.Queue(insertWebhookEvent, e.Type, e.OrderUID, e.Payload)
 	}
 
 	result := tx.SendBatch(context.Background(), batch)
@@ -132,3 +246,395 @@ func (r *OrderRepository) SelectAll(ctx context.Context) ([]model.Order, error)
 
 	return orders, nil
 }
+
+// SelectCreatedBefore returns up to limit of the oldest orders created before the cutoff.
+func (r *OrderRepository) SelectCreatedBefore(ctx context.Context, cutoff time.Time, limit int) ([]model.Order, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectFullOrdersCreatedBefore, cutoff.UTC(), limit)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	orders, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.Order])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// DeleteOrders deletes archived orders with their items, payments, deliveries and status history
+// and records an archived audit entry for every deleted order in the same transaction.
+func (r *OrderRepository) DeleteOrders(ctx context.Context, orderUIDs []string) error {
+	tx, err := r.postgresPool.DB.Begin(ctx)
+	if err != nil {
+		r.logger.Info("Error while staring transaction", zap.String("error", err.Error()))
+		return err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	rows, err := tx.Query(ctx, deleteOrders, orderUIDs)
+	if err != nil {
+		return apperr.NewValueError("unable to delete orders", apperr.Caller(), err)
+	}
+
+	deleted, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return apperr.NewValueError("unable to collect deleted orders", apperr.Caller(), err)
+	}
+
+	_, err = tx.Exec(ctx, insertOrderAudits, deleted, audit.ActionArchived, audit.SourceFromContext(ctx), audit.PrincipalFromContext(ctx))
+	if err != nil {
+		return apperr.NewValueError("unable to insert order audit", apperr.Caller(), err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *OrderRepository) InsertQuarantine(ctx context.Context, o model.Order, reason string) error {
+	payload, err := json.Marshal(o)
+	if err != nil {
+		return apperr.NewValueError("unable to marshal order", apperr.Caller(), err)
+	}
+
+	_, err = r.postgresPool.DB.Exec(ctx, insertQuarantine, o.OrderUID, payload, reason)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// EraseCustomer anonymises delivery data of all customer orders, or deletes the orders
+// completely when hardDelete is set. Outbox messages and webhook events of the orders are
+// anonymised or deleted the same way, encoded outbox messages such as requeued orders are always
+// deleted, and quarantined orders of the customer are removed. The archived orders of the
+// customer, erased by the caller after the commit, are treated the same way. newAudit builds the
+// audit entry of every order from the rows selected in the transaction, the entries and an
+// erasure audit entry listing the erased orders are written in the same transaction. It returns
+// the erased order ids found in the database.
+func (r *OrderRepository) EraseCustomer(ctx context.Context, customerID string, hardDelete bool, principal string, archived []model.Order, newAudit func(model.Order) (model.OrderAudit, error)) ([]string, error) {
+	tx, err := r.postgresPool.DB.Begin(ctx)
+	if err != nil {
+		r.logger.Info("Error while staring transaction", zap.String("error", err.Error()))
+		return nil, err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	rows, err := tx.Query(ctx, selectOrderUIDsByCustomer, customerID)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to select customer orders", apperr.Caller(), err)
+	}
+
+	orderUIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, apperr.NewValueError("unable to collect customer orders", apperr.Caller(), err)
+	}
+
+	rows, err = tx.Query(ctx, selectFullOrdersByCustomer, customerID)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to select customer orders", apperr.Caller(), err)
+	}
+
+	orders, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.Order])
+	if err != nil {
+		return nil, apperr.NewValueError("unable to collect customer orders", apperr.Caller(), err)
+	}
+
+	entries := make([]model.OrderAudit, 0, len(orders)+len(archived))
+	for _, o := range append(orders, archived...) {
+		entry, err := newAudit(o)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	mode := "anonymise"
+	if hardDelete {
+		mode = "delete"
+		_, err = tx.Exec(ctx, deleteOrders, orderUIDs)
+	} else {
+		_, err = tx.Exec(ctx, anonymiseDelivery, orderUIDs)
+	}
+	if err != nil {
+		return nil, apperr.NewValueError("unable to "+mode+" customer orders", apperr.Caller(), err)
+	}
+
+	erasedUIDs := slices.Clone(orderUIDs)
+	for _, entry := range entries {
+		if !slices.Contains(erasedUIDs, entry.OrderUID) {
+			erasedUIDs = append(erasedUIDs, entry.OrderUID)
+		}
+	}
+
+	if hardDelete {
+		_, err = tx.Exec(ctx, deleteOutboxByCustomer, customerID, erasedUIDs)
+	} else {
+		_, err = tx.Exec(ctx, anonymiseOutbox, customerID, erasedUIDs)
+	}
+	if err != nil {
+		return nil, apperr.NewValueError("unable to "+mode+" outbox messages", apperr.Caller(), err)
+	}
+
+	if hardDelete {
+		_, err = tx.Exec(ctx, deleteWebhookEvents, erasedUIDs)
+	} else {
+		_, err = tx.Exec(ctx, anonymiseWebhookEvents, erasedUIDs)
+	}
+	if err != nil {
+		return nil, apperr.NewValueError("unable to "+mode+" webhook events", apperr.Caller(), err)
+	}
+
+	_, err = tx.Exec(ctx, deleteQuarantineByCustomer, customerID, erasedUIDs)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to delete quarantined orders", apperr.Caller(), err)
+	}
+
+	for _, a := range entries {
+		_, err = tx.Exec(ctx, insertOrderAudit, a.OrderUID, a.Action, a.Source, a.Principal, a.Diff)
+		if err != nil {
+			return nil, apperr.NewValueError("unable to insert order audit", apperr.Caller(), err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, insertErasureAudit, customerID, mode, erasedUIDs, principal)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to insert erasure audit", apperr.Caller(), err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderUIDs, nil
+}
+
+func (r *OrderRepository) SelectByCustomerID(ctx context.Context, customerID string) ([]model.Order, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectFullOrdersByCustomer, customerID)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	orders, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.Order])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (r *OrderRepository) SelectByTrackNumber(ctx context.Context, trackNumber string) ([]model.Order, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectFullOrdersByTrack, trackNumber)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	orders, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.Order])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (r *OrderRepository) SelectByStatus(ctx context.Context, status string) ([]model.Order, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectFullOrdersByStatus, status)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	orders, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.Order])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// SelectQuarantine returns the latest quarantined orders without their payload.
+func (r *OrderRepository) SelectQuarantine(ctx context.Context, limit int) ([]model.QuarantinedOrder, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectQuarantine, limit)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	quarantined, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.QuarantinedOrder])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return quarantined, nil
+}
+
+// requeuedOrder is a quarantined order removed from the quarantine for publishing again.
+type requeuedOrder struct {
+	OrderUID string          `db:"order_uid"`
+	Payload  json.RawMessage `db:"payload"`
+}
+
+// RequeueQuarantine moves the quarantined orders with the ids, or all of them, to the outbox for
+// publishing to the subject as encoded by encode and returns their order ids.
+func (r *OrderRepository) RequeueQuarantine(ctx context.Context, ids []string, all bool, subject string, encode func(model.Order) ([]byte, error)) ([]string, error) {
+	tx, err := r.postgresPool.DB.Begin(ctx)
+	if err != nil {
+		r.logger.Info("Error while staring transaction", zap.String("error", err.Error()))
+		return nil, err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	rows, err := tx.Query(ctx, requeueQuarantine, all, ids)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	requeued, err := pgx.CollectRows(rows, pgx.RowToStructByName[requeuedOrder])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	orderUIDs := make([]string, 0, len(requeued))
+	batch := &pgx.Batch{}
+	for _, q := range requeued {
+		var order model.Order
+		if err = json.Unmarshal(q.Payload, &order); err != nil {
+			return nil, apperr.NewValueError("unable to unmarshal quarantined order", apperr.Caller(), err)
+		}
+
+		data, err := encode(order)
+		if err != nil {
+			return nil, apperr.NewValueError("unable to encode quarantined order", apperr.Caller(), err)
+		}
+
+		batch.Queue(insertEncodedOutbox, subject, q.Payload, data)
+		orderUIDs = append(orderUIDs, q.OrderUID)
+	}
+
+	err = tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderUIDs, nil
+}
+
+func (r *OrderRepository) SelectAuditByOrderID(ctx context.Context, orderID string) ([]model.OrderAudit, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectOrderAuditByID, orderID)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	history, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.OrderAudit])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// UpdateStatus changes the order status from the expected one and records the change in the
+// status history, the audit trail and the webhook outbox. It returns false when the order is missing or its status is not the expected one.
+func (r *OrderRepository) UpdateStatus(ctx context.Context, orderUID string, from string, to string, reason string, source string, entry model.OrderAudit, events ...model.Event) (bool, error) {
+	tx, err := r.postgresPool.DB.Begin(ctx)
+	if err != nil {
+		r.logger.Info("Error while staring transaction", zap.String("error", err.Error()))
+		return false, err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	tag, err := tx.Exec(ctx, updateOrderStatus, orderUID, from, to)
+	if err != nil {
+		return false, apperr.NewValueError("unable to update order status", apperr.Caller(), err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(ctx, insertStatusHistory, orderUID, from, to, reason, source)
+	if err != nil {
+		return false, apperr.NewValueError("unable to insert status history", apperr.Caller(), err)
+	}
+
+	_, err = tx.Exec(ctx, insertOrderAudit, entry.OrderUID, entry.Action, entry.Source, entry.Principal, entry.Diff)
+	if err != nil {
+		return false, apperr.NewValueError("unable to insert order audit", apperr.Caller(), err)
+	}
+
+	for _, e := range events {
+		_, err = tx.Exec(ctx, insertWebhookEvent, e.Type, e.OrderUID, e.Payload)
+		if err != nil {
+			return false, apperr.NewValueError("unable to insert webhook event", apperr.Caller(), err)
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// InsertEvent writes an event that is not tied to an order change to the webhook outbox.
+func (r *OrderRepository) InsertEvent(ctx context.Context, e model.Event) error {
+	_, err := r.postgresPool.DB.Exec(ctx, insertWebhookEvent, e.Type, e.OrderUID, e.Payload)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// EraseEvents anonymises the webhook events of the orders, or deletes them when hardDelete is
+// set. It erases events kept outside the database of the orders, EraseCustomer erases the rest.
+func (r *OrderRepository) EraseEvents(ctx context.Context, orderUIDs []string, hardDelete bool) error {
+	query := anonymiseWebhookEvents
+	if hardDelete {
+		query = deleteWebhookEvents
+	}
+
+	_, err := r.postgresPool.DB.Exec(ctx, query, orderUIDs)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *OrderRepository) SelectStatusHistory(ctx context.Context, orderID string) ([]model.StatusChange, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectStatusHistoryByID, orderID)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	history, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.StatusChange])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return history, nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/storage/db"
+)
+
+//go:embed queries/lease_outbox.sql
+var leaseOutbox string
+
+//go:embed queries/mark_outbox_sent.sql
+var markOutboxSent string
+
+//go:embed queries/mark_outbox_failed.sql
+var markOutboxFailed string
+
+type OutboxRepository struct {
+	postgresPool *db.PostgresPool
+	logger       *zap.Logger
+}
+
+func NewOutboxRepository(postgresPool *db.PostgresPool, logger *zap.Logger) *OutboxRepository {
+	return &OutboxRepository{
+		postgresPool: postgresPool,
+		logger:       logger,
+	}
+}
+
+// Lease returns up to limit pending messages in insertion order and postpones them by lease,
+// so concurrent relays do not publish them twice and a crashed relay does not lose them.
+func (r *OutboxRepository) Lease(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, leaseOutbox, limit, lease)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	messages, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.OutboxMessage])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, id int64) error {
+	_, err := r.postgresPool.DB.Exec(ctx, markOutboxSent, id)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, nextAttempt time.Time, lastError string) error {
+	_, err := r.postgresPool.DB.Exec(ctx, markOutboxFailed, id, nextAttempt, lastError)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
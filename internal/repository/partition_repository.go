@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	_ "embed"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/audit"
+	"github.com/msmkdenis/wb-order-nats/internal/storage/db"
+	"github.com/msmkdenis/wb-order-nats/pkg/apperr"
+)
+
+//go:embed queries/create_order_partitions.sql
+var createOrderPartitions string
+
+//go:embed queries/detach_order_partitions.sql
+var detachOrderPartitions string
+
+type PartitionRepository struct {
+	postgresPool *db.PostgresPool
+	logger       *zap.Logger
+}
+
+func NewPartitionRepository(postgresPool *db.PostgresPool, logger *zap.Logger) *PartitionRepository {
+	return &PartitionRepository{
+		postgresPool: postgresPool,
+		logger:       logger,
+	}
+}
+
+// CreatePartitions creates monthly partitions of the order tables up to ahead months from now
+// and for months of orders that landed in the default partitions. It returns the suffixes of
+// the created partitions, e.g. p202401.
+func (r *PartitionRepository) CreatePartitions(ctx context.Context, ahead int) ([]string, error) {
+	return r.collect(ctx, createOrderPartitions, ahead)
+}
+
+// DetachPartitions detaches partitions of months ending before the cutoff, dropping them if
+// drop is set or moving them to the wb_demo_archive schema otherwise. Their orders are recorded
+// in the audit trail in the same transaction, as deleted or archived. It returns the suffixes
+// of the detached partitions and the ids of their orders.
+func (r *PartitionRepository) DetachPartitions(ctx context.Context, cutoff time.Time, drop bool) ([]string, []string, error) {
+	tx, err := r.postgresPool.DB.Begin(ctx)
+	if err != nil {
+		r.logger.Info("Error while staring transaction", zap.String("error", err.Error()))
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	rows, err := tx.Query(ctx, detachOrderPartitions, cutoff, drop)
+	if err != nil {
+		return nil, nil, apperr.NewValueError("unable to detach partitions", apperr.Caller(), err)
+	}
+
+	var suffixes, orderUIDs []string
+	var suffix string
+	var orderUID *string
+	_, err = pgx.ForEachRow(rows, []any{&suffix, &orderUID}, func() error {
+		if !slices.Contains(suffixes, suffix) {
+			suffixes = append(suffixes, suffix)
+		}
+		if orderUID != nil {
+			orderUIDs = append(orderUIDs, *orderUID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, apperr.NewValueError("unable to collect detached orders", apperr.Caller(), err)
+	}
+
+	action := audit.ActionArchived
+	if drop {
+		action = audit.ActionDeleted
+	}
+	_, err = tx.Exec(ctx, insertOrderAudits, orderUIDs, action, audit.SourceRetention, audit.PrincipalFromContext(ctx))
+	if err != nil {
+		return nil, nil, apperr.NewValueError("unable to insert order audit", apperr.Caller(), err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return suffixes, orderUIDs, nil
+}
+
+func (r *PartitionRepository) collect(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, query, args...)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	suffixes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return suffixes, nil
+}
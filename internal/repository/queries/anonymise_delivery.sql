@@ -0,0 +1,7 @@
+update wb_demo.delivery
+set name    = '[erased]',
+    phone   = '[erased]',
+    zip     = '[erased]',
+    address = '[erased]',
+    email   = '[erased]'
+where order_uid = any($1)
\ No newline at end of file
@@ -0,0 +1,15 @@
+with erased as (
+    select '{"name": "[erased]", "phone": "[erased]", "zip": "[erased]", "address": "[erased]", "email": "[erased]"}'::jsonb as delivery
+), encoded as (
+    delete from wb_demo.outbox
+    where data is not null
+      and (payload ->> 'customer_id' = $1 or payload ->> 'order_uid' = any($2))
+)
+update wb_demo.outbox
+set payload = case
+        when payload ? 'order' then jsonb_set(payload, '{order,delivery}', coalesce(payload #> '{order,delivery}', '{}') || erased.delivery)
+        else jsonb_set(payload, '{delivery}', coalesce(payload -> 'delivery', '{}') || erased.delivery)
+    end
+from erased
+where data is null
+  and (payload ->> 'customer_id' = $1 or payload ->> 'order_uid' = any($2))
\ No newline at end of file
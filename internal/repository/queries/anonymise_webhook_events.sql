@@ -0,0 +1,8 @@
+with erased as (
+    select '{"name": "[erased]", "phone": "[erased]", "zip": "[erased]", "address": "[erased]", "email": "[erased]"}'::jsonb as delivery
+)
+update wb_demo.webhook_event
+set payload = jsonb_set(payload, '{delivery}', payload -> 'delivery' || erased.delivery)
+from erased
+where order_uid = any($1)
+  and jsonb_typeof(payload -> 'delivery') = 'object'
\ No newline at end of file
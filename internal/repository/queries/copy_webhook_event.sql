@@ -0,0 +1,11 @@
+insert into wb_demo.webhook_event
+    (
+     id,
+     event_type,
+     order_uid,
+     payload,
+     created_at
+    )
+values
+    ($1, $2, $3, $4, $5)
+on conflict (id) do nothing
\ No newline at end of file
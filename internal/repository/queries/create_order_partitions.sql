@@ -0,0 +1,9 @@
+select to_char(partition_month, '"p"YYYYMM')
+from (
+    select date_trunc('month', date_created)::date as partition_month
+    from wb_demo.order_default
+    union
+    select generate_series(date_trunc('month', now()), date_trunc('month', now()) + make_interval(months => $1), interval '1 month')::date
+) months
+where wb_demo.create_order_partition(partition_month)
+order by 1
\ No newline at end of file
@@ -0,0 +1 @@
+delete from wb_demo.order_shard where order_uid = any($1)
\ No newline at end of file
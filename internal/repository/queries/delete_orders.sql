@@ -0,0 +1,17 @@
+with deleted_items as (
+    delete from wb_demo.item where order_uid = any($1)
+),
+deleted_payments as (
+    delete from wb_demo.payment where order_uid = any($1)
+),
+deleted_deliveries as (
+    delete from wb_demo.delivery where order_uid = any($1)
+),
+deleted_history as (
+    delete from wb_demo.order_status_history where order_uid = any($1)
+),
+deleted_keys as (
+    delete from wb_demo.order_key where order_uid = any($1)
+)
+delete from wb_demo."order" where order_uid = any($1)
+returning order_uid
\ No newline at end of file
@@ -0,0 +1,3 @@
+delete from wb_demo.outbox
+where payload ->> 'customer_id' = $1
+   or payload ->> 'order_uid' = any($2)
\ No newline at end of file
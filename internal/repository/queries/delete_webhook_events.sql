@@ -0,0 +1,2 @@
+delete from wb_demo.webhook_event
+where order_uid = any($1)
\ No newline at end of file
@@ -0,0 +1,2 @@
+delete from wb_demo.webhook_subscription
+where id = $1
\ No newline at end of file
@@ -0,0 +1 @@
+select * from wb_demo.detach_order_partitions($1, $2)
\ No newline at end of file
@@ -0,0 +1,21 @@
+with events as (
+    update wb_demo.webhook_event
+    set dispatched_at = now()
+    where id in (
+        select id
+        from wb_demo.webhook_event
+        where dispatched_at is null
+        order by created_at
+        limit $1
+        for update skip locked
+    )
+    returning id, event_type
+)
+insert into wb_demo.webhook_delivery
+    (
+     subscription_id,
+     event_id
+    )
+select s.id, e.id
+from events e
+join wb_demo.webhook_subscription s on s.active and e.event_type = any (s.events)
\ No newline at end of file
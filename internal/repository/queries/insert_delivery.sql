@@ -7,6 +7,7 @@ insert into wb_demo.delivery
      address,
      region,
      email,
-     order_uid)
+     order_uid,
+     date_created)
 values
-    ($1, $2, $3, $4, $5, $6, $7, $8)
\ No newline at end of file
+    ($1, $2, $3, $4, $5, $6, $7, $8, $9)
\ No newline at end of file
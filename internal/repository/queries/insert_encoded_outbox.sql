@@ -0,0 +1,8 @@
+insert into wb_demo.outbox
+    (
+     subject,
+     payload,
+     data
+    )
+values
+    ($1, $2, $3)
\ No newline at end of file
@@ -0,0 +1,9 @@
+insert into wb_demo.erasure_audit
+    (
+     customer_id,
+     mode,
+     order_uids,
+     principal
+    )
+values
+    ($1, $2, $3, $4)
\ No newline at end of file
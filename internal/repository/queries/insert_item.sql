@@ -11,7 +11,8 @@ insert into wb_demo.item
      nm_id,
      brand,
      status,
-     order_uid
+     order_uid,
+     date_created
     )
 values
-    ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
\ No newline at end of file
+    ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
\ No newline at end of file
@@ -0,0 +1,10 @@
+insert into wb_demo.order_audit
+    (
+     order_uid,
+     action,
+     source,
+     principal,
+     diff
+    )
+values
+    ($1, $2, $3, $4, $5)
\ No newline at end of file
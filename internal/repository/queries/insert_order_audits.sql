@@ -0,0 +1,10 @@
+insert into wb_demo.order_audit
+    (
+     order_uid,
+     action,
+     source,
+     principal,
+     diff
+    )
+select order_uid, $2, $3, $4, '{}'::jsonb
+from unnest($1::text[]) as order_uid
\ No newline at end of file
@@ -0,0 +1,7 @@
+insert into wb_demo.order_key
+    (
+     order_uid,
+     date_created
+    )
+values
+    ($1, $2)
\ No newline at end of file
@@ -0,0 +1,7 @@
+insert into wb_demo.outbox
+    (
+     subject,
+     payload
+    )
+values
+    ($1, $2)
\ No newline at end of file
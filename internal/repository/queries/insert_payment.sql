@@ -10,7 +10,8 @@ insert into wb_demo.payment
      delivery_cost,
      goods_total,
      custom_fee,
-     order_uid
+     order_uid,
+     date_created
     )
 values
-    ($1, $2, $3, $4, $5, to_timestamp($6), $7, $8, $9, $10, $11)
\ No newline at end of file
+    ($1, $2, $3, $4, $5, to_timestamp($6), $7, $8, $9, $10, $11, $12)
\ No newline at end of file
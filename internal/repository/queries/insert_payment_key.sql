@@ -0,0 +1,7 @@
+insert into wb_demo.payment_key
+    (
+     transaction,
+     order_uid
+    )
+values
+    ($1, $2)
\ No newline at end of file
@@ -0,0 +1,8 @@
+insert into wb_demo.quarantine
+    (
+     order_uid,
+     payload,
+     reason
+    )
+values
+    ($1, $2, $3)
\ No newline at end of file
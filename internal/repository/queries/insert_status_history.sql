@@ -0,0 +1,10 @@
+insert into wb_demo.order_status_history
+    (
+     order_uid,
+     from_status,
+     to_status,
+     reason,
+     source
+    )
+values
+    ($1, $2, $3, $4, $5)
\ No newline at end of file
@@ -0,0 +1,8 @@
+insert into wb_demo.webhook_event
+    (
+     event_type,
+     order_uid,
+     payload
+    )
+values
+    ($1, $2, $3)
\ No newline at end of file
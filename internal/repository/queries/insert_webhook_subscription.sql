@@ -0,0 +1,9 @@
+insert into wb_demo.webhook_subscription
+    (
+     url,
+     secret,
+     events
+    )
+values
+    ($1, $2, $3)
+returning id, url, secret, events, active, created_at
\ No newline at end of file
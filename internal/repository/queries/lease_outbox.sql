@@ -0,0 +1,11 @@
+update wb_demo.outbox
+set next_attempt_at = now() + $2::interval
+where id in (
+    select id
+    from wb_demo.outbox
+    where sent_at is null and next_attempt_at <= now()
+    order by id
+    limit $1
+    for update skip locked
+)
+returning id, subject, coalesce(data, convert_to(payload::text, 'UTF8')) as payload, attempts
\ No newline at end of file
@@ -0,0 +1,15 @@
+update wb_demo.webhook_delivery d
+set next_attempt_at = now() + $2::interval,
+    updated_at      = now()
+from wb_demo.webhook_event e, wb_demo.webhook_subscription s
+where d.id in (
+    select id
+    from wb_demo.webhook_delivery
+    where status = 'pending' and next_attempt_at <= now()
+    order by next_attempt_at
+    limit $1
+    for update skip locked
+)
+  and e.id = d.event_id
+  and s.id = d.subscription_id
+returning d.id, d.attempts, s.url, s.secret, e.id as event_id, e.event_type, e.order_uid, e.payload, e.created_at
\ No newline at end of file
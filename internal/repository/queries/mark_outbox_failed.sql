@@ -0,0 +1,5 @@
+update wb_demo.outbox
+set attempts        = attempts + 1,
+    next_attempt_at = $2,
+    last_error      = $3
+where id = $1
\ No newline at end of file
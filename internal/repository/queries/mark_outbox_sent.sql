@@ -0,0 +1,4 @@
+update wb_demo.outbox
+set sent_at  = now(),
+    attempts = attempts + 1
+where id = $1
\ No newline at end of file
@@ -0,0 +1,3 @@
+update wb_demo.webhook_event
+set dispatched_at = now()
+where id = any($1::uuid[])
\ No newline at end of file
@@ -0,0 +1,3 @@
+delete from wb_demo.quarantine
+where $1::boolean or id = any($2::uuid[])
+returning order_uid, payload
\ No newline at end of file
@@ -43,12 +43,13 @@ select
     o.shardkey,
     o.sm_id,
     o.date_created::text,
-        o.oof_shard
+        o.oof_shard,
+        o.status
 from wb_demo."order" o
-         left join wb_demo.delivery d on o.order_uid = d.order_uid
-         left join wb_demo.item i on o.order_uid = i.order_uid
-         left join wb_demo.payment p on o.order_uid = p.order_uid
-group by o.order_uid,
+         left join wb_demo.delivery d on o.order_uid = d.order_uid and o.date_created = d.date_created
+         left join wb_demo.item i on o.order_uid = i.order_uid and o.date_created = i.date_created
+         left join wb_demo.payment p on o.order_uid = p.order_uid and o.date_created = p.date_created
+group by o.order_uid, o.date_created,
          d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
          p.transaction, p.request_id, p.currency, p.provider, p.amount, extract(epoch from p.payment_dt)::integer,
          p.bank, p.delivery_cost, p.goods_total, p.custom_fee
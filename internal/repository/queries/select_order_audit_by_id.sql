@@ -0,0 +1,11 @@
+select
+    id,
+    order_uid,
+    action,
+    source,
+    principal,
+    diff,
+    created_at
+from wb_demo.order_audit
+where order_uid = $1
+order by created_at, id
\ No newline at end of file
@@ -0,0 +1 @@
+select shard from wb_demo.order_shard where order_uid = $1
\ No newline at end of file
@@ -0,0 +1,4 @@
+select order_uid
+from wb_demo."order"
+where customer_id = $1
+for update
\ No newline at end of file
@@ -0,0 +1,5 @@
+select id, event_type, order_uid, payload, created_at
+from wb_demo.webhook_event
+where dispatched_at is null
+order by created_at
+limit $1
\ No newline at end of file
@@ -0,0 +1,8 @@
+select
+    id,
+    order_uid,
+    reason,
+    created_at
+from wb_demo.quarantine
+order by created_at desc
+limit $1
\ No newline at end of file
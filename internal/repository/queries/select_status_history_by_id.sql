@@ -0,0 +1,11 @@
+select
+    id,
+    order_uid,
+    from_status,
+    to_status,
+    reason,
+    source,
+    changed_at
+from wb_demo.order_status_history
+where order_uid = $1
+order by changed_at, id
\ No newline at end of file
@@ -0,0 +1,18 @@
+select
+    d.id,
+    d.subscription_id,
+    d.event_id,
+    e.event_type,
+    e.order_uid,
+    d.status,
+    d.attempts,
+    d.next_attempt_at,
+    d.last_status_code,
+    d.last_error,
+    d.created_at,
+    d.updated_at
+from wb_demo.webhook_delivery d
+join wb_demo.webhook_event e on e.id = d.event_id
+where d.subscription_id = $1
+order by d.created_at desc
+limit $2
\ No newline at end of file
@@ -0,0 +1,9 @@
+select
+    id,
+    url,
+    secret,
+    events,
+    active,
+    created_at
+from wb_demo.webhook_subscription
+order by created_at
\ No newline at end of file
@@ -0,0 +1,3 @@
+update wb_demo."order"
+set status = $3
+where order_uid = $1 and status = $2
\ No newline at end of file
@@ -0,0 +1,8 @@
+update wb_demo.webhook_delivery
+set status           = $2,
+    attempts         = attempts + 1,
+    next_attempt_at  = $3,
+    last_status_code = $4,
+    last_error       = $5,
+    updated_at       = now()
+where id = $1
\ No newline at end of file
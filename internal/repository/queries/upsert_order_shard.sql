@@ -0,0 +1,9 @@
+insert into wb_demo.order_shard
+    (
+     order_uid,
+     shard
+    )
+values
+    ($1, $2)
+on conflict (order_uid) do update set order_uid = excluded.order_uid
+returning shard, xmax = 0 as inserted
\ No newline at end of file
@@ -0,0 +1,399 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/shard"
+	"github.com/msmkdenis/wb-order-nats/internal/storage/db"
+)
+
+//go:embed queries/upsert_order_shard.sql
+var upsertOrderShard string
+
+//go:embed queries/select_order_shard.sql
+var selectOrderShard string
+
+//go:embed queries/delete_order_shards.sql
+var deleteOrderShards string
+
+// ErrUnknownShard is returned for orders recorded on a shard missing in DATABASE_SHARDS.
+var ErrUnknownShard = errors.New("shard is not configured")
+
+// ShardedOrderRepository spreads orders over several databases. An order is written to the
+// shard the consistent hash ring assigns to its shardkey and the main database records the
+// shard in the order_shard table, reads by order id are routed by it. Orders without an
+// order_shard row, e.g. written before sharding, are searched on every shard. Lists are
+// queried on every shard and merged.
+//
+// The outbox and the webhook events stay in the shard transaction, every shard needs its own
+// outbox relay and the webhook dispatcher collects the events of every shard.
+type ShardedOrderRepository struct {
+	main   mainRepository
+	shards map[string]shardRepository
+	names  []string
+	ring   *shard.Ring
+	logger *zap.Logger
+}
+
+// shardRepository is the order repository of a single shard.
+type shardRepository interface {
+	Insert(ctx context.Context, o model.Order, entry model.OrderAudit, outbox []model.OutboxMessage, events ...model.Event) error
+	SelectByID(ctx context.Context, orderID string) (*model.Order, error)
+	SelectAll(ctx context.Context) ([]model.Order, error)
+	SelectByCustomerID(ctx context.Context, customerID string) ([]model.Order, error)
+	SelectByTrackNumber(ctx context.Context, trackNumber string) ([]model.Order, error)
+	SelectByStatus(ctx context.Context, status string) ([]model.Order, error)
+	SelectCreatedBefore(ctx context.Context, cutoff time.Time, limit int) ([]model.Order, error)
+	DeleteOrders(ctx context.Context, orderUIDs []string) error
+	InsertQuarantine(ctx context.Context, o model.Order, reason string) error
+	EraseCustomer(ctx context.Context, customerID string, hardDelete bool, principal string, archived []model.Order, newAudit func(model.Order) (model.OrderAudit, error)) ([]string, error)
+	SelectQuarantine(ctx context.Context, limit int) ([]model.QuarantinedOrder, error)
+	RequeueQuarantine(ctx context.Context, ids []string, all bool, subject string, encode func(model.Order) ([]byte, error)) ([]string, error)
+	SelectAuditByOrderID(ctx context.Context, orderID string) ([]model.OrderAudit, error)
+	UpdateStatus(ctx context.Context, orderUID string, from string, to string, reason string, source string, entry model.OrderAudit, events ...model.Event) (bool, error)
+	SelectStatusHistory(ctx context.Context, orderID string) ([]model.StatusChange, error)
+}
+
+// mainRepository is the main database, it records the shards of orders and keeps events
+// without an order as well as events written before the shards had them.
+type mainRepository interface {
+	AssignShard(ctx context.Context, orderUID string, name string) (string, bool, error)
+	SelectShard(ctx context.Context, orderUID string) (string, error)
+	DeleteShards(ctx context.Context, orderUIDs []string) error
+	InsertEvent(ctx context.Context, e model.Event) error
+	EraseEvents(ctx context.Context, orderUIDs []string, hardDelete bool) error
+}
+
+func NewShardedOrderRepository(main *db.PostgresPool, shards map[string]*db.PostgresPool, logger *zap.Logger) *ShardedOrderRepository {
+	shardRepositories := make(map[string]shardRepository, len(shards))
+	for name, pool := range shards {
+		shardRepositories[name] = NewOrderRepository(pool, logger)
+	}
+	return newShardedOrderRepository(NewOrderRepository(main, logger), shardRepositories, logger)
+}
+
+func newShardedOrderRepository(main mainRepository, shards map[string]shardRepository, logger *zap.Logger) *ShardedOrderRepository {
+	r := &ShardedOrderRepository{
+		main:   main,
+		shards: shards,
+		logger: logger,
+	}
+	for name := range shards {
+		r.names = append(r.names, name)
+	}
+	slices.Sort(r.names)
+	r.ring = shard.NewRing(r.names)
+	return r
+}
+
+// AssignShard records the shard of the order unless it already has one and returns the
+// recorded shard and whether this call recorded it.
+func (r *OrderRepository) AssignShard(ctx context.Context, orderUID string, name string) (string, bool, error) {
+	var inserted bool
+	err := r.postgresPool.DB.QueryRow(ctx, upsertOrderShard, orderUID, name).Scan(&name, &inserted)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return "", false, err
+	}
+	return name, inserted, nil
+}
+
+// SelectShard returns the recorded shard of the order or pgx.ErrNoRows.
+func (r *OrderRepository) SelectShard(ctx context.Context, orderUID string) (string, error) {
+	var name string
+	err := r.postgresPool.DB.QueryRow(ctx, selectOrderShard, orderUID).Scan(&name)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		r.logger.Info("error", zap.Error(err))
+	}
+	return name, err
+}
+
+// DeleteShards deletes the order_shard rows of the orders.
+func (r *OrderRepository) DeleteShards(ctx context.Context, orderUIDs []string) error {
+	_, err := r.postgresPool.DB.Exec(ctx, deleteOrderShards, orderUIDs)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// shardKey routes the order by shardkey, or by order id if it has none.
+func shardKey(o model.Order) string {
+	if o.Shardkey != "" {
+		return o.Shardkey
+	}
+	return o.OrderUID
+}
+
+// assign records the shard of the order unless it already has one and returns the recorded
+// shard, so a redelivered order reaches the shard detecting it as a duplicate.
+func (r *ShardedOrderRepository) assign(ctx context.Context, orderUID string, name string) (shardRepository, bool, error) {
+	name, inserted, err := r.main.AssignShard(ctx, orderUID, name)
+	if err != nil {
+		return nil, false, err
+	}
+	s, err := r.shard(name)
+	return s, inserted, err
+}
+
+func (r *ShardedOrderRepository) shard(name string) (shardRepository, error) {
+	s, ok := r.shards[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownShard, name)
+	}
+	return s, nil
+}
+
+// locate returns the shard of the order or pgx.ErrNoRows if no shard has it.
+func (r *ShardedOrderRepository) locate(ctx context.Context, orderUID string) (shardRepository, error) {
+	name, err := r.main.SelectShard(ctx, orderUID)
+	if err == nil {
+		return r.shard(name)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	for _, name := range r.names {
+		_, err = r.shards[name].SelectByID(ctx, orderUID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s, _, err := r.assign(ctx, orderUID, name)
+		return s, err
+	}
+	return nil, pgx.ErrNoRows
+}
+
+// fanOut runs the query on every shard concurrently and concatenates the results. If some
+// shards fail, the results of the others are returned with the joined errors.
+func fanOut[T any](r *ShardedOrderRepository, query func(s shardRepository) ([]T, error)) ([]T, error) {
+	results := make([][]T, len(r.names))
+	errs := make([]error, len(r.names))
+	var wg sync.WaitGroup
+	for i, name := range r.names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i], errs[i] = query(r.shards[name])
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("shard %s: %w", name, errs[i])
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, result := range results {
+		total += len(result)
+	}
+	merged := make([]T, 0, total)
+	for _, result := range results {
+		merged = append(merged, result...)
+	}
+	return merged, errors.Join(errs...)
+}
+
+// sortOrders orders merged shard results by creation time.
+func sortOrders(orders []model.Order) []model.Order {
+	slices.SortFunc(orders, func(a, b model.Order) int {
+		if c := cmp.Compare(a.DateCreated, b.DateCreated); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.OrderUID, b.OrderUID)
+	})
+	return orders
+}
+
+// Insert records the shard of the order in the main database and writes the order to it. The
+// record is deleted again if this call created it and the shard rejected the order, so a
+// redelivery is routed by the ring as if the order had never arrived. Order ids are unique
+// across shards through order_shard, payment transactions and item chrt_ids only within a
+// shard: the key tables live next to the orders, and orders with the same transaction or
+// chrt_id on different shards are both accepted.
+func (r *ShardedOrderRepository) Insert(ctx context.Context, o model.Order, entry model.OrderAudit, outbox []model.OutboxMessage, events ...model.Event) error {
+	s, inserted, err := r.assign(ctx, o.OrderUID, r.ring.Get(shardKey(o)))
+	if err != nil {
+		return err
+	}
+
+	err = s.Insert(ctx, o, entry, outbox, events...)
+	if err != nil && inserted {
+		return errors.Join(err, r.DeleteShards(ctx, []string{o.OrderUID}))
+	}
+	return err
+}
+
+func (r *ShardedOrderRepository) SelectByID(ctx context.Context, orderID string) (*model.Order, error) {
+	s, err := r.locate(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return s.SelectByID(ctx, orderID)
+}
+
+func (r *ShardedOrderRepository) SelectAll(ctx context.Context) ([]model.Order, error) {
+	orders, err := fanOut(r, func(s shardRepository) ([]model.Order, error) {
+		return s.SelectAll(ctx)
+	})
+	return sortOrders(orders), err
+}
+
+func (r *ShardedOrderRepository) SelectByCustomerID(ctx context.Context, customerID string) ([]model.Order, error) {
+	orders, err := fanOut(r, func(s shardRepository) ([]model.Order, error) {
+		return s.SelectByCustomerID(ctx, customerID)
+	})
+	return sortOrders(orders), err
+}
+
+func (r *ShardedOrderRepository) SelectByTrackNumber(ctx context.Context, trackNumber string) ([]model.Order, error) {
+	orders, err := fanOut(r, func(s shardRepository) ([]model.Order, error) {
+		return s.SelectByTrackNumber(ctx, trackNumber)
+	})
+	return sortOrders(orders), err
+}
+
+func (r *ShardedOrderRepository) SelectByStatus(ctx context.Context, status string) ([]model.Order, error) {
+	orders, err := fanOut(r, func(s shardRepository) ([]model.Order, error) {
+		return s.SelectByStatus(ctx, status)
+	})
+	return sortOrders(orders), err
+}
+
+// SelectCreatedBefore returns up to limit of the oldest orders of all shards created before the cutoff.
+func (r *ShardedOrderRepository) SelectCreatedBefore(ctx context.Context, cutoff time.Time, limit int) ([]model.Order, error) {
+	orders, err := fanOut(r, func(s shardRepository) ([]model.Order, error) {
+		return s.SelectCreatedBefore(ctx, cutoff, limit)
+	})
+	orders = sortOrders(orders)
+	return orders[:min(limit, len(orders))], err
+}
+
+// DeleteOrders deletes the orders from every shard and their order_shard rows.
+func (r *ShardedOrderRepository) DeleteOrders(ctx context.Context, orderUIDs []string) error {
+	_, err := fanOut(r, func(s shardRepository) ([]string, error) {
+		return nil, s.DeleteOrders(ctx, orderUIDs)
+	})
+	if err != nil {
+		return err
+	}
+	return r.DeleteShards(ctx, orderUIDs)
+}
+
+// DeleteShards deletes the order_shard rows of the orders, e.g. of orders removed by partition retention.
+func (r *ShardedOrderRepository) DeleteShards(ctx context.Context, orderUIDs []string) error {
+	return r.main.DeleteShards(ctx, orderUIDs)
+}
+
+func (r *ShardedOrderRepository) InsertQuarantine(ctx context.Context, o model.Order, reason string) error {
+	return r.shards[r.ring.Get(shardKey(o))].InsertQuarantine(ctx, o, reason)
+}
+
+// EraseCustomer erases the customer on every shard, each shard in its own transaction. Archived
+// orders go to the shard recorded for them, orders no shard has to the first shard. Events of
+// the orders kept by the main database are erased as well. If some shards fail, the orders
+// erased by the others are returned with the error.
+func (r *ShardedOrderRepository) EraseCustomer(ctx context.Context, customerID string, hardDelete bool, principal string, archived []model.Order, newAudit func(model.Order) (model.OrderAudit, error)) ([]string, error) {
+	shardArchived := make(map[shardRepository][]model.Order)
+	for _, order := range archived {
+		s, err := r.locate(ctx, order.OrderUID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			s, err = r.shards[r.names[0]], nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		shardArchived[s] = append(shardArchived[s], order)
+	}
+
+	orderUIDs, err := fanOut(r, func(s shardRepository) ([]string, error) {
+		return s.EraseCustomer(ctx, customerID, hardDelete, principal, shardArchived[s], newAudit)
+	})
+
+	erasedUIDs := slices.Clone(orderUIDs)
+	for _, order := range archived {
+		if !slices.Contains(erasedUIDs, order.OrderUID) {
+			erasedUIDs = append(erasedUIDs, order.OrderUID)
+		}
+	}
+	if len(erasedUIDs) == 0 {
+		return orderUIDs, err
+	}
+
+	errs := []error{err, r.main.EraseEvents(ctx, erasedUIDs, hardDelete)}
+	if hardDelete && len(orderUIDs) > 0 {
+		errs = append(errs, r.DeleteShards(ctx, orderUIDs))
+	}
+	return orderUIDs, errors.Join(errs...)
+}
+
+func (r *ShardedOrderRepository) SelectQuarantine(ctx context.Context, limit int) ([]model.QuarantinedOrder, error) {
+	orders, err := fanOut(r, func(s shardRepository) ([]model.QuarantinedOrder, error) {
+		return s.SelectQuarantine(ctx, limit)
+	})
+	slices.SortFunc(orders, func(a, b model.QuarantinedOrder) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return orders[:min(limit, len(orders))], err
+}
+
+func (r *ShardedOrderRepository) RequeueQuarantine(ctx context.Context, ids []string, all bool, subject string, encode func(model.Order) ([]byte, error)) ([]string, error) {
+	return fanOut(r, func(s shardRepository) ([]string, error) {
+		return s.RequeueQuarantine(ctx, ids, all, subject, encode)
+	})
+}
+
+// SelectAuditByOrderID searches every shard for the audit of orders without a shard, it
+// outlives hard deleted orders.
+func (r *ShardedOrderRepository) SelectAuditByOrderID(ctx context.Context, orderID string) ([]model.OrderAudit, error) {
+	s, err := r.locate(ctx, orderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fanOut(r, func(s shardRepository) ([]model.OrderAudit, error) {
+			return s.SelectAuditByOrderID(ctx, orderID)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.SelectAuditByOrderID(ctx, orderID)
+}
+
+func (r *ShardedOrderRepository) UpdateStatus(ctx context.Context, orderUID string, from string, to string, reason string, source string, entry model.OrderAudit, events ...model.Event) (bool, error) {
+	s, err := r.locate(ctx, orderUID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return s.UpdateStatus(ctx, orderUID, from, to, reason, source, entry, events...)
+}
+
+func (r *ShardedOrderRepository) InsertEvent(ctx context.Context, e model.Event) error {
+	return r.main.InsertEvent(ctx, e)
+}
+
+func (r *ShardedOrderRepository) SelectStatusHistory(ctx context.Context, orderID string) ([]model.StatusChange, error) {
+	s, err := r.locate(ctx, orderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []model.StatusChange{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.SelectStatusHistory(ctx, orderID)
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+var errDuplicateKey = errors.New("duplicate key value violates unique constraint \"pk_payment_key\"")
+
+type fakeShard struct {
+	shardRepository
+	orders   map[string]model.Order
+	events   []model.Event
+	erased   []string
+	archived []model.Order
+	eraseErr error
+}
+
+func newFakeShard(orders ...model.Order) *fakeShard {
+	s := &fakeShard{orders: make(map[string]model.Order)}
+	for _, o := range orders {
+		s.orders[o.OrderUID] = o
+	}
+	return s
+}
+
+// Insert rejects duplicate payment transactions like the key tables of a shard do.
+func (f *fakeShard) Insert(_ context.Context, o model.Order, _ model.OrderAudit, _ []model.OutboxMessage, events ...model.Event) error {
+	for _, existing := range f.orders {
+		if existing.Payment.Transaction != "" && existing.Payment.Transaction == o.Payment.Transaction {
+			return errDuplicateKey
+		}
+	}
+	f.orders[o.OrderUID] = o
+	f.events = append(f.events, events...)
+	return nil
+}
+
+func (f *fakeShard) SelectByID(_ context.Context, orderID string) (*model.Order, error) {
+	o, ok := f.orders[orderID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return &o, nil
+}
+
+func (f *fakeShard) SelectAll(context.Context) ([]model.Order, error) {
+	orders := make([]model.Order, 0, len(f.orders))
+	for _, o := range f.orders {
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+func (f *fakeShard) EraseCustomer(_ context.Context, customerID string, _ bool, _ string, archived []model.Order, _ func(model.Order) (model.OrderAudit, error)) ([]string, error) {
+	f.archived = append(f.archived, archived...)
+	if f.eraseErr != nil {
+		return nil, f.eraseErr
+	}
+	for uid, o := range f.orders {
+		if o.CustomerID == customerID {
+			f.erased = append(f.erased, uid)
+		}
+	}
+	return f.erased, nil
+}
+
+type fakeMain struct {
+	mainRepository
+	shards        map[string]string
+	deletedShards []string
+	erasedEvents  []string
+}
+
+func (f *fakeMain) AssignShard(_ context.Context, orderUID string, name string) (string, bool, error) {
+	if recorded, ok := f.shards[orderUID]; ok {
+		return recorded, false, nil
+	}
+	f.shards[orderUID] = name
+	return name, true, nil
+}
+
+func (f *fakeMain) SelectShard(_ context.Context, orderUID string) (string, error) {
+	name, ok := f.shards[orderUID]
+	if !ok {
+		return "", pgx.ErrNoRows
+	}
+	return name, nil
+}
+
+func (f *fakeMain) DeleteShards(_ context.Context, orderUIDs []string) error {
+	f.deletedShards = append(f.deletedShards, orderUIDs...)
+	for _, orderUID := range orderUIDs {
+		delete(f.shards, orderUID)
+	}
+	return nil
+}
+
+func (f *fakeMain) EraseEvents(_ context.Context, orderUIDs []string, _ bool) error {
+	f.erasedEvents = append(f.erasedEvents, orderUIDs...)
+	return nil
+}
+
+func newTestRepository(shards map[string]*fakeShard) (*ShardedOrderRepository, *fakeMain) {
+	main := &fakeMain{shards: make(map[string]string)}
+	repositories := make(map[string]shardRepository, len(shards))
+	for name, s := range shards {
+		repositories[name] = s
+	}
+	return newShardedOrderRepository(main, repositories, zap.NewNop()), main
+}
+
+func TestShardedInsertRouting(t *testing.T) {
+	shards := map[string]*fakeShard{"shard1": newFakeShard(), "shard2": newFakeShard()}
+	r, main := newTestRepository(shards)
+
+	order := model.Order{OrderUID: "order1", Shardkey: "7"}
+	event := model.Event{Type: model.EventOrderSaved, OrderUID: "order1"}
+	require.NoError(t, r.Insert(context.Background(), order, model.OrderAudit{}, nil, event))
+
+	name := r.ring.Get(order.Shardkey)
+	assert.Equal(t, name, main.shards["order1"])
+	assert.Contains(t, shards[name].orders, "order1")
+	assert.Equal(t, []model.Event{event}, shards[name].events, "events are written in the shard transaction")
+
+	// a redelivered order goes to its recorded shard even if the ring changed
+	other := "shard1"
+	if name == other {
+		other = "shard2"
+	}
+	main.shards["order2"] = other
+	require.NoError(t, r.Insert(context.Background(), model.Order{OrderUID: "order2", Shardkey: "7"}, model.OrderAudit{}, nil))
+	assert.Contains(t, shards[other].orders, "order2")
+	assert.NotContains(t, shards[name].orders, "order2")
+}
+
+func TestShardedInsertKeyScope(t *testing.T) {
+	shards := map[string]*fakeShard{"shard1": newFakeShard(), "shard2": newFakeShard()}
+	r, main := newTestRepository(shards)
+
+	// find shardkeys the ring puts on different shards
+	keys := map[string]string{}
+	for i := 0; len(keys) < 2; i++ {
+		key := strconv.Itoa(i)
+		if _, ok := keys[r.ring.Get(key)]; !ok {
+			keys[r.ring.Get(key)] = key
+		}
+	}
+	payment := model.Payment{Transaction: "transaction"}
+
+	require.NoError(t, r.Insert(context.Background(), model.Order{OrderUID: "order1", Shardkey: keys["shard1"], Payment: payment}, model.OrderAudit{}, nil))
+	require.NoError(t, r.Insert(context.Background(), model.Order{OrderUID: "order2", Shardkey: keys["shard2"], Payment: payment}, model.OrderAudit{}, nil),
+		"payment transactions are unique per shard only")
+
+	// the shard rejects the duplicate, the order_shard row created for it is deleted again
+	err := r.Insert(context.Background(), model.Order{OrderUID: "order3", Shardkey: keys["shard1"], Payment: payment}, model.OrderAudit{}, nil)
+	assert.ErrorIs(t, err, errDuplicateKey)
+	assert.NotContains(t, main.shards, "order3")
+
+	// a redelivery keeps the row recorded for the order
+	err = r.Insert(context.Background(), model.Order{OrderUID: "order1", Shardkey: keys["shard1"], Payment: payment}, model.OrderAudit{}, nil)
+	assert.ErrorIs(t, err, errDuplicateKey)
+	assert.Equal(t, "shard1", main.shards["order1"])
+	assert.Equal(t, []string{"order3"}, main.deletedShards)
+}
+
+func TestShardedLookupFallback(t *testing.T) {
+	shards := map[string]*fakeShard{"shard1": newFakeShard(), "shard2": newFakeShard(model.Order{OrderUID: "legacy"})}
+	r, main := newTestRepository(shards)
+
+	order, err := r.SelectByID(context.Background(), "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", order.OrderUID)
+	assert.Equal(t, "shard2", main.shards["legacy"], "an order found by searching is assigned its shard")
+
+	_, err = r.SelectByID(context.Background(), "missing")
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	main.shards["lost"] = "shard3"
+	_, err = r.SelectByID(context.Background(), "lost")
+	assert.ErrorIs(t, err, ErrUnknownShard)
+}
+
+func TestShardedFanOutMerge(t *testing.T) {
+	shards := map[string]*fakeShard{
+		"shard1": newFakeShard(model.Order{OrderUID: "b", DateCreated: "2024-01-02T00:00:00Z", CustomerID: "customer"}),
+		"shard2": newFakeShard(model.Order{OrderUID: "a", DateCreated: "2024-01-03T00:00:00Z", CustomerID: "customer"},
+			model.Order{OrderUID: "c", DateCreated: "2024-01-01T00:00:00Z"}),
+	}
+	r, main := newTestRepository(shards)
+
+	orders, err := r.SelectAll(context.Background())
+	require.NoError(t, err)
+	uids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		uids = append(uids, o.OrderUID)
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, uids, "merged by creation time")
+
+	// a failing shard keeps the orders erased by the others
+	shards["shard1"].eraseErr = errors.New("connection refused")
+	archived := []model.Order{{OrderUID: "archived", CustomerID: "customer"}}
+	erased, err := r.EraseCustomer(context.Background(), "customer", true, "admin", archived, nil)
+	assert.ErrorContains(t, err, "shard shard1")
+	assert.Equal(t, []string{"a"}, erased)
+	assert.Equal(t, archived, shards["shard1"].archived, "archived orders no shard has go to the first shard")
+	assert.Equal(t, []string{"a"}, main.deletedShards)
+	assert.ElementsMatch(t, []string{"a", "archived"}, main.erasedEvents)
+}
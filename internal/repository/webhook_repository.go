@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/storage/db"
+)
+
+//go:embed queries/insert_webhook_subscription.sql
+var insertWebhookSubscription string
+
+//go:embed queries/select_webhook_subscriptions.sql
+var selectWebhookSubscriptions string
+
+//go:embed queries/delete_webhook_subscription.sql
+var deleteWebhookSubscription string
+
+//go:embed queries/select_webhook_deliveries.sql
+var selectWebhookDeliveries string
+
+//go:embed queries/fan_out_webhook_events.sql
+var fanOutWebhookEvents string
+
+//go:embed queries/lease_webhook_deliveries.sql
+var leaseWebhookDeliveries string
+
+//go:embed queries/update_webhook_delivery.sql
+var updateWebhookDelivery string
+
+//go:embed queries/select_pending_webhook_events.sql
+var selectPendingWebhookEvents string
+
+//go:embed queries/mark_webhook_events_dispatched.sql
+var markWebhookEventsDispatched string
+
+//go:embed queries/copy_webhook_event.sql
+var copyWebhookEvent string
+
+type WebhookRepository struct {
+	postgresPool *db.PostgresPool
+	logger       *zap.Logger
+}
+
+func NewWebhookRepository(postgresPool *db.PostgresPool, logger *zap.Logger) *WebhookRepository {
+	return &WebhookRepository{
+		postgresPool: postgresPool,
+		logger:       logger,
+	}
+}
+
+func (r *WebhookRepository) InsertSubscription(ctx context.Context, s model.WebhookSubscription) (*model.WebhookSubscription, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, insertWebhookSubscription, s.URL, s.Secret, s.Events)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	subscription, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[model.WebhookSubscription])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return &subscription, nil
+}
+
+func (r *WebhookRepository) SelectSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectWebhookSubscriptions)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	subscriptions, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.WebhookSubscription])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id string) (bool, error) {
+	tag, err := r.postgresPool.DB.Exec(ctx, deleteWebhookSubscription, id)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *WebhookRepository) SelectDeliveries(ctx context.Context, subscriptionID string, limit int) ([]model.WebhookDelivery, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectWebhookDeliveries, subscriptionID, limit)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	deliveries, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.WebhookDelivery])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// FanOutEvents creates deliveries of up to limit outbox events for every active subscription
+// interested in them and marks the events dispatched. It returns the number of created deliveries.
+func (r *WebhookRepository) FanOutEvents(ctx context.Context, limit int) (int64, error) {
+	tag, err := r.postgresPool.DB.Exec(ctx, fanOutWebhookEvents, limit)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// SelectPendingEvents returns up to limit of the oldest events not fanned out yet.
+func (r *WebhookRepository) SelectPendingEvents(ctx context.Context, limit int) ([]model.PendingEvent, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, selectPendingWebhookEvents, limit)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.PendingEvent])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// MarkEventsDispatched marks the events dispatched, e.g. once they are copied to the database
+// of the subscriptions.
+func (r *WebhookRepository) MarkEventsDispatched(ctx context.Context, ids []string) error {
+	_, err := r.postgresPool.DB.Exec(ctx, markWebhookEventsDispatched, ids)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// CopyEvents writes events of another database with their ids, events copied before are skipped.
+func (r *WebhookRepository) CopyEvents(ctx context.Context, events []model.PendingEvent) error {
+	batch := &pgx.Batch{}
+	for _, e := range events {
+		batch.Queue(copyWebhookEvent, e.ID, e.Type, e.OrderUID, e.Payload, e.CreatedAt)
+	}
+
+	err := r.postgresPool.DB.SendBatch(ctx, batch).Close()
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// LeaseDeliveries returns up to limit due deliveries and postpones them by lease,
+// so a crashed sender does not lose them and concurrent senders do not pick them twice.
+func (r *WebhookRepository) LeaseDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.PendingDelivery, error) {
+	rows, err := r.postgresPool.DB.Query(ctx, leaseWebhookDeliveries, limit, lease)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	deliveries, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.PendingDelivery])
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery records the result of a delivery attempt.
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, id string, status string, nextAttempt time.Time, statusCode *int, lastError string) error {
+	_, err := r.postgresPool.DB.Exec(ctx, updateWebhookDelivery, id, status, nextAttempt, statusCode, lastError)
+	if err != nil {
+		r.logger.Info("error", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package schema implements versioned order messages and upgrades of older payloads to the current model.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+// CurrentVersion is the schema version matching model.Order.
+const CurrentVersion = 1
+
+// LegacyVersion is assumed for messages published without an envelope.
+const LegacyVersion = 1
+
+// ErrUnknownVersion is returned when a message carries a version without a decoder or upgrade path.
+var ErrUnknownVersion = errors.New("unknown schema version")
+
+// Envelope wraps an order payload together with its schema version.
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Decoder converts a payload of a specific version into the current model.
+type Decoder func(payload []byte) (model.Order, error)
+
+// Upgrader converts a payload of some version into a payload of the next version.
+type Upgrader func(payload []byte) ([]byte, error)
+
+// Registry holds per-version decoders and upgrade functions.
+type Registry struct {
+	decoders  map[int]Decoder
+	upgraders map[int]Upgrader
+}
+
+// NewRegistry creates a registry that knows the current schema version.
+func NewRegistry() *Registry {
+	r := &Registry{
+		decoders:  make(map[int]Decoder),
+		upgraders: make(map[int]Upgrader),
+	}
+	r.RegisterDecoder(CurrentVersion, decodeCurrent)
+
+	return r
+}
+
+// RegisterDecoder registers a decoder for the given version.
+func (r *Registry) RegisterDecoder(version int, decoder Decoder) {
+	r.decoders[version] = decoder
+}
+
+// RegisterUpgrader registers an upgrade from the given version to the next one.
+func (r *Registry) RegisterUpgrader(from int, upgrader Upgrader) {
+	r.upgraders[from] = upgrader
+}
+
+// Decode unwraps the message and decodes it into the current model,
+// upgrading older payloads step by step until a decoder is found.
+func (r *Registry) Decode(data []byte) (model.Order, error) {
+	version, payload, err := Unwrap(data)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	return r.DecodePayload(version, payload)
+}
+
+// DecodePayload decodes the JSON payload of the version into the current model, codecs
+// with their own envelope pass the payload converted to JSON.
+func (r *Registry) DecodePayload(version int, payload []byte) (model.Order, error) {
+	var err error
+	for v := version; v <= CurrentVersion; v++ {
+		if decoder, ok := r.decoders[v]; ok {
+			return decoder(payload)
+		}
+
+		upgrader, ok := r.upgraders[v]
+		if !ok {
+			break
+		}
+		payload, err = upgrader(payload)
+		if err != nil {
+			return model.Order{}, fmt.Errorf("upgrade schema version %d: %w", v, err)
+		}
+	}
+
+	return model.Order{}, fmt.Errorf("%w %d", ErrUnknownVersion, version)
+}
+
+// Wrap puts the order into an envelope of the current version.
+func Wrap(order model.Order) ([]byte, error) {
+	payload, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Envelope{
+		SchemaVersion: CurrentVersion,
+		Payload:       payload,
+	})
+}
+
+// Unwrap returns the schema version and payload of the message.
+// Messages without an envelope are treated as LegacyVersion payloads.
+func Unwrap(data []byte) (int, []byte, error) {
+	var probe struct {
+		SchemaVersion *int            `json:"schema_version"`
+		Payload       json.RawMessage `json:"payload"`
+	}
+	err := json.Unmarshal(data, &probe)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if probe.SchemaVersion == nil {
+		return LegacyVersion, data, nil
+	}
+
+	return *probe.SchemaVersion, probe.Payload, nil
+}
+
+func decodeCurrent(payload []byte) (model.Order, error) {
+	var order model.Order
+	err := json.Unmarshal(payload, &order)
+	return order, err
+}
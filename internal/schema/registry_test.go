@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+func TestDecode(t *testing.T) {
+	order := model.Order{OrderUID: "b563feb7b2b84b6test", TrackNumber: "WBILMTESTTRACK"}
+	legacy, err := json.Marshal(order)
+	require.NoError(t, err)
+	wrapped, err := Wrap(order)
+	require.NoError(t, err)
+
+	registry := NewRegistry()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "legacy payload without envelope", data: legacy},
+		{name: "current version envelope", data: wrapped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := registry.Decode(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, order.OrderUID, decoded.OrderUID)
+			assert.Equal(t, order.TrackNumber, decoded.TrackNumber)
+		})
+	}
+}
+
+func TestDecodeUpgrade(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterUpgrader(0, func(payload []byte) ([]byte, error) {
+		var old struct {
+			UID string `json:"uid"`
+		}
+		if err := json.Unmarshal(payload, &old); err != nil {
+			return nil, err
+		}
+		return json.Marshal(model.Order{OrderUID: old.UID})
+	})
+
+	data := []byte(`{"schema_version":0,"payload":{"uid":"old-order"}}`)
+	decoded, err := registry.Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, "old-order", decoded.OrderUID)
+}
+
+func TestDecodeUnknownVersion(t *testing.T) {
+	registry := NewRegistry()
+
+	_, err := registry.Decode([]byte(`{"schema_version":42,"payload":{}}`))
+	assert.True(t, errors.Is(err, ErrUnknownVersion))
+	assert.EqualError(t, err, "unknown schema version 42")
+}
@@ -2,27 +2,83 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 
+	"github.com/msmkdenis/wb-order-nats/internal/archive"
+	"github.com/msmkdenis/wb-order-nats/internal/audit"
+	"github.com/msmkdenis/wb-order-nats/internal/codec"
 	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/pii"
+	"github.com/msmkdenis/wb-order-nats/internal/signature"
 )
 
 type OrderRepository interface {
-	Insert(ctx context.Context, order model.Order) error
+	Insert(ctx context.Context, order model.Order, entry model.OrderAudit, outbox []model.OutboxMessage, events ...model.Event) error
 	SelectByID(ctx context.Context, orderID string) (*model.Order, error)
 	SelectAll(ctx context.Context) ([]model.Order, error)
+	InsertQuarantine(ctx context.Context, order model.Order, reason string) error
+	EraseCustomer(ctx context.Context, customerID string, hardDelete bool, principal string, archived []model.Order, newAudit func(model.Order) (model.OrderAudit, error)) ([]string, error)
+	SelectByCustomerID(ctx context.Context, customerID string) ([]model.Order, error)
+	SelectByTrackNumber(ctx context.Context, trackNumber string) ([]model.Order, error)
+	SelectByStatus(ctx context.Context, status string) ([]model.Order, error)
+	SelectQuarantine(ctx context.Context, limit int) ([]model.QuarantinedOrder, error)
+	RequeueQuarantine(ctx context.Context, ids []string, all bool, subject string, encode func(model.Order) ([]byte, error)) ([]string, error)
+	SelectAuditByOrderID(ctx context.Context, orderID string) ([]model.OrderAudit, error)
+	UpdateStatus(ctx context.Context, orderUID string, from string, to string, reason string, source string, entry model.OrderAudit, events ...model.Event) (bool, error)
+	InsertEvent(ctx context.Context, event model.Event) error
+	SelectStatusHistory(ctx context.Context, orderID string) ([]model.StatusChange, error)
 }
 
 type CacheSetter interface {
 	SetOrder(key string, value model.Order)
+	DeleteOrder(key string)
 	RestoreCache(orders []model.Order)
 }
 
+// Archive reads orders moved out of the database and erases customer data from them.
+type Archive interface {
+	Find(orderUID string) (*model.Order, error)
+	FindByCustomer(customerID string) ([]model.Order, error)
+	Erase(customerID string, hardDelete bool) ([]model.Order, error)
+}
+
+var ErrRequeueDisabled = errors.New("requeue subject is not configured")
+
+// Erasure modes of customer data.
+const (
+	ErasureAnonymise = "anonymise"
+	ErasureDelete    = "delete"
+)
+
+// ErasureResult describes the outcome of a customer data erasure.
+type ErasureResult struct {
+	CustomerID string   `json:"customer_id"`
+	Mode       string   `json:"mode"`
+	OrderUIDs  []string `json:"order_uids"`
+}
+
+// RequeueResult lists the orders moved from the quarantine back to the queue.
+type RequeueResult struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
 type OrderUseCase struct {
-	repository OrderRepository
-	cache      CacheSetter
-	logger     *zap.Logger
+	repository     OrderRepository
+	cache          CacheSetter
+	logger         *zap.Logger
+	hardDelete     bool
+	outboxSubject  string
+	requeueSubject string
+	requeueCodec   codec.Codec
+	requeueSigner  *signature.Signer
+	archive        Archive
 }
 
 func NewOrderUseCase(repository OrderRepository, cache CacheSetter, logger *zap.Logger) *OrderUseCase {
@@ -34,7 +90,24 @@ func NewOrderUseCase(repository OrderRepository, cache CacheSetter, logger *zap.
 }
 
 func (o *OrderUseCase) Save(ctx context.Context, order model.Order) error {
-	err := o.repository.Insert(ctx, order)
+	order.Status = model.StatusCreated
+	masked := pii.DefaultPolicy.Order(order)
+	event, err := newEvent(model.EventOrderSaved, order.OrderUID, masked)
+	if err != nil {
+		return err
+	}
+
+	outbox, err := o.outbox(masked)
+	if err != nil {
+		return err
+	}
+
+	entry, err := newAudit(ctx, order.OrderUID, audit.ActionCreated, nil, masked)
+	if err != nil {
+		return err
+	}
+
+	err = o.repository.Insert(ctx, order, entry, outbox, event)
 	if err != nil {
 		return err
 	}
@@ -43,20 +116,257 @@ func (o *OrderUseCase) Save(ctx context.Context, order model.Order) error {
 	return nil
 }
 
+// SetOutboxSubject enables republishing of saved orders to the subject, an empty subject disables it.
+func (o *OrderUseCase) SetOutboxSubject(subject string) {
+	o.outboxSubject = subject
+}
+
+// outbox returns the order.saved message written to the outbox with the order. Callers pass the
+// order with personal data masked, the message leaves the service like a webhook event does.
+func (o *OrderUseCase) outbox(order model.Order) ([]model.OutboxMessage, error) {
+	if o.outboxSubject == "" {
+		return nil, nil
+	}
+
+	payload, err := json.Marshal(model.OrderSaved{
+		Type:       model.EventOrderSaved,
+		OrderUID:   order.OrderUID,
+		CustomerID: order.CustomerID,
+		Status:     order.Status,
+		SavedAt:    time.Now().UTC(),
+		Order:      order,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal outbox message: %w", err)
+	}
+
+	return []model.OutboxMessage{{Subject: o.outboxSubject, Payload: payload}}, nil
+}
+
+// ReportFailure notifies webhook subscribers that the order could not be processed.
+func (o *OrderUseCase) ReportFailure(ctx context.Context, orderUID string, reason string) error {
+	event, err := newEvent(model.EventOrderFailed, orderUID, map[string]string{
+		"order_uid": orderUID,
+		"reason":    reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	return o.repository.InsertEvent(ctx, event)
+}
+
+func newEvent(eventType string, orderUID string, data any) (model.Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return model.Event{}, fmt.Errorf("unable to marshal %s event: %w", eventType, err)
+	}
+
+	return model.Event{Type: eventType, OrderUID: orderUID, Payload: payload}, nil
+}
+
+// Quarantine stores the rejected order for later inspection instead of saving it.
+func (o *OrderUseCase) Quarantine(ctx context.Context, order model.Order, reason string) error {
+	return o.repository.InsertQuarantine(ctx, order, reason)
+}
+
+// SetErasureMode selects whether EraseCustomer anonymises delivery data or deletes orders.
+func (o *OrderUseCase) SetErasureMode(mode string) error {
+	switch mode {
+	case "", ErasureAnonymise:
+		o.hardDelete = false
+	case ErasureDelete:
+		o.hardDelete = true
+	default:
+		return fmt.Errorf("unknown erasure mode %q", mode)
+	}
+	return nil
+}
+
+// EraseCustomer erases personal data tied to the customer from the database and the archive and
+// purges the affected orders from the cache. The audit entries of the database and the archived
+// orders are written in the erasure transaction, the archive is erased after its commit, so a
+// failed archive erasure is retried by erasing the customer again. On a partial failure the
+// result lists the orders erased before it together with the error.
+func (o *OrderUseCase) EraseCustomer(ctx context.Context, customerID string) (*ErasureResult, error) {
+	ctx = audit.WithSource(ctx, audit.SourceAdmin)
+	principal := audit.PrincipalFromContext(ctx)
+
+	var archived []model.Order
+	if o.archive != nil {
+		var err error
+		archived, err = o.archive.FindByCustomer(customerID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archived orders: %w", err)
+		}
+	}
+
+	mode := ErasureAnonymise
+	if o.hardDelete {
+		mode = ErasureDelete
+	}
+
+	newErasureAudit := func(order model.Order) (model.OrderAudit, error) {
+		masked := pii.DefaultPolicy.Order(order)
+		if o.hardDelete {
+			return newAudit(ctx, order.OrderUID, audit.ActionDeleted, masked, nil)
+		}
+		return newAudit(ctx, order.OrderUID, audit.ActionUpdated, masked, pii.Erase(masked))
+	}
+
+	// a partial failure, e.g. of one shard, still returns the orders erased and audited elsewhere
+	orderUIDs, err := o.repository.EraseCustomer(ctx, customerID, o.hardDelete, principal, archived, newErasureAudit)
+	for _, orderUID := range orderUIDs {
+		o.cache.DeleteOrder(orderUID)
+	}
+	result := &ErasureResult{
+		CustomerID: customerID,
+		Mode:       mode,
+		OrderUIDs:  orderUIDs,
+	}
+	if err != nil {
+		if len(orderUIDs) > 0 {
+			o.logger.Error("customer data erased partially", zap.Strings("order_uids", orderUIDs), zap.String("principal", principal), zap.Error(err))
+		}
+		return result, err
+	}
+
+	if o.archive != nil {
+		erased, err := o.archive.Erase(customerID, o.hardDelete)
+		for _, order := range erased {
+			if !slices.Contains(result.OrderUIDs, order.OrderUID) {
+				result.OrderUIDs = append(result.OrderUIDs, order.OrderUID)
+			}
+		}
+		if err != nil {
+			o.logger.Error("customer data erased partially", zap.Strings("order_uids", result.OrderUIDs), zap.String("principal", principal), zap.Error(err))
+			return result, fmt.Errorf("unable to erase archived orders: %w", err)
+		}
+	}
+
+	o.logger.Info("customer data erased", zap.String("mode", mode), zap.Int("orders", len(result.OrderUIDs)), zap.String("principal", principal))
+	return result, nil
+}
+
+// History returns the audit trail of the order.
+func (o *OrderUseCase) History(ctx context.Context, orderID string) ([]model.OrderAudit, error) {
+	return o.repository.SelectAuditByOrderID(ctx, orderID)
+}
+
+// newAudit returns the audit entry of the change, written by the repository in the transaction
+// of the change itself. Callers pass snapshots with personal data already masked, nil for a
+// missing side.
+func newAudit(ctx context.Context, orderUID string, action string, before any, after any) (model.OrderAudit, error) {
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		return model.OrderAudit{}, fmt.Errorf("unable to diff order %s: %w", orderUID, err)
+	}
+
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return model.OrderAudit{}, fmt.Errorf("unable to marshal order %s diff: %w", orderUID, err)
+	}
+
+	return model.OrderAudit{
+		OrderUID:  orderUID,
+		Action:    action,
+		Source:    audit.SourceFromContext(ctx),
+		Principal: audit.PrincipalFromContext(ctx),
+		Diff:      diff,
+	}, nil
+}
+
 func (o *OrderUseCase) FindByID(ctx context.Context, orderID string) (*model.Order, error) {
+	order, _, err := o.Lookup(ctx, orderID)
+	return order, err
+}
+
+// SetArchive makes FindByID and Lookup read orders missing in the database from the archive
+// and EraseCustomer erase the customer from it.
+func (o *OrderUseCase) SetArchive(archive Archive) {
+	o.archive = archive
+}
+
+// Lookup is FindByID reporting whether the order was read from the archive. Archived orders are
+// not cached, the archiver has evicted them.
+func (o *OrderUseCase) Lookup(ctx context.Context, orderID string) (*model.Order, bool, error) {
 	order, err := o.repository.SelectByID(ctx, orderID)
+	if errors.Is(err, pgx.ErrNoRows) && o.archive != nil {
+		archived, archiveErr := o.archive.Find(orderID)
+		if archiveErr == nil {
+			return archived, true, nil
+		}
+		if !errors.Is(archiveErr, archive.ErrNotArchived) {
+			o.logger.Error("unable to read archived order", zap.String("id", orderID), zap.Error(archiveErr))
+		}
+	}
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	o.cache.SetOrder(orderID, *order)
-	return order, nil
+	return order, false, nil
 }
 
 func (o *OrderUseCase) FindAll(ctx context.Context) ([]model.Order, error) {
 	return o.repository.SelectAll(ctx)
 }
 
+func (o *OrderUseCase) FindByTrackNumber(ctx context.Context, trackNumber string) ([]model.Order, error) {
+	return o.repository.SelectByTrackNumber(ctx, trackNumber)
+}
+
+func (o *OrderUseCase) FindByStatus(ctx context.Context, status string) ([]model.Order, error) {
+	return o.repository.SelectByStatus(ctx, status)
+}
+
+func (o *OrderUseCase) FindByCustomerID(ctx context.Context, customerID string) ([]model.Order, error) {
+	return o.repository.SelectByCustomerID(ctx, customerID)
+}
+
+// Quarantined returns the latest quarantined orders.
+func (o *OrderUseCase) Quarantined(ctx context.Context, limit int) ([]model.QuarantinedOrder, error) {
+	return o.repository.SelectQuarantine(ctx, limit)
+}
+
+// SetRequeueSubject sets the subject quarantined orders are published to again by Requeue and
+// the codec of the subject. Requeued orders are signed again by the signer when it is not nil,
+// so they pass signature verification.
+func (o *OrderUseCase) SetRequeueSubject(subject string, c codec.Codec, signer *signature.Signer) {
+	o.requeueSubject = subject
+	o.requeueCodec = c
+	o.requeueSigner = signer
+}
+
+// Requeue publishes quarantined orders with the ids, or all of them, again through the outbox
+// and removes them from the quarantine.
+func (o *OrderUseCase) Requeue(ctx context.Context, ids []string, all bool) (*RequeueResult, error) {
+	if o.requeueSubject == "" {
+		return nil, ErrRequeueDisabled
+	}
+
+	orderUIDs, err := o.repository.RequeueQuarantine(ctx, ids, all, o.requeueSubject, o.encodeRequeued)
+	if err != nil {
+		return nil, err
+	}
+
+	o.logger.Info("quarantined orders requeued", zap.Int("orders", len(orderUIDs)), zap.String("principal", audit.PrincipalFromContext(ctx)))
+	return &RequeueResult{OrderUIDs: orderUIDs}, nil
+}
+
+// encodeRequeued signs the order and encodes it as published to the requeue subject.
+func (o *OrderUseCase) encodeRequeued(order model.Order) ([]byte, error) {
+	if o.requeueSigner != nil {
+		var err error
+		order.InternalSignature, err = o.requeueSigner.Sign(order)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codec.Encode(o.requeueCodec, order)
+}
+
 func (o *OrderUseCase) RestoreCache() error {
 	orders, err := o.repository.SelectAll(context.Background())
 	if err != nil {
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/codec"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/pii"
+	"github.com/msmkdenis/wb-order-nats/internal/signature"
+)
+
+type insertRepository struct {
+	OrderRepository
+	outbox []model.OutboxMessage
+	events []model.Event
+}
+
+func (r *insertRepository) Insert(_ context.Context, _ model.Order, _ model.OrderAudit, outbox []model.OutboxMessage, events ...model.Event) error {
+	r.outbox, r.events = outbox, events
+	return nil
+}
+
+func TestSaveMasksPublishedOrder(t *testing.T) {
+	repository := &insertRepository{}
+	o := NewOrderUseCase(repository, &fakeCache{orders: make(map[string]model.Order)}, zap.NewNop())
+	o.SetOutboxSubject("orders.saved")
+
+	order := model.Order{OrderUID: "order", CustomerID: "customer", Delivery: model.Delivery{
+		Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+		Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+	}}
+	require.NoError(t, o.Save(context.Background(), order))
+
+	require.Len(t, repository.outbox, 1)
+	var saved model.OrderSaved
+	require.NoError(t, json.Unmarshal(repository.outbox[0].Payload, &saved))
+	assert.Equal(t, pii.DefaultPolicy.Delivery(order.Delivery), saved.Order.Delivery)
+	assert.NotContains(t, string(repository.outbox[0].Payload), order.Delivery.Phone)
+	assert.NotContains(t, string(repository.outbox[0].Payload), order.Delivery.Email)
+
+	require.Len(t, repository.events, 1)
+	assert.NotContains(t, string(repository.events[0].Payload), order.Delivery.Phone)
+}
+
+type requeueRepository struct {
+	OrderRepository
+	quarantined []model.Order
+	subject     string
+	encoded     [][]byte
+}
+
+func (r *requeueRepository) RequeueQuarantine(_ context.Context, _ []string, _ bool, subject string, encode func(model.Order) ([]byte, error)) ([]string, error) {
+	r.subject = subject
+	orderUIDs := make([]string, 0, len(r.quarantined))
+	for _, order := range r.quarantined {
+		data, err := encode(order)
+		if err != nil {
+			return nil, err
+		}
+		r.encoded = append(r.encoded, data)
+		orderUIDs = append(orderUIDs, order.OrderUID)
+	}
+	return orderUIDs, nil
+}
+
+func TestRequeueEncodesWithSubjectCodec(t *testing.T) {
+	repository := &requeueRepository{quarantined: []model.Order{{
+		OrderUID: "order", CustomerID: "customer", InternalSignature: "v1:k0:forged",
+		Items: []model.Item{{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Name: "Mascaras"}},
+	}}}
+	protobuf, err := codec.ByName("protobuf")
+	require.NoError(t, err)
+	keys := signature.Keys{"k1": []byte("secret")}
+
+	o := NewOrderUseCase(repository, &fakeCache{orders: make(map[string]model.Order)}, zap.NewNop())
+	o.SetRequeueSubject("orders", protobuf, signature.NewSigner("k1", keys["k1"]))
+	result, err := o.Requeue(context.Background(), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"order"}, result.OrderUIDs)
+	assert.Equal(t, "orders", repository.subject)
+
+	require.Len(t, repository.encoded, 1)
+	contentType, body, err := codec.SplitHeader(repository.encoded[0])
+	require.NoError(t, err)
+	assert.Equal(t, codec.ContentTypeProtobuf, contentType)
+	order, err := protobuf.Unmarshal(body)
+	require.NoError(t, err)
+	assert.Equal(t, "order", order.OrderUID)
+	assert.NoError(t, signature.NewVerifier(keys).Verify(order))
+}
+
+type eraseRepository struct {
+	OrderRepository
+	orders  []model.Order
+	erased  []string
+	err     error
+	entries []model.OrderAudit
+}
+
+func (r *eraseRepository) EraseCustomer(_ context.Context, _ string, _ bool, _ string, archived []model.Order, newAudit func(model.Order) (model.OrderAudit, error)) ([]string, error) {
+	for _, order := range append(r.orders, archived...) {
+		entry, err := newAudit(order)
+		if err != nil {
+			return nil, err
+		}
+		r.entries = append(r.entries, entry)
+	}
+	return r.erased, r.err
+}
+
+type eraseArchive struct {
+	Archive
+	orders []model.Order
+	erased bool
+	err    error
+}
+
+func (a *eraseArchive) FindByCustomer(string) ([]model.Order, error) {
+	return a.orders, nil
+}
+
+func (a *eraseArchive) Erase(string, bool) ([]model.Order, error) {
+	a.erased = true
+	return a.orders, a.err
+}
+
+func TestEraseCustomerPartialFailure(t *testing.T) {
+	repository := &eraseRepository{
+		orders: []model.Order{{OrderUID: "order1", CustomerID: "customer"}, {OrderUID: "order2", CustomerID: "customer"}},
+		erased: []string{"order1"},
+		err:    errors.New("shard shard2: connection refused"),
+	}
+	cache := &fakeCache{orders: map[string]model.Order{"order1": {}, "order2": {}}}
+	archive := &eraseArchive{orders: []model.Order{{OrderUID: "archived", CustomerID: "customer"}}}
+	o := NewOrderUseCase(repository, cache, zap.NewNop())
+	o.SetArchive(archive)
+
+	result, err := o.EraseCustomer(context.Background(), "customer")
+	assert.ErrorIs(t, err, repository.err)
+	require.NotNil(t, result)
+	assert.Equal(t, []string{"order1"}, result.OrderUIDs, "the result reports the orders erased before the failure")
+	assert.NotContains(t, cache.orders, "order1", "orders erased before the failure leave the cache")
+	assert.Contains(t, cache.orders, "order2")
+	assert.False(t, archive.erased, "the archive is erased only after the database erasure commits")
+}
+
+func TestEraseCustomerArchive(t *testing.T) {
+	repository := &eraseRepository{
+		orders: []model.Order{{OrderUID: "order1", CustomerID: "customer"}},
+		erased: []string{"order1"},
+	}
+	archive := &eraseArchive{orders: []model.Order{{OrderUID: "archived", CustomerID: "customer"}}}
+	o := NewOrderUseCase(repository, &fakeCache{orders: make(map[string]model.Order)}, zap.NewNop())
+	o.SetArchive(archive)
+
+	result, err := o.EraseCustomer(context.Background(), "customer")
+	require.NoError(t, err)
+	assert.True(t, archive.erased)
+	assert.Equal(t, []string{"order1", "archived"}, result.OrderUIDs)
+	require.Len(t, repository.entries, 2, "archived orders are audited in the erasure transaction")
+	assert.Equal(t, "archived", repository.entries[1].OrderUID)
+
+	archive.err = errors.New("disk full")
+	result, err = o.EraseCustomer(context.Background(), "customer")
+	assert.ErrorIs(t, err, archive.err)
+	require.NotNil(t, result)
+	assert.Equal(t, []string{"order1", "archived"}, result.OrderUIDs)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/audit"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("unknown order status")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	ErrStatusConflict    = errors.New("order status changed concurrently")
+)
+
+// transitions lists statuses reachable from every status, cancelled and returned are final.
+var transitions = map[string][]string{
+	model.StatusCreated:    {model.StatusPaid, model.StatusCancelled},
+	model.StatusPaid:       {model.StatusAssembling, model.StatusCancelled},
+	model.StatusAssembling: {model.StatusShipped, model.StatusCancelled},
+	model.StatusShipped:    {model.StatusDelivered, model.StatusReturned},
+	model.StatusDelivered:  {model.StatusReturned},
+	model.StatusCancelled:  {},
+	model.StatusReturned:   {},
+}
+
+// CanTransition reports whether the order may move from one status to another.
+func CanTransition(from string, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// ChangeStatus moves the order to the status of the event if the state machine allows it.
+func (o *OrderUseCase) ChangeStatus(ctx context.Context, event model.StatusEvent) error {
+	if _, ok := transitions[event.Status]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownStatus, event.Status)
+	}
+
+	order, err := o.repository.SelectByID(ctx, event.OrderUID)
+	if err != nil {
+		return err
+	}
+
+	if !CanTransition(order.Status, event.Status) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, order.Status, event.Status)
+	}
+
+	updated, err := newEvent(model.EventOrderUpdated, order.OrderUID, map[string]string{
+		"order_uid": order.OrderUID,
+		"from":      order.Status,
+		"to":        event.Status,
+		"reason":    event.Reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	entry, err := newAudit(ctx, order.OrderUID, audit.ActionUpdated,
+		map[string]string{"status": order.Status}, map[string]string{"status": event.Status})
+	if err != nil {
+		return err
+	}
+
+	ok, err := o.repository.UpdateStatus(ctx, order.OrderUID, order.Status, event.Status, event.Reason, audit.SourceFromContext(ctx), entry, updated)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrStatusConflict
+	}
+
+	before := order.Status
+	order.Status = event.Status
+	o.cache.SetOrder(order.OrderUID, *order)
+	o.logger.Info("order status changed", zap.String("id", order.OrderUID), zap.String("from", before), zap.String("to", event.Status))
+
+	return nil
+}
+
+// StatusHistory returns the status changes of the order.
+func (o *OrderUseCase) StatusHistory(ctx context.Context, orderID string) ([]model.StatusChange, error) {
+	return o.repository.SelectStatusHistory(ctx, orderID)
+}
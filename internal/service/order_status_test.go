@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/audit"
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+type fakeRepository struct {
+	OrderRepository
+	orders  map[string]model.Order
+	updated []string
+	entries []model.OrderAudit
+}
+
+func (f *fakeRepository) SelectByID(_ context.Context, orderID string) (*model.Order, error) {
+	order, ok := f.orders[orderID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return &order, nil
+}
+
+func (f *fakeRepository) UpdateStatus(_ context.Context, orderUID string, from string, to string, _ string, _ string, entry model.OrderAudit, _ ...model.Event) (bool, error) {
+	order, ok := f.orders[orderUID]
+	if !ok || order.Status != from {
+		return false, nil
+	}
+	order.Status = to
+	f.orders[orderUID] = order
+	f.updated = append(f.updated, orderUID)
+	f.entries = append(f.entries, entry)
+	return true, nil
+}
+
+type fakeCache struct {
+	CacheSetter
+	orders map[string]model.Order
+}
+
+func (f *fakeCache) SetOrder(key string, value model.Order) {
+	f.orders[key] = value
+}
+
+func (f *fakeCache) DeleteOrder(key string) {
+	delete(f.orders, key)
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{model.StatusCreated, model.StatusPaid, true},
+		{model.StatusCreated, model.StatusCancelled, true},
+		{model.StatusCreated, model.StatusShipped, false},
+		{model.StatusPaid, model.StatusAssembling, true},
+		{model.StatusAssembling, model.StatusShipped, true},
+		{model.StatusShipped, model.StatusDelivered, true},
+		{model.StatusShipped, model.StatusCancelled, false},
+		{model.StatusDelivered, model.StatusReturned, true},
+		{model.StatusCancelled, model.StatusPaid, false},
+		{model.StatusReturned, model.StatusDelivered, false},
+		{model.StatusPaid, model.StatusPaid, false},
+		{"unknown", model.StatusPaid, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CanTransition(tt.from, tt.to), "%s -> %s", tt.from, tt.to)
+	}
+}
+
+func TestChangeStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		event   model.StatusEvent
+		wantErr error
+		want    string
+	}{
+		{
+			name:   "allowed transition",
+			status: model.StatusCreated,
+			event:  model.StatusEvent{OrderUID: "order", Status: model.StatusPaid},
+			want:   model.StatusPaid,
+		},
+		{
+			name:    "unknown status",
+			status:  model.StatusCreated,
+			event:   model.StatusEvent{OrderUID: "order", Status: "lost"},
+			wantErr: ErrUnknownStatus,
+			want:    model.StatusCreated,
+		},
+		{
+			name:    "invalid transition",
+			status:  model.StatusCancelled,
+			event:   model.StatusEvent{OrderUID: "order", Status: model.StatusPaid},
+			wantErr: ErrInvalidTransition,
+			want:    model.StatusCancelled,
+		},
+		{
+			name:    "missing order",
+			status:  model.StatusCreated,
+			event:   model.StatusEvent{OrderUID: "missing", Status: model.StatusPaid},
+			wantErr: pgx.ErrNoRows,
+			want:    model.StatusCreated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRepository{orders: map[string]model.Order{"order": {OrderUID: "order", Status: tt.status}}}
+			cache := &fakeCache{orders: make(map[string]model.Order)}
+			o := NewOrderUseCase(repository, cache, zap.NewNop())
+
+			err := o.ChangeStatus(audit.WithSource(context.Background(), audit.SourceNats), tt.event)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, repository.updated)
+				assert.Empty(t, cache.orders)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, cache.orders["order"].Status)
+				require.Len(t, repository.entries, 1)
+				assert.Equal(t, audit.ActionUpdated, repository.entries[0].Action)
+				assert.Equal(t, audit.SourceNats, repository.entries[0].Source)
+				assert.JSONEq(t, `{"status": {"before": "created", "after": "paid"}}`, string(repository.entries[0].Diff))
+			}
+			assert.Equal(t, tt.want, repository.orders["order"].Status)
+		})
+	}
+}
+
+// conflictRepository changes the status between the read and the update.
+type conflictRepository struct {
+	*fakeRepository
+}
+
+func (c conflictRepository) SelectByID(ctx context.Context, orderID string) (*model.Order, error) {
+	order, err := c.fakeRepository.SelectByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	concurrent := *order
+	concurrent.Status = model.StatusCancelled
+	c.orders[orderID] = concurrent
+	return order, nil
+}
+
+func TestChangeStatusConflict(t *testing.T) {
+	repository := conflictRepository{&fakeRepository{orders: map[string]model.Order{"order": {OrderUID: "order", Status: model.StatusCreated}}}}
+	cache := &fakeCache{orders: make(map[string]model.Order)}
+	o := NewOrderUseCase(repository, cache, zap.NewNop())
+
+	err := o.ChangeStatus(context.Background(), model.StatusEvent{OrderUID: "order", Status: model.StatusPaid})
+	require.ErrorIs(t, err, ErrStatusConflict)
+	assert.Empty(t, cache.orders)
+	assert.Equal(t, model.StatusCancelled, repository.orders["order"].Status)
+}
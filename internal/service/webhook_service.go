@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/internal/webhook"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+)
+
+// deliveriesLimit caps the number of deliveries returned for a subscription.
+const deliveriesLimit = 100
+
+type WebhookRepository interface {
+	InsertSubscription(ctx context.Context, subscription model.WebhookSubscription) (*model.WebhookSubscription, error)
+	SelectSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) (bool, error)
+	SelectDeliveries(ctx context.Context, subscriptionID string, limit int) ([]model.WebhookDelivery, error)
+}
+
+type WebhookUseCase struct {
+	repository   WebhookRepository
+	logger       *zap.Logger
+	allowPrivate bool
+}
+
+func NewWebhookUseCase(repository WebhookRepository, logger *zap.Logger) *WebhookUseCase {
+	return &WebhookUseCase{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+// SetAllowPrivate allows subscription URLs pointing to internal addresses.
+func (w *WebhookUseCase) SetAllowPrivate(allow bool) {
+	w.allowPrivate = allow
+}
+
+// Subscribe registers the subscription, generating a signing secret when none is given.
+// The secret is only returned here, later listings omit it. URLs that are not http(s) or
+// resolve to internal addresses are rejected with ErrInvalidSubscription.
+func (w *WebhookUseCase) Subscribe(ctx context.Context, subscription model.WebhookSubscription) (*model.WebhookSubscription, error) {
+	err := webhook.ValidateURL(ctx, subscription.URL, w.allowPrivate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSubscription, err)
+	}
+
+	if subscription.Secret == "" {
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		if err != nil {
+			return nil, err
+		}
+		subscription.Secret = hex.EncodeToString(secret)
+	}
+
+	return w.repository.InsertSubscription(ctx, subscription)
+}
+
+func (w *WebhookUseCase) Subscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	subscriptions, err := w.repository.SelectSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+func (w *WebhookUseCase) Unsubscribe(ctx context.Context, id string) error {
+	ok, err := w.repository.DeleteSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// Deliveries returns the latest deliveries of the subscription.
+func (w *WebhookUseCase) Deliveries(ctx context.Context, subscriptionID string) ([]model.WebhookDelivery, error) {
+	return w.repository.SelectDeliveries(ctx, subscriptionID, deliveriesLimit)
+}
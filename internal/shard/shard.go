@@ -0,0 +1,97 @@
+// Package shard assigns orders to databases by their shard key.
+package shard
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// replicas is the number of points of every shard on the ring, more points spread keys evenly.
+const replicas = 128
+
+// Shard is a database orders are stored in.
+type Shard struct {
+	Name string
+	URI  string
+}
+
+// Parse parses DATABASE_SHARDS, name=uri pairs separated by semicolons. A URI may be a key=value
+// connection string, only the first equals sign separates the name.
+func Parse(spec string) ([]Shard, error) {
+	var shards []Shard
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, uri, ok := strings.Cut(entry, "=")
+		name, uri = strings.TrimSpace(name), strings.TrimSpace(uri)
+		if !ok || name == "" || uri == "" {
+			return nil, fmt.Errorf("invalid shard %q, expected name=uri", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate shard %q", name)
+		}
+		seen[name] = true
+		shards = append(shards, Shard{Name: name, URI: uri})
+	}
+	return shards, nil
+}
+
+type point struct {
+	hash uint64
+	name string
+}
+
+// Ring is a consistent hash ring of shard names, adding a shard moves only the keys of the
+// ring arcs it takes over.
+type Ring struct {
+	points []point
+}
+
+func NewRing(names []string) *Ring {
+	r := &Ring{points: make([]point, 0, len(names)*replicas)}
+	for _, name := range names {
+		for i := 0; i < replicas; i++ {
+			r.points = append(r.points, point{hash: hash(name + "#" + strconv.Itoa(i)), name: name})
+		}
+	}
+	slices.SortFunc(r.points, func(a, b point) int {
+		if a.hash != b.hash {
+			return cmp.Compare(a.hash, b.hash)
+		}
+		return cmp.Compare(a.name, b.name)
+	})
+	return r
+}
+
+// Get returns the shard of the key, the first ring point at or after the key hash.
+func (r *Ring) Get(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i, _ := slices.BinarySearchFunc(r.points, h, func(p point, h uint64) int { return cmp.Compare(p.hash, h) })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].name
+}
+
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	// FNV clusters similar short keys, the murmur3 finalizer spreads them over the ring
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
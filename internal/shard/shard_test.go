@@ -0,0 +1,43 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	shards, err := Parse(" a=postgres://a/db ; b=user=postgres host=b ;")
+	require.NoError(t, err)
+	assert.Equal(t, []Shard{{Name: "a", URI: "postgres://a/db"}, {Name: "b", URI: "user=postgres host=b"}}, shards)
+
+	_, err = Parse("a=x;a=y")
+	assert.Error(t, err)
+	_, err = Parse("postgres://a/db")
+	assert.Error(t, err)
+}
+
+func TestRing(t *testing.T) {
+	three := NewRing([]string{"a", "b", "c"})
+	four := NewRing([]string{"a", "b", "c", "d"})
+
+	counts := make(map[string]int)
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before, after := three.Get(key), four.Get(key)
+		counts[before]++
+		if before != after {
+			assert.Equal(t, "d", after, "keys only move to the new shard")
+			moved++
+		}
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		assert.InDelta(t, 3333, counts[name], 700, name)
+	}
+	assert.InDelta(t, 2500, moved, 700)
+	assert.Equal(t, three.Get("9"), three.Get("9"))
+	assert.Empty(t, NewRing(nil).Get("9"))
+}
@@ -0,0 +1,131 @@
+// Package signature implements HMAC signing and verification of orders via internal_signature.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+// Verification modes.
+const (
+	ModeOff        = "off"
+	ModeReject     = "reject"
+	ModeQuarantine = "quarantine"
+)
+
+const version = "v1"
+
+var (
+	ErrUnsigned         = errors.New("order is not signed")
+	ErrMalformed        = errors.New("malformed signature")
+	ErrUnknownKey       = errors.New("unknown signature key")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Keys maps key ids to HMAC secrets.
+type Keys map[string][]byte
+
+// ParseKeys parses keys in the form "id1:secret1,id2:secret2".
+func ParseKeys(raw string) (Keys, error) {
+	keys := make(Keys)
+	if raw == "" {
+		return keys, nil
+	}
+
+	for _, pair := range strings.Split(raw, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("invalid signature key %q, expected id:secret", pair)
+		}
+		keys[id] = []byte(secret)
+	}
+
+	return keys, nil
+}
+
+// Canonical returns the payload covered by the signature: the order JSON without internal_signature.
+func Canonical(order model.Order) ([]byte, error) {
+	order.InternalSignature = ""
+	return json.Marshal(order)
+}
+
+// Signer signs orders with a single key.
+type Signer struct {
+	keyID  string
+	secret []byte
+}
+
+func NewSigner(keyID string, secret []byte) *Signer {
+	return &Signer{
+		keyID:  keyID,
+		secret: secret,
+	}
+}
+
+// Sign returns the signature in the form "v1:<key id>:<base64 hmac-sha256>".
+func (s *Signer) Sign(order model.Order) (string, error) {
+	payload, err := Canonical(order)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:%s:%s", version, s.keyID, base64.RawURLEncoding.EncodeToString(mac(s.secret, payload))), nil
+}
+
+// Verifier checks order signatures against a set of keys, so keys can be rotated
+// by adding the new key before producers switch to it.
+type Verifier struct {
+	keys Keys
+}
+
+func NewVerifier(keys Keys) *Verifier {
+	return &Verifier{
+		keys: keys,
+	}
+}
+
+// Verify checks the internal_signature of the order.
+func (v *Verifier) Verify(order model.Order) error {
+	if order.InternalSignature == "" {
+		return ErrUnsigned
+	}
+
+	parts := strings.Split(order.InternalSignature, ":")
+	if len(parts) != 3 || parts[0] != version {
+		return ErrMalformed
+	}
+
+	secret, ok := v.keys[parts[1]]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, parts[1])
+	}
+
+	sum, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrMalformed
+	}
+
+	payload, err := Canonical(order)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(sum, mac(secret, payload)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func mac(secret []byte, payload []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,65 @@
+package signature
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+)
+
+func TestVerify(t *testing.T) {
+	verifier := NewVerifier(Keys{"old": []byte("old-secret"), "new": []byte("new-secret")})
+	order := model.Order{OrderUID: "b563feb7b2b84b6test", CustomerID: "test"}
+
+	signedOld, err := NewSigner("old", []byte("old-secret")).Sign(order)
+	require.NoError(t, err)
+	signedNew, err := NewSigner("new", []byte("new-secret")).Sign(order)
+	require.NoError(t, err)
+	forged, err := NewSigner("new", []byte("guessed")).Sign(order)
+	require.NoError(t, err)
+	unknown, err := NewSigner("retired", []byte("old-secret")).Sign(order)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		signature string
+		mutate    func(o *model.Order)
+		wantErr   error
+	}{
+		{name: "signed with old key", signature: signedOld},
+		{name: "signed with new key", signature: signedNew},
+		{name: "unsigned", signature: "", wantErr: ErrUnsigned},
+		{name: "malformed", signature: "garbage", wantErr: ErrMalformed},
+		{name: "forged", signature: forged, wantErr: ErrInvalidSignature},
+		{name: "unknown key", signature: unknown, wantErr: ErrUnknownKey},
+		{name: "tampered payload", signature: signedNew, mutate: func(o *model.Order) { o.CustomerID = "other" }, wantErr: ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := order
+			o.InternalSignature = tt.signature
+			if tt.mutate != nil {
+				tt.mutate(&o)
+			}
+
+			err := verifier.Verify(o)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tt.wantErr), err)
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys("k1:secret1, k2:secret2")
+	require.NoError(t, err)
+	assert.Equal(t, Keys{"k1": []byte("secret1"), "k2": []byte("secret2")}, keys)
+
+	_, err = ParseKeys("k1")
+	assert.Error(t, err)
+}
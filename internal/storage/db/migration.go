@@ -2,11 +2,15 @@
 package db
 
 import (
+	"cmp"
 	"embed"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
@@ -54,15 +58,260 @@ func NewMigrations(connection string, logger *zap.Logger) (*Migrations, error) {
 	}, nil
 }
 
-// MigrateUp perform migrations up.
+var (
+	// ErrDirty means a migration failed midway, the schema must be repaired by hand and the
+	// version set with Force.
+	ErrDirty = errors.New("database schema is dirty")
+	// ErrSchemaTooNew means the database was migrated by a newer binary.
+	ErrSchemaTooNew = errors.New("database schema is newer than the binary")
+	// ErrSchemaOutdated means migrations of the binary are not applied yet.
+	ErrSchemaOutdated = errors.New("database schema is older than the binary")
+	// ErrUnknownVersion means there is no embedded migration with the version.
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+// Startup modes of the service.
+const (
+	// ModeUp applies pending migrations on start.
+	ModeUp = "up"
+	// ModeVerify leaves the schema alone and refuses to start unless it is up to date,
+	// migrations are then run with wbctl migrate.
+	ModeVerify = "verify"
+)
+
+// Startup prepares the schema for the service according to the mode, an empty mode is ModeUp.
+// Both modes refuse a dirty schema or one newer than the binary.
+func (m *Migrations) Startup(mode string) error {
+	switch mode {
+	case "", ModeUp:
+		return m.MigrateUp()
+	case ModeVerify:
+		return m.Verify()
+	default:
+		return fmt.Errorf("unknown migration mode %q", mode)
+	}
+}
+
+// Migration is an embedded migration.
+type Migration struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+}
+
+// Step is a migration applied in the direction, "up" or "down".
+type Step struct {
+	Migration
+	Direction string `json:"direction"`
+}
+
+// Embedded returns the migrations built into the binary, ordered by version.
+func Embedded() ([]Migration, error) {
+	entries, err := migrationsFS.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		parsed, err := source.Parse(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if parsed.Direction == source.Up {
+			migrations = append(migrations, Migration{Version: parsed.Version, Name: parsed.Identifier})
+		}
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+// Latest returns the version of the newest embedded migration.
+func Latest() (uint, error) {
+	migrations, err := Embedded()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// MigrateUp applies all pending migrations.
 func (m *Migrations) MigrateUp() error {
-	err := m.migrations.Up()
-	if err != nil && err.Error() != "no change" {
-		return apperr.NewValueError("Unable to up migrations", apperr.Caller(), err)
+	latest, err := Latest()
+	if err != nil {
+		return apperr.NewValueError("Unable to read embedded migrations", apperr.Caller(), err)
+	}
+	return m.MigrateTo(latest)
+}
+
+// MigrateDown rolls back the given number of applied migrations.
+func (m *Migrations) MigrateDown(steps int) error {
+	target, err := m.downTarget(steps)
+	if err != nil {
+		return err
+	}
+	return m.MigrateTo(target)
+}
+
+// MigrateTo migrates up or down to the version, zero rolls back all migrations.
+func (m *Migrations) MigrateTo(version uint) error {
+	current, err := m.Check()
+	if err != nil {
+		return err
+	}
+	if version == current {
+		return nil
+	}
+
+	if version == 0 {
+		err = m.migrations.Down()
+	} else {
+		if _, err = stepsTo(current, version); err != nil {
+			return err
+		}
+		err = m.migrations.Migrate(version)
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return apperr.NewValueError("Unable to migrate", apperr.Caller(), err)
+	}
+
+	m.logger.Info("Migrated", zap.Uint("from", current), zap.Uint("to", version))
+	return nil
+}
+
+// Force sets the version without running migrations and clears the dirty flag. Use it after
+// repairing the schema of a failed migration, -1 means no migration applied.
+func (m *Migrations) Force(version int) error {
+	err := m.migrations.Force(version)
+	if err != nil {
+		return apperr.NewValueError("Unable to force migrations version", apperr.Caller(), err)
+	}
+	return nil
+}
+
+// Version returns the current schema version and whether the last migration failed midway.
+func (m *Migrations) Version() (uint, bool, error) {
+	version, dirty, err := m.migrations.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, apperr.NewValueError("Unable to read migrations version", apperr.Caller(), err)
+	}
+	return version, dirty, nil
+}
+
+// Check returns the current version, or ErrDirty or ErrSchemaTooNew if migrating is unsafe.
+func (m *Migrations) Check() (uint, error) {
+	current, dirty, err := m.Version()
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return current, fmt.Errorf("%w at version %d", ErrDirty, current)
+	}
+
+	latest, err := Latest()
+	if err != nil {
+		return 0, apperr.NewValueError("Unable to read embedded migrations", apperr.Caller(), err)
+	}
+	if current > latest {
+		return current, fmt.Errorf("%w: version %d, binary knows %d", ErrSchemaTooNew, current, latest)
+	}
+	return current, nil
+}
+
+// Verify fails unless the schema is exactly at the latest embedded version.
+func (m *Migrations) Verify() error {
+	current, err := m.Check()
+	if err != nil {
+		return err
+	}
+
+	latest, err := Latest()
+	if err != nil {
+		return apperr.NewValueError("Unable to read embedded migrations", apperr.Caller(), err)
+	}
+	if current < latest {
+		return fmt.Errorf("%w: version %d, binary needs %d", ErrSchemaOutdated, current, latest)
 	}
 	return nil
 }
 
+// Plan returns the migrations MigrateTo(version) would run, without running them.
+func (m *Migrations) Plan(version uint) ([]Step, error) {
+	current, err := m.Check()
+	if err != nil {
+		return nil, err
+	}
+	return stepsTo(current, version)
+}
+
+// PlanDown returns the migrations MigrateDown(steps) would run, without running them.
+func (m *Migrations) PlanDown(steps int) ([]Step, error) {
+	target, err := m.downTarget(steps)
+	if err != nil {
+		return nil, err
+	}
+	return m.Plan(target)
+}
+
+// downTarget returns the version left after rolling back the number of migrations.
+func (m *Migrations) downTarget(steps int) (uint, error) {
+	current, err := m.Check()
+	if err != nil {
+		return 0, err
+	}
+
+	applied, err := stepsTo(current, 0)
+	if err != nil {
+		return 0, err
+	}
+	if steps < 1 || steps > len(applied) {
+		return 0, fmt.Errorf("unable to roll back %d of %d applied migrations", steps, len(applied))
+	}
+	if steps == len(applied) {
+		return 0, nil
+	}
+	return applied[steps].Version, nil
+}
+
+// stepsTo lists the embedded migrations between the versions in the order they run.
+func stepsTo(current uint, target uint) ([]Step, error) {
+	migrations, err := Embedded()
+	if err != nil {
+		return nil, err
+	}
+	if target != 0 && !slices.ContainsFunc(migrations, func(m Migration) bool { return m.Version == target }) {
+		return nil, fmt.Errorf("%w %d", ErrUnknownVersion, target)
+	}
+
+	steps := []Step{}
+	if target >= current {
+		for _, migration := range migrations {
+			if migration.Version > current && migration.Version <= target {
+				steps = append(steps, Step{Migration: migration, Direction: string(source.Up)})
+			}
+		}
+		return steps, nil
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if migrations[i].Version <= current && migrations[i].Version > target {
+			steps = append(steps, Step{Migration: migrations[i], Direction: string(source.Down)})
+		}
+	}
+	return steps, nil
+}
+
+// Close releases the source and database connections.
+func (m *Migrations) Close() {
+	srcErr, dbErr := m.migrations.Close()
+	if srcErr != nil || dbErr != nil {
+		m.logger.Error("Unable to close migrations", zap.NamedError("source", srcErr), zap.NamedError("database", dbErr))
+	}
+}
+
 func makeDBURL(config *pgxpool.Config, sslMode string) string {
 	var dbURL strings.Builder
 
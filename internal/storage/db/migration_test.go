@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"go.uber.org/zap"
+)
+
+func TestEmbeddedMigrationsHaveDownScripts(t *testing.T) {
+	migrations, err := Embedded()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, uint(i+1), m.Version, "versions must be consecutive")
+		for _, direction := range []string{"up", "down"} {
+			script, err := migrationsFS.ReadFile(fmt.Sprintf("migrations/%06d_%s.%s.sql", m.Version, m.Name, direction))
+			require.NoError(t, err)
+			statements := strings.NewReplacer("begin transaction;", "", "commit transaction;", "").Replace(string(script))
+			assert.NotEmpty(t, strings.TrimSpace(statements), "%06d_%s.%s.sql has no statements", m.Version, m.Name, direction)
+		}
+	}
+}
+
+func TestStepsTo(t *testing.T) {
+	latest, err := Latest()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, latest, uint(3))
+
+	up, err := stepsTo(1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2, 3}, versions(up))
+	assert.Equal(t, "up", up[0].Direction)
+
+	down, err := stepsTo(3, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{3, 2, 1}, versions(down))
+	assert.Equal(t, "down", down[0].Direction)
+
+	none, err := stepsTo(latest, latest)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	_, err = stepsTo(0, latest+1)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+}
+
+func versions(steps []Step) []uint {
+	var v []uint
+	for _, s := range steps {
+		v = append(v, s.Version)
+	}
+	return v
+}
+
+// TestMigrationsUpDown applies every migration, rolls it back and applies it again, so every
+// down script must fully revert its up script.
+func TestMigrationsUpDown(t *testing.T) {
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "postgres:latest",
+			ExposedPorts: []string{"5432/tcp"},
+			WaitingFor:   wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+			Env: map[string]string{
+				"POSTGRES_DB":       "wb-order-test",
+				"POSTGRES_PASSWORD": "postgres",
+				"POSTGRES_USER":     "postgres",
+			},
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Skipf("postgres container is unavailable: %v", err)
+	}
+	defer func() { _ = container.Terminate(ctx) }()
+
+	host, err := container.Host(ctx)
+	require.NoError(t, err)
+	port, err := container.MappedPort(ctx, "5432")
+	require.NoError(t, err)
+	connection := fmt.Sprintf("user=postgres password=postgres host=%s database=wb-order-test sslmode=disable port=%d", host, port.Int())
+
+	logger := zap.NewNop()
+	migrations, err := NewMigrations(connection, logger)
+	require.NoError(t, err)
+	defer migrations.Close()
+
+	embedded, err := Embedded()
+	require.NoError(t, err)
+	for _, m := range embedded {
+		require.NoError(t, migrations.MigrateTo(m.Version), "up %d", m.Version)
+		require.NoError(t, migrations.MigrateDown(1), "down %d", m.Version)
+		require.NoError(t, migrations.MigrateTo(m.Version), "up %d again", m.Version)
+	}
+	require.NoError(t, migrations.Verify())
+
+	require.NoError(t, migrations.MigrateTo(0))
+	version, dirty, err := migrations.Version()
+	require.NoError(t, err)
+	assert.Zero(t, version)
+	assert.False(t, dirty)
+
+	pool, err := NewPostgresPool(ctx, connection, logger)
+	require.NoError(t, err)
+	defer pool.DB.Close()
+	var schemas int
+	require.NoError(t, pool.DB.QueryRow(ctx, "select count(*) from information_schema.schemata where schema_name = 'wb_demo'").Scan(&schemas))
+	assert.Zero(t, schemas, "down scripts must remove the schema")
+
+	require.NoError(t, migrations.MigrateUp())
+	require.NoError(t, migrations.Verify())
+
+	latest, err := Latest()
+	require.NoError(t, err)
+	require.NoError(t, migrations.Force(int(latest+1)))
+	assert.ErrorIs(t, migrations.MigrateUp(), ErrSchemaTooNew)
+	assert.ErrorIs(t, migrations.Startup(ModeVerify), ErrSchemaTooNew)
+}
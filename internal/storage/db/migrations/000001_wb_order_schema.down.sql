@@ -0,0 +1,10 @@
+begin transaction;
+
+drop table if exists wb_demo.delivery;
+drop table if exists wb_demo.item;
+drop table if exists wb_demo.payment;
+drop table if exists wb_demo.order;
+
+drop schema if exists wb_demo;
+
+commit transaction;
@@ -0,0 +1,5 @@
+begin transaction;
+
+drop table if exists wb_demo.quarantine;
+
+commit transaction;
@@ -0,0 +1,15 @@
+begin transaction;
+
+create table if not exists wb_demo.quarantine
+(
+    id                      uuid default gen_random_uuid(),
+    order_uid               text not null,
+    payload                 jsonb not null,
+    reason                  text not null,
+    created_at              timestamp not null default now(),
+    constraint pk_quarantine primary key (id)
+);
+
+create index if not exists idx_quarantine_order_uid on wb_demo.quarantine (order_uid);
+
+commit transaction;
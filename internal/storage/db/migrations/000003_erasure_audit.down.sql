@@ -0,0 +1,6 @@
+begin transaction;
+
+drop index if exists wb_demo.idx_order_customer_id;
+drop table if exists wb_demo.erasure_audit;
+
+commit transaction;
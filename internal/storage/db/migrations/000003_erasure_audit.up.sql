@@ -0,0 +1,16 @@
+begin transaction;
+
+create table if not exists wb_demo.erasure_audit
+(
+    id                      uuid default gen_random_uuid(),
+    customer_id             text not null,
+    mode                    text not null,
+    order_uids              text[] not null,
+    principal               text not null,
+    requested_at            timestamp not null default now(),
+    constraint pk_erasure_audit primary key (id)
+);
+
+create index if not exists idx_order_customer_id on wb_demo.order (customer_id);
+
+commit transaction;
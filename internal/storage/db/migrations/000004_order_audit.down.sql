@@ -0,0 +1,7 @@
+begin transaction;
+
+drop trigger if exists order_audit_append_only on wb_demo.order_audit;
+drop function if exists wb_demo.order_audit_append_only();
+drop table if exists wb_demo.order_audit;
+
+commit transaction;
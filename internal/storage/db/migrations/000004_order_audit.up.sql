@@ -0,0 +1,28 @@
+begin transaction;
+
+create table if not exists wb_demo.order_audit
+(
+    id                      bigserial,
+    order_uid               text not null,
+    action                  text not null,
+    source                  text not null,
+    principal               text not null,
+    diff                    jsonb not null,
+    created_at              timestamptz not null default now(),
+    constraint pk_order_audit primary key (id)
+);
+
+create index if not exists idx_order_audit_order_uid on wb_demo.order_audit (order_uid, created_at);
+
+create or replace function wb_demo.order_audit_append_only() returns trigger as
+$$
+begin
+    raise exception 'wb_demo.order_audit is append-only';
+end;
+$$ language plpgsql;
+
+create trigger order_audit_append_only
+    before update or delete on wb_demo.order_audit
+    for each row execute function wb_demo.order_audit_append_only();
+
+commit transaction;
@@ -0,0 +1,7 @@
+begin transaction;
+
+drop table if exists wb_demo.order_status_history;
+
+alter table wb_demo.order drop column if exists status;
+
+commit transaction;
@@ -0,0 +1,23 @@
+begin transaction;
+
+alter table wb_demo.order add column if not exists status text not null default 'created';
+
+create table if not exists wb_demo.order_status_history
+(
+    id                      bigserial,
+    order_uid               text not null,
+    from_status             text,
+    to_status               text not null,
+    reason                  text not null default '',
+    source                  text not null,
+    changed_at              timestamptz not null default now(),
+    constraint pk_order_status_history primary key (id),
+    constraint fk_order_uid foreign key (order_uid) references wb_demo.order (order_uid) on delete cascade
+);
+
+create index if not exists idx_order_status_history_order_uid on wb_demo.order_status_history (order_uid, changed_at);
+
+insert into wb_demo.order_status_history (order_uid, from_status, to_status, source)
+select order_uid, null, status, 'migration' from wb_demo.order;
+
+commit transaction;
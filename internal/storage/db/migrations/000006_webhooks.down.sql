@@ -0,0 +1,7 @@
+begin transaction;
+
+drop table if exists wb_demo.webhook_delivery;
+drop table if exists wb_demo.webhook_event;
+drop table if exists wb_demo.webhook_subscription;
+
+commit transaction;
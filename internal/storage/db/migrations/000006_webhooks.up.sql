@@ -0,0 +1,47 @@
+begin transaction;
+
+create table if not exists wb_demo.webhook_subscription
+(
+    id                      uuid default gen_random_uuid(),
+    url                     text not null,
+    secret                  text not null,
+    events                  text[] not null,
+    active                  boolean not null default true,
+    created_at              timestamptz not null default now(),
+    constraint pk_webhook_subscription primary key (id)
+);
+
+create table if not exists wb_demo.webhook_event
+(
+    id                      uuid default gen_random_uuid(),
+    event_type              text not null,
+    order_uid               text not null,
+    payload                 jsonb not null,
+    created_at              timestamptz not null default now(),
+    dispatched_at           timestamptz,
+    constraint pk_webhook_event primary key (id)
+);
+
+create index if not exists idx_webhook_event_pending on wb_demo.webhook_event (created_at) where dispatched_at is null;
+
+create table if not exists wb_demo.webhook_delivery
+(
+    id                      uuid default gen_random_uuid(),
+    subscription_id         uuid not null,
+    event_id                uuid not null,
+    status                  text not null default 'pending',
+    attempts                integer not null default 0,
+    next_attempt_at         timestamptz not null default now(),
+    last_status_code        integer,
+    last_error              text not null default '',
+    created_at              timestamptz not null default now(),
+    updated_at              timestamptz not null default now(),
+    constraint pk_webhook_delivery primary key (id),
+    constraint fk_subscription_id foreign key (subscription_id) references wb_demo.webhook_subscription (id) on delete cascade,
+    constraint fk_event_id foreign key (event_id) references wb_demo.webhook_event (id) on delete cascade
+);
+
+create index if not exists idx_webhook_delivery_due on wb_demo.webhook_delivery (next_attempt_at) where status = 'pending';
+create index if not exists idx_webhook_delivery_subscription on wb_demo.webhook_delivery (subscription_id, created_at);
+
+commit transaction;
@@ -0,0 +1,18 @@
+begin transaction;
+
+create table if not exists wb_demo.outbox
+(
+    id                      bigserial,
+    subject                 text not null,
+    payload                 jsonb not null,
+    attempts                integer not null default 0,
+    next_attempt_at         timestamptz not null default now(),
+    last_error              text not null default '',
+    created_at              timestamptz not null default now(),
+    sent_at                 timestamptz,
+    constraint pk_outbox primary key (id)
+);
+
+create index if not exists idx_outbox_pending on wb_demo.outbox (next_attempt_at) where sent_at is null;
+
+commit transaction;
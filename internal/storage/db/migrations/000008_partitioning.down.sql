@@ -0,0 +1,127 @@
+begin transaction;
+
+-- Only attached partitions are moved back, tables archived in wb_demo_archive must be dropped
+-- or restored by hand first.
+
+drop function if exists wb_demo.detach_order_partitions(date, boolean);
+drop function if exists wb_demo.create_order_partitions(date, date);
+drop function if exists wb_demo.create_order_partition(date);
+
+drop index if exists wb_demo.idx_order_customer_id;
+
+alter table wb_demo.order rename to order_partitioned;
+alter table wb_demo.order_partitioned rename constraint pk_order to pk_order_partitioned;
+alter table wb_demo.payment rename to payment_partitioned;
+alter table wb_demo.payment_partitioned rename constraint pk_payment to pk_payment_partitioned;
+alter table wb_demo.item rename to item_partitioned;
+alter table wb_demo.item_partitioned rename constraint pk_item to pk_item_partitioned;
+alter table wb_demo.delivery rename to delivery_partitioned;
+alter table wb_demo.delivery_partitioned rename constraint pk_delivery to pk_delivery_partitioned;
+
+create table wb_demo.order
+(
+    order_uid               text unique not null,
+    track_number            text not null,
+    entry                   text not null,
+    locale                  text not null,
+    internal_signature      text not null,
+    customer_id             text not null,
+    delivery_service        text not null,
+    shardkey                text not null,
+    sm_id                   integer not null,
+    date_created            timestamp not null,
+    oof_shard               text not null,
+    status                  text not null default 'created',
+    constraint pk_order primary key (order_uid)
+);
+
+create table wb_demo.payment
+(
+    transaction             text unique not null,
+    request_id              text not null,
+    currency                char(3) not null,
+    provider                text not null,
+    amount                  integer not null,
+    payment_dt              timestamp not null,
+    bank                    text not null,
+    delivery_cost           integer not null,
+    goods_total             integer not null,
+    custom_fee              integer not null,
+    order_uid               text unique not null,
+    constraint pk_payment primary key (transaction),
+    constraint fk_order_uid foreign key (order_uid) references wb_demo.order (order_uid)
+);
+
+create table wb_demo.item
+(
+    chrt_id                 bigint unique not null,
+    track_number            text not null,
+    price                   integer not null,
+    rid                     text not null,
+    name                    text not null,
+    sale                    integer not null,
+    size                    text not null,
+    total_price             integer not null,
+    nm_id                   bigint not null,
+    brand                   text not null,
+    status                  integer not null,
+    order_uid               text not null,
+    constraint pk_item primary key (chrt_id),
+    constraint fk_order_uid foreign key (order_uid) references wb_demo.order (order_uid)
+);
+
+create table wb_demo.delivery
+(
+    id                      uuid default gen_random_uuid(),
+    order_uid               text unique not null,
+    name                    text not null,
+    phone                   text not null,
+    zip                     text not null,
+    city                    text not null,
+    address                 text not null,
+    region                  text not null,
+    email                   text not null,
+    constraint pk_delivery primary key (id),
+    constraint fk_order_uid foreign key (order_uid) references wb_demo.order (order_uid)
+);
+
+insert into wb_demo.order
+    (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+     shardkey, sm_id, date_created, oof_shard, status)
+select order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+       shardkey, sm_id, date_created, oof_shard, status
+from wb_demo.order_partitioned;
+
+insert into wb_demo.payment
+    (transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost,
+     goods_total, custom_fee, order_uid)
+select transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost,
+       goods_total, custom_fee, order_uid
+from wb_demo.payment_partitioned;
+
+insert into wb_demo.item
+    (chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, order_uid)
+select chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, order_uid
+from wb_demo.item_partitioned;
+
+insert into wb_demo.delivery
+    (id, order_uid, name, phone, zip, city, address, region, email)
+select id, order_uid, name, phone, zip, city, address, region, email
+from wb_demo.delivery_partitioned;
+
+drop table wb_demo.delivery_partitioned;
+drop table wb_demo.item_partitioned;
+drop table wb_demo.payment_partitioned;
+drop table wb_demo.order_partitioned;
+
+create index if not exists idx_order_customer_id on wb_demo.order (customer_id);
+
+delete from wb_demo.order_status_history h
+where not exists (select 1 from wb_demo.order o where o.order_uid = h.order_uid);
+
+alter table wb_demo.order_status_history
+    add constraint fk_order_uid foreign key (order_uid) references wb_demo.order (order_uid) on delete cascade;
+
+drop schema if exists wb_demo_archive;
+
+commit transaction;
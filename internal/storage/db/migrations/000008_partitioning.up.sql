@@ -0,0 +1,228 @@
+begin transaction;
+
+-- Partitioned tables need the partition key in every unique constraint, so order_uid,
+-- transaction and chrt_id are unique together with date_created from now on. Child tables
+-- carry date_created of their order to be partitioned by the same month.
+
+alter table wb_demo.order_status_history drop constraint if exists fk_order_uid;
+drop index if exists wb_demo.idx_order_customer_id;
+
+alter table wb_demo.order rename to order_unpartitioned;
+alter table wb_demo.order_unpartitioned rename constraint pk_order to pk_order_unpartitioned;
+alter table wb_demo.payment rename to payment_unpartitioned;
+alter table wb_demo.payment_unpartitioned rename constraint pk_payment to pk_payment_unpartitioned;
+alter table wb_demo.item rename to item_unpartitioned;
+alter table wb_demo.item_unpartitioned rename constraint pk_item to pk_item_unpartitioned;
+alter table wb_demo.delivery rename to delivery_unpartitioned;
+alter table wb_demo.delivery_unpartitioned rename constraint pk_delivery to pk_delivery_unpartitioned;
+
+create table wb_demo.order
+(
+    order_uid               text not null,
+    track_number            text not null,
+    entry                   text not null,
+    locale                  text not null,
+    internal_signature      text not null,
+    customer_id             text not null,
+    delivery_service        text not null,
+    shardkey                text not null,
+    sm_id                   integer not null,
+    date_created            timestamp not null,
+    oof_shard               text not null,
+    status                  text not null default 'created',
+    constraint pk_order primary key (order_uid, date_created)
+) partition by range (date_created);
+
+create table wb_demo.payment
+(
+    transaction             text not null,
+    request_id              text not null,
+    currency                char(3) not null,
+    provider                text not null,
+    amount                  integer not null,
+    payment_dt              timestamp not null,
+    bank                    text not null,
+    delivery_cost           integer not null,
+    goods_total             integer not null,
+    custom_fee              integer not null,
+    order_uid               text not null,
+    date_created            timestamp not null,
+    constraint pk_payment primary key (transaction, date_created),
+    constraint uq_payment_order_uid unique (order_uid, date_created),
+    constraint fk_order_uid foreign key (order_uid, date_created) references wb_demo.order (order_uid, date_created)
+) partition by range (date_created);
+
+create table wb_demo.item
+(
+    chrt_id                 bigint not null,
+    track_number            text not null,
+    price                   integer not null,
+    rid                     text not null,
+    name                    text not null,
+    sale                    integer not null,
+    size                    text not null,
+    total_price             integer not null,
+    nm_id                   bigint not null,
+    brand                   text not null,
+    status                  integer not null,
+    order_uid               text not null,
+    date_created            timestamp not null,
+    constraint pk_item primary key (chrt_id, date_created),
+    constraint fk_order_uid foreign key (order_uid, date_created) references wb_demo.order (order_uid, date_created)
+) partition by range (date_created);
+
+create table wb_demo.delivery
+(
+    id                      uuid default gen_random_uuid(),
+    order_uid               text not null,
+    name                    text not null,
+    phone                   text not null,
+    zip                     text not null,
+    city                    text not null,
+    address                 text not null,
+    region                  text not null,
+    email                   text not null,
+    date_created            timestamp not null,
+    constraint pk_delivery primary key (id, date_created),
+    constraint uq_delivery_order_uid unique (order_uid, date_created),
+    constraint fk_order_uid foreign key (order_uid, date_created) references wb_demo.order (order_uid, date_created)
+) partition by range (date_created);
+
+create index if not exists idx_order_customer_id on wb_demo.order (customer_id);
+create index if not exists idx_item_order_uid on wb_demo.item (order_uid, date_created);
+
+-- Rows of months without a partition land in the default partitions until
+-- create_order_partition moves them to their own.
+create table wb_demo.order_default partition of wb_demo.order default;
+create table wb_demo.payment_default partition of wb_demo.payment default;
+create table wb_demo.item_default partition of wb_demo.item default;
+create table wb_demo.delivery_default partition of wb_demo.delivery default;
+
+create schema if not exists wb_demo_archive;
+
+-- create_order_partition creates the partitions of the month of all order tables, moving rows
+-- of the month out of the default partitions. It returns false if they already exist.
+create or replace function wb_demo.create_order_partition(partition_month date) returns boolean as
+$$
+declare
+    lower_bound date := date_trunc('month', partition_month)::date;
+    upper_bound date := (date_trunc('month', partition_month) + interval '1 month')::date;
+    suffix      text := to_char(lower_bound, '"p"YYYYMM');
+    parent    text;
+begin
+    if to_regclass(format('wb_demo.%I', 'order_' || suffix)) is not null then
+        return false;
+    end if;
+
+    -- children first, their rows reference the order rows
+    foreach parent in array array['item', 'payment', 'delivery', 'order'] loop
+        execute format('create table wb_demo.%I (like wb_demo.%I including defaults)', parent || '_' || suffix, parent);
+        execute format('with moved as (delete from wb_demo.%I where date_created >= $1 and date_created < $2 returning *) insert into wb_demo.%I select * from moved',
+                       parent || '_default', parent || '_' || suffix) using lower_bound, upper_bound;
+    end loop;
+
+    -- orders first, attaching a child partition validates its foreign key
+    foreach parent in array array['order', 'item', 'payment', 'delivery'] loop
+        execute format('alter table wb_demo.%I attach partition wb_demo.%I for values from (%L) to (%L)',
+                       parent, parent || '_' || suffix, lower_bound, upper_bound);
+    end loop;
+
+    return true;
+end;
+$$ language plpgsql;
+
+-- create_order_partitions creates missing partitions of the months from the first to the last
+-- month and returns their suffixes.
+create or replace function wb_demo.create_order_partitions(first_month date, last_month date) returns setof text as
+$$
+declare
+    partition_month date;
+begin
+    for partition_month in select generate_series(date_trunc('month', first_month), date_trunc('month', last_month), interval '1 month')::date loop
+        if wb_demo.create_order_partition(partition_month) then
+            return next to_char(partition_month, '"p"YYYYMM');
+        end if;
+    end loop;
+end;
+$$ language plpgsql;
+
+-- detach_order_partitions detaches partitions of months ending before the cutoff and moves them
+-- to wb_demo_archive, or drops them, and returns their suffixes.
+create or replace function wb_demo.detach_order_partitions(cutoff date, drop_tables boolean) returns setof text as
+$$
+declare
+    suffix    text;
+    parent    text;
+    fk_name   text;
+begin
+    for suffix in
+        select substring(c.relname from '_(p\d{6})$')
+        from pg_inherits i
+        join pg_class c on c.oid = i.inhrelid
+        where i.inhparent = 'wb_demo.order'::regclass
+          and c.relname ~ '^order_p\d{6}$'
+          and to_date(substring(c.relname from '_p(\d{6})$'), 'YYYYMM') + interval '1 month' <= cutoff
+        order by 1
+    loop
+        -- children first, a detached child keeps its foreign key to the order table
+        foreach parent in array array['item', 'payment', 'delivery', 'order'] loop
+            execute format('alter table wb_demo.%I detach partition wb_demo.%I', parent, parent || '_' || suffix);
+            for fk_name in
+                select conname from pg_constraint
+                where conrelid = format('wb_demo.%I', parent || '_' || suffix)::regclass and contype = 'f'
+            loop
+                execute format('alter table wb_demo.%I drop constraint %I', parent || '_' || suffix, fk_name);
+            end loop;
+
+            if drop_tables then
+                execute format('drop table wb_demo.%I', parent || '_' || suffix);
+            else
+                execute format('alter table wb_demo.%I set schema wb_demo_archive', parent || '_' || suffix);
+            end if;
+        end loop;
+
+        return next suffix;
+    end loop;
+end;
+$$ language plpgsql;
+
+select wb_demo.create_order_partitions(
+    least((select min(date_created) from wb_demo.order_unpartitioned), now())::date,
+    (now() + interval '3 months')::date
+);
+
+insert into wb_demo.order
+    (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+     shardkey, sm_id, date_created, oof_shard, status)
+select order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+       shardkey, sm_id, date_created, oof_shard, status
+from wb_demo.order_unpartitioned;
+
+insert into wb_demo.payment
+    (transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost,
+     goods_total, custom_fee, order_uid, date_created)
+select p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, p.bank, p.delivery_cost,
+       p.goods_total, p.custom_fee, p.order_uid, o.date_created
+from wb_demo.payment_unpartitioned p
+join wb_demo.order_unpartitioned o on o.order_uid = p.order_uid;
+
+insert into wb_demo.item
+    (chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status,
+     order_uid, date_created)
+select i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status,
+       i.order_uid, o.date_created
+from wb_demo.item_unpartitioned i
+join wb_demo.order_unpartitioned o on o.order_uid = i.order_uid;
+
+insert into wb_demo.delivery
+    (id, order_uid, name, phone, zip, city, address, region, email, date_created)
+select d.id, d.order_uid, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, o.date_created
+from wb_demo.delivery_unpartitioned d
+join wb_demo.order_unpartitioned o on o.order_uid = d.order_uid;
+
+drop table wb_demo.delivery_unpartitioned;
+drop table wb_demo.item_unpartitioned;
+drop table wb_demo.payment_unpartitioned;
+drop table wb_demo.order_unpartitioned;
+
+commit transaction;
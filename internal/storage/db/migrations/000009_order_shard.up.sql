@@ -0,0 +1,12 @@
+begin transaction;
+
+-- the shard of every order, used in the main database when DATABASE_SHARDS is set
+create table if not exists wb_demo.order_shard
+(
+    order_uid               text not null,
+    shard                   text not null,
+    created_at              timestamptz not null default now(),
+    constraint pk_order_shard primary key (order_uid)
+);
+
+commit transaction;
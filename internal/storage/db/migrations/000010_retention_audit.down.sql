@@ -0,0 +1,45 @@
+begin transaction;
+
+drop function if exists wb_demo.detach_order_partitions(date, boolean);
+
+-- detach_order_partitions detaches partitions of months ending before the cutoff and moves them
+-- to wb_demo_archive, or drops them, and returns their suffixes.
+create or replace function wb_demo.detach_order_partitions(cutoff date, drop_tables boolean) returns setof text as
+$$
+declare
+    suffix    text;
+    parent    text;
+    fk_name   text;
+begin
+    for suffix in
+        select substring(c.relname from '_(p\d{6})$')
+        from pg_inherits i
+        join pg_class c on c.oid = i.inhrelid
+        where i.inhparent = 'wb_demo.order'::regclass
+          and c.relname ~ '^order_p\d{6}$'
+          and to_date(substring(c.relname from '_p(\d{6})$'), 'YYYYMM') + interval '1 month' <= cutoff
+        order by 1
+    loop
+        -- children first, a detached child keeps its foreign key to the order table
+        foreach parent in array array['item', 'payment', 'delivery', 'order'] loop
+            execute format('alter table wb_demo.%I detach partition wb_demo.%I', parent, parent || '_' || suffix);
+            for fk_name in
+                select conname from pg_constraint
+                where conrelid = format('wb_demo.%I', parent || '_' || suffix)::regclass and contype = 'f'
+            loop
+                execute format('alter table wb_demo.%I drop constraint %I', parent || '_' || suffix, fk_name);
+            end loop;
+
+            if drop_tables then
+                execute format('drop table wb_demo.%I', parent || '_' || suffix);
+            else
+                execute format('alter table wb_demo.%I set schema wb_demo_archive', parent || '_' || suffix);
+            end if;
+        end loop;
+
+        return next suffix;
+    end loop;
+end;
+$$ language plpgsql;
+
+commit transaction;
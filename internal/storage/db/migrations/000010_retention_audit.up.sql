@@ -0,0 +1,49 @@
+begin transaction;
+
+-- detach_order_partitions returns the orders of the detached partitions as well, so the caller
+-- records them in the audit trail in the same transaction. Empty partitions are returned with
+-- a null order_uid.
+drop function if exists wb_demo.detach_order_partitions(date, boolean);
+
+create function wb_demo.detach_order_partitions(cutoff date, drop_tables boolean) returns table (suffix text, order_uid text) as
+$$
+declare
+    partition_suffix text;
+    parent           text;
+    fk_name          text;
+begin
+    for partition_suffix in
+        select substring(c.relname from '_(p\d{6})$')
+        from pg_inherits i
+        join pg_class c on c.oid = i.inhrelid
+        where i.inhparent = 'wb_demo.order'::regclass
+          and c.relname ~ '^order_p\d{6}$'
+          and to_date(substring(c.relname from '_p(\d{6})$'), 'YYYYMM') + interval '1 month' <= cutoff
+        order by 1
+    loop
+        return query execute format('select %L::text, o.order_uid from wb_demo.%I o', partition_suffix, 'order_' || partition_suffix);
+        if not found then
+            return query select partition_suffix, null::text;
+        end if;
+
+        -- children first, a detached child keeps its foreign key to the order table
+        foreach parent in array array['item', 'payment', 'delivery', 'order'] loop
+            execute format('alter table wb_demo.%I detach partition wb_demo.%I', parent, parent || '_' || partition_suffix);
+            for fk_name in
+                select conname from pg_constraint
+                where conrelid = format('wb_demo.%I', parent || '_' || partition_suffix)::regclass and contype = 'f'
+            loop
+                execute format('alter table wb_demo.%I drop constraint %I', parent || '_' || partition_suffix, fk_name);
+            end loop;
+
+            if drop_tables then
+                execute format('drop table wb_demo.%I', parent || '_' || partition_suffix);
+            else
+                execute format('alter table wb_demo.%I set schema wb_demo_archive', parent || '_' || partition_suffix);
+            end if;
+        end loop;
+    end loop;
+end;
+$$ language plpgsql;
+
+commit transaction;
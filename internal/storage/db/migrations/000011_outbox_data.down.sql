@@ -0,0 +1,5 @@
+begin transaction;
+
+alter table wb_demo.outbox drop column if exists data;
+
+commit transaction;
@@ -0,0 +1,7 @@
+begin transaction;
+
+-- data holds the message as published when it is encoded with the codec of the subject, such as
+-- requeued orders, payload keeps the order JSON for erasure.
+alter table wb_demo.outbox add column if not exists data bytea;
+
+commit transaction;
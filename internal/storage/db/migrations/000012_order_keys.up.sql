@@ -0,0 +1,108 @@
+begin transaction;
+
+-- Unique constraints of the partitioned tables include date_created, so these non-partitioned
+-- tables keep order_uid, transaction and chrt_id unique across partitions. They are written in
+-- the transaction of the order and the status history references orders through order_key.
+-- Each database has its own key tables, with DATABASE_SHARDS the keys are unique per shard.
+
+create table if not exists wb_demo.order_key
+(
+    order_uid               text not null,
+    date_created            timestamp not null,
+    constraint pk_order_key primary key (order_uid)
+);
+
+create table if not exists wb_demo.payment_key
+(
+    transaction             text not null,
+    order_uid               text not null,
+    constraint pk_payment_key primary key (transaction),
+    constraint fk_order_uid foreign key (order_uid) references wb_demo.order_key (order_uid) on delete cascade
+);
+
+create table if not exists wb_demo.item_key
+(
+    chrt_id                 bigint not null,
+    order_uid               text not null,
+    constraint pk_item_key primary key (chrt_id),
+    constraint fk_order_uid foreign key (order_uid) references wb_demo.order_key (order_uid) on delete cascade
+);
+
+create index if not exists idx_payment_key_order_uid on wb_demo.payment_key (order_uid);
+create index if not exists idx_item_key_order_uid on wb_demo.item_key (order_uid);
+
+-- Duplicates stored while the keys were missing keep the key of their earliest row.
+insert into wb_demo.order_key (order_uid, date_created)
+select order_uid, min(date_created) from wb_demo.order group by order_uid;
+
+insert into wb_demo.payment_key (transaction, order_uid)
+select distinct on (p.transaction) p.transaction, p.order_uid
+from wb_demo.payment p
+join wb_demo.order_key k on k.order_uid = p.order_uid
+order by p.transaction, p.date_created;
+
+insert into wb_demo.item_key (chrt_id, order_uid)
+select distinct on (i.chrt_id) i.chrt_id, i.order_uid
+from wb_demo.item i
+join wb_demo.order_key k on k.order_uid = i.order_uid
+order by i.chrt_id, i.date_created;
+
+alter table wb_demo.order
+    add constraint fk_order_key foreign key (order_uid) references wb_demo.order_key (order_uid);
+
+delete from wb_demo.order_status_history h
+where not exists (select 1 from wb_demo.order_key k where k.order_uid = h.order_uid);
+
+alter table wb_demo.order_status_history
+    add constraint fk_order_uid foreign key (order_uid) references wb_demo.order_key (order_uid) on delete cascade;
+
+-- detach_order_partitions deletes the order keys of the detached partitions as well, removing
+-- their status history.
+drop function if exists wb_demo.detach_order_partitions(date, boolean);
+
+create function wb_demo.detach_order_partitions(cutoff date, drop_tables boolean) returns table (suffix text, order_uid text) as
+$$
+declare
+    partition_suffix text;
+    parent           text;
+    fk_name          text;
+begin
+    for partition_suffix in
+        select substring(c.relname from '_(p\d{6})$')
+        from pg_inherits i
+        join pg_class c on c.oid = i.inhrelid
+        where i.inhparent = 'wb_demo.order'::regclass
+          and c.relname ~ '^order_p\d{6}$'
+          and to_date(substring(c.relname from '_p(\d{6})$'), 'YYYYMM') + interval '1 month' <= cutoff
+        order by 1
+    loop
+        return query execute format('select %L::text, o.order_uid from wb_demo.%I o', partition_suffix, 'order_' || partition_suffix);
+        if not found then
+            return query select partition_suffix, null::text;
+        end if;
+
+        -- children first, a detached child keeps its foreign key to the order table
+        foreach parent in array array['item', 'payment', 'delivery', 'order'] loop
+            execute format('alter table wb_demo.%I detach partition wb_demo.%I', parent, parent || '_' || partition_suffix);
+            for fk_name in
+                select conname from pg_constraint
+                where conrelid = format('wb_demo.%I', parent || '_' || partition_suffix)::regclass and contype = 'f'
+            loop
+                execute format('alter table wb_demo.%I drop constraint %I', parent || '_' || partition_suffix, fk_name);
+            end loop;
+        end loop;
+
+        execute format('delete from wb_demo.order_key k using wb_demo.%I o where k.order_uid = o.order_uid', 'order_' || partition_suffix);
+
+        foreach parent in array array['item', 'payment', 'delivery', 'order'] loop
+            if drop_tables then
+                execute format('drop table wb_demo.%I', parent || '_' || partition_suffix);
+            else
+                execute format('alter table wb_demo.%I set schema wb_demo_archive', parent || '_' || partition_suffix);
+            end if;
+        end loop;
+    end loop;
+end;
+$$ language plpgsql;
+
+commit transaction;
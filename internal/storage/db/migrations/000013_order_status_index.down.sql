@@ -0,0 +1,5 @@
+begin transaction;
+
+drop index if exists wb_demo.idx_order_status;
+
+commit transaction;
@@ -0,0 +1,5 @@
+begin transaction;
+
+create index if not exists idx_order_status on wb_demo.order (status);
+
+commit transaction;
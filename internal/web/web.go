@@ -0,0 +1,67 @@
+// Package web renders the built-in support UI of wborder.
+package web
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var funcs = template.FuncMap{
+	"unix": func(sec int64) string {
+		return time.Unix(sec, 0).UTC().Format("2006-01-02 15:04:05")
+	},
+	"datetime": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	},
+	"deref": func(i *int) string {
+		if i == nil {
+			return "-"
+		}
+		return fmt.Sprint(*i)
+	},
+}
+
+// Renderer renders pages, each page template is combined with the shared layout.
+type Renderer struct {
+	pages map[string]*template.Template
+}
+
+func NewRenderer() (*Renderer, error) {
+	pages, err := fs.Glob(templates, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Renderer{pages: make(map[string]*template.Template)}
+	for _, page := range pages {
+		name := path.Base(page)
+		if name == "layout.html" {
+			continue
+		}
+		t, err := template.New(name).Funcs(funcs).ParseFS(templates, "templates/layout.html", page)
+		if err != nil {
+			return nil, err
+		}
+		r.pages[name] = t
+	}
+
+	return r, nil
+}
+
+func (r *Renderer) Render(w io.Writer, name string, data interface{}, _ echo.Context) error {
+	t, ok := r.pages[name]
+	if !ok {
+		return fmt.Errorf("unknown page %q", name)
+	}
+	return t.ExecuteTemplate(w, "layout", data)
+}
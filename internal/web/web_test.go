@@ -0,0 +1,45 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderer(t *testing.T) {
+	r, err := NewRenderer()
+	require.NoError(t, err)
+
+	amount := 1817
+	from := "created"
+	data := map[string]any{
+		"Title":     "Заказ",
+		"Principal": "support",
+		"Query":     "b563feb7b2b84b6test",
+		"By":        "id",
+		"Order": map[string]any{
+			"OrderUID": "b563feb7b2b84b6test",
+			"Delivery": map[string]any{"Name": "T*** T***"},
+			"Payment":  map[string]any{"Amount": &amount, "PaymentDt": int64(1637907727)},
+			"Items":    []map[string]any{{"ChrtID": 9934930}},
+		},
+		"StatusHistory": []map[string]any{{"FromStatus": &from, "ToStatus": "paid", "ChangedAt": time.Now()}},
+	}
+
+	for page := range r.pages {
+		t.Run(page, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, r.Render(&buf, page, data, nil))
+			assert.Contains(t, buf.String(), "<h1>Заказ</h1>")
+		})
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Render(&buf, "order.html", data, nil))
+	assert.Contains(t, buf.String(), "2021-11-26 06:22:07")
+	assert.Contains(t, buf.String(), "<td>created</td>")
+	assert.Error(t, r.Render(&buf, "missing.html", data, nil))
+}
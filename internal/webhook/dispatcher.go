@@ -0,0 +1,260 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/wb-order-nats/internal/model"
+	"github.com/msmkdenis/wb-order-nats/pkg/backoff"
+)
+
+// Delivery statuses, they match the status column of wb_demo.webhook_delivery.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Headers sent with every webhook request.
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	batchSize = 100
+	// concurrency is the number of deliveries of a batch sent at once.
+	concurrency = 10
+	timeout     = 10 * time.Second
+	// lease postpones leased deliveries, so they are retried if the dispatcher dies mid-send. It
+	// outlasts a whole batch of timed out requests, so another replica never leases a delivery
+	// that is still being sent.
+	lease      = (batchSize+concurrency-1)/concurrency*timeout + time.Minute
+	minBackoff = 5 * time.Second
+	maxBackoff = time.Hour
+)
+
+type Repository interface {
+	FanOutEvents(ctx context.Context, limit int) (int64, error)
+	LeaseDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.PendingDelivery, error)
+	UpdateDelivery(ctx context.Context, id string, status string, nextAttempt time.Time, statusCode *int, lastError string) error
+	CopyEvents(ctx context.Context, events []model.PendingEvent) error
+}
+
+// EventSource keeps events outside the database of the subscriptions, e.g. an order shard
+// writing them in the transaction of the order.
+type EventSource interface {
+	SelectPendingEvents(ctx context.Context, limit int) ([]model.PendingEvent, error)
+	MarkEventsDispatched(ctx context.Context, ids []string) error
+}
+
+// Body is the JSON document posted to subscribers.
+type Body struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher delivers outbox events to webhook subscribers with at-least-once semantics.
+type Dispatcher struct {
+	repository  Repository
+	sources     []EventSource
+	client      *http.Client
+	interval    time.Duration
+	maxAttempts int
+	logger      *zap.Logger
+}
+
+// NewDispatcher creates a dispatcher refusing to connect to internal addresses.
+func NewDispatcher(repository Repository, interval time.Duration, maxAttempts int, logger *zap.Logger) *Dispatcher {
+	d := &Dispatcher{
+		repository:  repository,
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		logger:      logger,
+	}
+	d.SetAllowPrivate(false)
+	return d
+}
+
+// SetAllowPrivate allows deliveries to internal addresses, e.g. for subscribers in the same network.
+func (d *Dispatcher) SetAllowPrivate(allow bool) {
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allow {
+		// a proxy would connect on behalf of the dispatcher and bypass the address check
+		dialer.Control = dialControl
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	d.client = &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// AddEventSource makes the dispatcher collect the events of the source before fanning out.
+func (d *Dispatcher) AddEventSource(source EventSource) {
+	d.sources = append(d.sources, source)
+}
+
+// Run fans out new events and sends due deliveries every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			d.logger.Info("webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+			d.dispatch(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	for _, source := range d.sources {
+		d.collect(ctx, source)
+	}
+
+	created, err := d.repository.FanOutEvents(ctx, batchSize)
+	if err != nil {
+		d.logger.Error("unable to fan out webhook events", zap.Error(err))
+	} else if created > 0 {
+		d.logger.Info("webhook deliveries created", zap.Int64("count", created))
+	}
+
+	deliveries, err := d.repository.LeaseDeliveries(ctx, batchSize, lease)
+	if err != nil {
+		d.logger.Error("unable to lease webhook deliveries", zap.Error(err))
+		return
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery model.PendingDelivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			d.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+}
+
+// collect copies pending events of the source to the repository and marks them dispatched at
+// the source. A copy repeated after a failed mark is skipped by the event id.
+func (d *Dispatcher) collect(ctx context.Context, source EventSource) {
+	events, err := source.SelectPendingEvents(ctx, batchSize)
+	if err != nil {
+		d.logger.Error("unable to select webhook events", zap.Error(err))
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	err = d.repository.CopyEvents(ctx, events)
+	if err != nil {
+		d.logger.Error("unable to copy webhook events", zap.Error(err))
+		return
+	}
+
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	err = source.MarkEventsDispatched(ctx, ids)
+	if err != nil {
+		d.logger.Error("unable to mark webhook events dispatched", zap.Error(err))
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery model.PendingDelivery) {
+	statusCode, err := d.send(ctx, delivery)
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	status, nextAttempt, lastError := StatusSucceeded, time.Now(), ""
+	if err != nil {
+		attempts := delivery.Attempts + 1
+		status, nextAttempt, lastError = StatusPending, time.Now().Add(backoff.Exponential(attempts, minBackoff, maxBackoff)), err.Error()
+		if attempts >= d.maxAttempts {
+			status = StatusFailed
+		}
+		d.logger.Info("webhook delivery failed", zap.String("id", delivery.ID), zap.Int("attempt", attempts), zap.Error(err))
+	}
+
+	err = d.repository.UpdateDelivery(ctx, delivery.ID, status, nextAttempt, code, lastError)
+	if err != nil {
+		d.logger.Error("unable to update webhook delivery", zap.String("id", delivery.ID), zap.Error(err))
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery model.PendingDelivery) (int, error) {
+	body, err := json.Marshal(Body{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.EventID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) //nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of the body: sha256=hex(hmac-sha256(secret, timestamp + "." + body)).
+// Subscribers recompute it to authenticate the request and reject stale timestamps to prevent replays.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + ".")) //nolint:errcheck
+	mac.Write(body)                    //nolint:errcheck
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// ErrForbiddenURL is returned for subscription URLs that are not http(s) or point to an
+// internal address.
+var ErrForbiddenURL = errors.New("webhook url is not allowed")
+
+// ValidateURL checks that the subscription URL is http or https and that its host resolves to
+// public addresses only, unless allowPrivate is set, so subscriptions cannot reach internal
+// services.
+func ValidateURL(ctx context.Context, rawURL string, allowPrivate bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrForbiddenURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrForbiddenURL, u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrForbiddenURL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials in url", ErrForbiddenURL)
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrForbiddenURL, err)
+	}
+	for _, addr := range addrs {
+		if !public(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenURL, host, addr)
+		}
+	}
+	return nil
+}
+
+// public reports whether the address is routable on the internet.
+func public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !cgnat.Contains(addr)
+}
+
+// cgnat is the shared address space of carrier-grade NAT, RFC 6598.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// dialControl refuses connections to internal addresses, it guards against hosts resolving to
+// other addresses at delivery time than at subscription time.
+func dialControl(_ string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !public(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenURL, addrPort.Addr())
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+// Package backoff implements retry delays of background senders such as the outbox relay and
+// the webhook dispatcher.
+package backoff
+
+import "time"
+
+// Exponential returns the delay before the next attempt after the given number of failed ones.
+// The delay starts at initial and doubles with every failed attempt up to limit.
+func Exponential(attempts int, initial time.Duration, limit time.Duration) time.Duration {
+	delay := initial
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
@@ -0,0 +1,15 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponential(t *testing.T) {
+	assert.Equal(t, 5*time.Second, Exponential(1, 5*time.Second, time.Hour))
+	assert.Equal(t, 20*time.Second, Exponential(3, 5*time.Second, time.Hour))
+	assert.Equal(t, time.Hour, Exponential(50, 5*time.Second, time.Hour))
+	assert.Equal(t, time.Second, Exponential(0, time.Second, time.Minute))
+}
@@ -0,0 +1,61 @@
+// Package mask implements masking of personal data values.
+package mask
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Hidden replaces the masked part of a value.
+const Hidden = "***"
+
+// Phone keeps the country code and the last 4 digits: +79161234567 -> +7***4567.
+func Phone(value string) string {
+	r := []rune(value)
+	if len(r) <= 6 {
+		return Hidden
+	}
+
+	prefix := 1
+	if r[0] == '+' {
+		prefix = 2
+	}
+	return string(r[:prefix]) + Hidden + string(r[len(r)-4:])
+}
+
+// Email keeps the first character of the local part and the domain: ivan@mail.ru -> i***@mail.ru.
+func Email(value string) string {
+	local, domain, ok := strings.Cut(value, "@")
+	if !ok || local == "" {
+		return Hidden
+	}
+
+	first, _ := utf8.DecodeRuneInString(local)
+	return string(first) + Hidden + "@" + domain
+}
+
+// Name keeps the first letter of every word: Test Testov -> T*** T***.
+func Name(value string) string {
+	words := strings.Fields(value)
+	for i, w := range words {
+		first, _ := utf8.DecodeRuneInString(w)
+		words[i] = string(first) + Hidden
+	}
+	return strings.Join(words, " ")
+}
+
+// Prefix keeps the first n characters.
+func Prefix(n int) func(value string) string {
+	return func(value string) string {
+		r := []rune(value)
+		if len(r) <= n {
+			return Hidden
+		}
+		return string(r[:n]) + Hidden
+	}
+}
+
+// Redact hides the value completely.
+func Redact(string) string {
+	return Hidden
+}
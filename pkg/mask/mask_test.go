@@ -0,0 +1,30 @@
+package mask
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name   string
+		masker func(string) string
+		value  string
+		want   string
+	}{
+		{name: "phone", masker: Phone, value: "+79161231234", want: "+7***1234"},
+		{name: "short phone", masker: Phone, value: "+7916", want: Hidden},
+		{name: "email", masker: Email, value: "ivan@mail.ru", want: "i***@mail.ru"},
+		{name: "cyrillic email", masker: Email, value: "иван@почта.рф", want: "и***@почта.рф"},
+		{name: "invalid email", masker: Email, value: "ivan", want: Hidden},
+		{name: "name", masker: Name, value: "Test Testov", want: "T*** T***"},
+		{name: "zip", masker: Prefix(3), value: "2639809", want: "263***"},
+		{name: "address", masker: Redact, value: "Ploshad Mira 15", want: Hidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.masker(tt.value))
+		})
+	}
+}
@@ -1 +1 @@
-truncate table wb_demo.item, wb_demo.delivery, wb_demo.payment, wb_demo."order";
\ No newline at end of file
+truncate table wb_demo.item, wb_demo.delivery, wb_demo.payment, wb_demo."order", wb_demo.quarantine, wb_demo.erasure_audit, wb_demo.order_audit, wb_demo.order_status_history, wb_demo.webhook_delivery, wb_demo.webhook_event, wb_demo.webhook_subscription, wb_demo.outbox;
\ No newline at end of file